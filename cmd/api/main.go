@@ -1,12 +1,13 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/bangadam/wallet-api/internal/delivery/http"
-	"github.com/bangadam/wallet-api/internal/domain"
 	"github.com/bangadam/wallet-api/internal/middleware"
 	"github.com/bangadam/wallet-api/internal/repository"
 	"github.com/bangadam/wallet-api/internal/usecase"
@@ -14,6 +15,7 @@ import (
 	"github.com/bangadam/wallet-api/pkg/database"
 	"github.com/bangadam/wallet-api/pkg/queue"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 	"github.com/spf13/viper"
 )
@@ -41,9 +43,8 @@ func main() {
 		log.Fatalf("Failed to connect to database: %s", err)
 	}
 
-	// Auto migrate database
-	err = db.AutoMigrate(&domain.User{}, &domain.Transaction{})
-	if err != nil {
+	// Migrate database
+	if err := repository.Migrate(db); err != nil {
 		log.Fatalf("Failed to migrate database: %s", err)
 	}
 
@@ -67,35 +68,252 @@ func main() {
 
 	// Setup repositories
 	userRepo := repository.NewUserRepository(db)
+	walletRepo := repository.NewWalletRepository(db)
 	transactionRepo := repository.NewTransactionRepository(db)
+	ledgerRepo := repository.NewLedgerRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	outboxRepo := repository.NewOutboxRepository(db)
+	reconciliationRepo := repository.NewReconciliationRepository(db)
+	fxRepo := repository.NewFXRepository(db)
+	pocketRepo := repository.NewPocketRepository(db)
+	scheduleRepo := repository.NewScheduleRepository(db)
+	limitRepo := repository.NewLimitRepository(db)
+	merchantRepo := repository.NewMerchantRepository(db)
+	settlementRepo := repository.NewSettlementRepository(db)
+	paymentIntentRepo := repository.NewPaymentIntentRepository(db)
+	moneyRequestRepo := repository.NewMoneyRequestRepository(db)
+	billRepo := repository.NewBillRepository(db)
+	uow := repository.NewUnitOfWork(db)
 
 	// Setup usecases
+	var limitsConfig usecase.LimitsConfig
+	if err := viper.UnmarshalKey("limits", &limitsConfig); err != nil {
+		log.Fatalf("Failed to read transaction limits: %s", err)
+	}
+	limitsUsecase, err := usecase.NewLimitsUsecase(uow, userRepo, limitRepo, limitsConfig)
+	if err != nil {
+		log.Fatalf("Invalid transaction limits: %s", err)
+	}
+	var feesConfig usecase.FeesConfig
+	if err := viper.UnmarshalKey("fees", &feesConfig); err != nil {
+		log.Fatalf("Failed to read fee schedule: %s", err)
+	}
+	feeUsecase, err := usecase.NewFeeUsecase(userRepo, limitsUsecase, feesConfig)
+	if err != nil {
+		log.Fatalf("Invalid fee schedule: %s", err)
+	}
 	userUsecase := usecase.NewUserUsecase(userRepo, jwtService)
-	transactionUsecase := usecase.NewTransactionUsecase(transactionRepo, userRepo, queueService)
+	transactionUsecase := usecase.NewTransactionUsecase(uow, transactionRepo, userRepo, walletRepo, ledgerRepo, queueService, limitsUsecase, feeUsecase)
+	notificationUsecase := usecase.NewNotificationUsecase(notificationRepo)
+	outboxRelay := usecase.NewOutboxRelay(uow, queueService, viper.GetInt("outbox.batch_size"))
+	transferSweeper := usecase.NewTransferSweeper(uow, transactionRepo, outboxRepo, transactionUsecase, queueService, usecase.TransferSweeperConfig{
+		MinAge:    viper.GetDuration("sweeper.min_age"),
+		MaxAge:    viper.GetDuration("sweeper.max_age"),
+		BatchSize: viper.GetInt("sweeper.batch_size"),
+	})
+	reconciliationUsecase := usecase.NewReconciliationUsecase(uow, userRepo, reconciliationRepo, usecase.ReconciliationConfig{
+		AutoFreeze: viper.GetBool("reconciliation.auto_freeze"),
+		BatchSize:  viper.GetInt("reconciliation.batch_size"),
+	})
+	fxUsecase := usecase.NewFXUsecase(uow, fxRepo, usecase.FXConfig{
+		QuoteTTL: viper.GetDuration("fx.quote_ttl"),
+	})
+	pocketUsecase := usecase.NewPocketUsecase(uow, pocketRepo)
+	refundUsecase := usecase.NewRefundUsecase(uow)
+	merchantUsecase := usecase.NewMerchantUsecase(uow, merchantRepo, transactionRepo)
+	settlementUsecase := usecase.NewSettlementUsecase(uow, merchantRepo, settlementRepo, usecase.SettlementConfig{
+		FeeRateBps: viper.GetInt64("settlements.fee_rate_bps"),
+		BatchSize:  viper.GetInt("settlements.batch_size"),
+	})
+	scheduleUsecase := usecase.NewScheduleUsecase(uow, scheduleRepo, limitsUsecase, feeUsecase, usecase.ScheduleConfig{
+		BatchSize: viper.GetInt("schedules.batch_size"),
+	})
+	paymentIntentUsecase := usecase.NewPaymentIntentUsecase(uow, paymentIntentRepo, merchantRepo, transactionUsecase, usecase.PaymentIntentConfig{
+		DefaultTTL:      viper.GetDuration("payment_intents.ttl"),
+		MaxTTL:          viper.GetDuration("payment_intents.max_ttl"),
+		BatchSize:       viper.GetInt("payment_intents.batch_size"),
+		CallbackTimeout: viper.GetDuration("payment_intents.callback_timeout"),
+		CallbackSecret:  viper.GetString("payment_intents.callback_secret"),
+		MaxPINAttempts:  viper.GetInt("payment_intents.max_pin_attempts"),
+		PINLockout:      viper.GetDuration("payment_intents.pin_lockout"),
+	})
+	moneyRequestUsecase := usecase.NewMoneyRequestUsecase(uow, moneyRequestRepo, limitsUsecase, feeUsecase, usecase.MoneyRequestConfig{
+		DefaultTTL: viper.GetDuration("money_requests.ttl"),
+		MaxTTL:     viper.GetDuration("money_requests.max_ttl"),
+		BatchSize:  viper.GetInt("money_requests.batch_size"),
+	})
+	billUsecase := usecase.NewBillUsecase(uow, billRepo, moneyRequestUsecase, transactionRepo)
+	authorizationUsecase := usecase.NewAuthorizationUsecase(uow, transactionRepo, merchantRepo, limitsUsecase, feeUsecase, usecase.AuthorizationConfig{
+		DefaultTTL: viper.GetDuration("payments.authorization_ttl"),
+		MaxTTL:     viper.GetDuration("payments.max_authorization_ttl"),
+		BatchSize:  viper.GetInt("payments.batch_size"),
+	})
+
+	// Load exchange rates shipped with the deployment
+	if path := viper.GetString("fx.rates_file"); path != "" {
+		rates, err := fxUsecase.LoadRatesFile(path)
+		if err != nil {
+			log.Fatalf("Failed to load exchange rates: %s", err)
+		}
+		log.Printf("Loaded %d exchange rates from %s", len(rates), path)
+	}
 
 	// Setup HTTP handler
 	handler := http.NewHandler(userUsecase, transactionUsecase)
+	notificationHandler := http.NewNotificationHandler(notificationUsecase)
+	adminHandler := http.NewAdminHandler(userUsecase, reconciliationUsecase)
+	fxHandler := http.NewFXHandler(fxUsecase)
+	pocketHandler := http.NewPocketHandler(pocketUsecase)
+	authorizationHandler := http.NewAuthorizationHandler(authorizationUsecase)
+	refundHandler := http.NewRefundHandler(refundUsecase)
+	scheduleHandler := http.NewScheduleHandler(scheduleUsecase)
+	limitsHandler := http.NewLimitsHandler(limitsUsecase)
+	feeHandler := http.NewFeeHandler(feeUsecase)
+	merchantHandler := http.NewMerchantHandler(merchantUsecase)
+	settlementHandler := http.NewSettlementHandler(settlementUsecase)
+	paymentIntentHandler := http.NewPaymentIntentHandler(paymentIntentUsecase)
+	moneyRequestHandler := http.NewMoneyRequestHandler(moneyRequestUsecase)
+	billHandler := http.NewBillHandler(billUsecase)
 
 	// Setup background worker for transfer processing
-	go func() {
-		err := queueService.Start(func(task *asynq.Task) error {
-			var payload queue.TransferPayload
-			if err := json.Unmarshal(task.Payload(), &payload); err != nil {
-				return err
-			}
-
-			return transactionUsecase.ProcessTransfer(
-				payload.TransactionID,
-				payload.FromUserID,
-				payload.ToUserID,
-				payload.Amount,
-			)
-		})
+	queueService.HandleFunc(queue.TaskTransfer, func(task *asynq.Task) error {
+		transactionID, err := transferTransactionID(task)
+		if err != nil {
+			return fmt.Errorf("%w: %s", asynq.SkipRetry, err)
+		}
+
+		return transactionUsecase.ProcessTransfer(transactionID)
+	})
+	queueService.HandleFailure(queue.TaskTransfer, func(task *asynq.Task, taskErr error) {
+		transactionID, err := transferTransactionID(task)
+		if err != nil {
+			log.Printf("Failed to read failed transfer task: %s", err)
+			return
+		}
+
+		if err := transactionUsecase.FailTransfer(transactionID, taskErr.Error()); err != nil {
+			log.Printf("Failed to mark transfer %s as failed: %s", transactionID, err)
+		}
+	})
+
+	// Setup periodic sweep of stuck pending transfers
+	queueService.HandleFunc(queue.TaskSweepPendingTransfers, func(task *asynq.Task) error {
+		report, err := transferSweeper.Sweep()
+		if err != nil {
+			return err
+		}
+		log.Printf("Pending transfer sweep: %s", report)
+
+		// Expired idempotency keys are only replaced when reused; purge the rest
+		purged, err := idempotencyRepo.DeleteExpired(time.Now())
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("Purged %d expired idempotency keys", purged)
+		}
+		return nil
+	})
+	if err := queueService.Schedule(viper.GetString("sweeper.schedule"), queue.TaskSweepPendingTransfers); err != nil {
+		log.Fatalf("Failed to schedule pending transfer sweep: %s", err)
+	}
+
+	// Setup periodic balance reconciliation
+	queueService.HandleFunc(queue.TaskReconcileBalances, func(task *asynq.Task) error {
+		run, err := reconciliationUsecase.Run()
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Balance reconciliation %s: users=%d discrepancies=%d", run.ID, run.UsersChecked, run.DiscrepancyCount)
+		return nil
+	})
+	if err := queueService.Schedule(viper.GetString("reconciliation.schedule"), queue.TaskReconcileBalances); err != nil {
+		log.Fatalf("Failed to schedule balance reconciliation: %s", err)
+	}
+
+	// Setup periodic release of expired payment authorizations
+	queueService.HandleFunc(queue.TaskExpireAuthorizations, func(task *asynq.Task) error {
+		expired, err := authorizationUsecase.ExpireAuthorizations()
+		if expired > 0 {
+			log.Printf("Expired %d payment authorizations", expired)
+		}
+		return err
+	})
+	if err := queueService.Schedule(viper.GetString("payments.expiry_schedule"), queue.TaskExpireAuthorizations); err != nil {
+		log.Fatalf("Failed to schedule authorization expiry: %s", err)
+	}
+
+	// Setup periodic expiry of payment intents and merchant callbacks
+	queueService.HandleFunc(queue.TaskExpirePaymentIntents, func(task *asynq.Task) error {
+		expired, err := paymentIntentUsecase.ExpireIntents()
+		if expired > 0 {
+			log.Printf("Expired %d payment intents", expired)
+		}
+		return err
+	})
+	if err := queueService.Schedule(viper.GetString("payment_intents.expiry_schedule"), queue.TaskExpirePaymentIntents); err != nil {
+		log.Fatalf("Failed to schedule payment intent expiry: %s", err)
+	}
+	queueService.HandleFunc(queue.TaskPaymentIntentCallback, func(task *asynq.Task) error {
+		var payload queue.PaymentIntentCallbackPayload
+		if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+			return fmt.Errorf("%w: %s", asynq.SkipRetry, err)
+		}
+		intentID, err := uuid.Parse(payload.PaymentIntentID)
 		if err != nil {
+			return fmt.Errorf("%w: %s", asynq.SkipRetry, err)
+		}
+
+		return paymentIntentUsecase.DeliverCallback(intentID)
+	})
+
+	// Setup periodic expiry of money requests
+	queueService.HandleFunc(queue.TaskExpireMoneyRequests, func(task *asynq.Task) error {
+		expired, err := moneyRequestUsecase.ExpireRequests()
+		if expired > 0 {
+			log.Printf("Expired %d money requests", expired)
+		}
+		return err
+	})
+	if err := queueService.Schedule(viper.GetString("money_requests.expiry_schedule"), queue.TaskExpireMoneyRequests); err != nil {
+		log.Fatalf("Failed to schedule money request expiry: %s", err)
+	}
+
+	// Setup periodic run of scheduled transfers
+	queueService.HandleFunc(queue.TaskRunTransferSchedules, func(task *asynq.Task) error {
+		runs, err := scheduleUsecase.RunDue()
+		if len(runs) > 0 {
+			log.Printf("Ran %d scheduled transfer occurrences", len(runs))
+		}
+		return err
+	})
+	if err := queueService.Schedule(viper.GetString("schedules.tick"), queue.TaskRunTransferSchedules); err != nil {
+		log.Fatalf("Failed to schedule transfer schedule runs: %s", err)
+	}
+
+	// Setup daily merchant settlement
+	queueService.HandleFunc(queue.TaskRunSettlements, func(task *asynq.Task) error {
+		batches, err := settlementUsecase.RunSettlements()
+		if len(batches) > 0 {
+			log.Printf("Created %d merchant settlements", len(batches))
+		}
+		return err
+	})
+	if err := queueService.Schedule(viper.GetString("settlements.schedule"), queue.TaskRunSettlements); err != nil {
+		log.Fatalf("Failed to schedule merchant settlements: %s", err)
+	}
+
+	go func() {
+		if err := queueService.Start(); err != nil {
 			log.Printf("Failed to start queue worker: %s", err)
 		}
 	}()
 
+	// Setup outbox relay publishing queued tasks
+	go outboxRelay.Run(context.Background(), viper.GetDuration("outbox.interval"))
+
 	// Setup Gin router
 	router := gin.Default()
 
@@ -106,12 +324,80 @@ func main() {
 	// Protected routes
 	protected := router.Group("")
 	protected.Use(middleware.AuthMiddleware(jwtService))
+	idempotent := middleware.IdempotencyMiddleware(idempotencyRepo, viper.GetDuration("idempotency.ttl"))
 	{
-		protected.POST("/topup", handler.TopUp)
-		protected.POST("/pay", handler.Payment)
-		protected.POST("/transfer", handler.Transfer)
+		protected.POST("/topup", idempotent, handler.TopUp)
+		protected.POST("/pay", idempotent, handler.Payment)
+		protected.POST("/transfer", idempotent, handler.Transfer)
+		protected.POST("/transfers/:id/cancel", handler.CancelTransfer)
+		protected.POST("/requests", idempotent, moneyRequestHandler.CreateMoneyRequest)
+		protected.GET("/requests/incoming", moneyRequestHandler.GetIncoming)
+		protected.GET("/requests/outgoing", moneyRequestHandler.GetOutgoing)
+		protected.GET("/requests/:id", moneyRequestHandler.GetMoneyRequest)
+		protected.POST("/requests/:id/accept", idempotent, moneyRequestHandler.AcceptMoneyRequest)
+		protected.POST("/requests/:id/decline", moneyRequestHandler.DeclineMoneyRequest)
+		protected.POST("/requests/:id/cancel", moneyRequestHandler.CancelMoneyRequest)
+		protected.POST("/bills", idempotent, billHandler.CreateBill)
+		protected.GET("/bills", billHandler.GetBills)
+		protected.GET("/bills/:id", billHandler.GetBill)
+		protected.POST("/schedules", idempotent, scheduleHandler.CreateSchedule)
+		protected.GET("/schedules", scheduleHandler.GetSchedules)
+		protected.GET("/schedules/:id/runs", scheduleHandler.GetRuns)
+		protected.POST("/schedules/:id/pause", scheduleHandler.PauseSchedule)
+		protected.POST("/schedules/:id/resume", scheduleHandler.ResumeSchedule)
+		protected.DELETE("/schedules/:id", scheduleHandler.DeleteSchedule)
+		protected.POST("/payments/authorize", idempotent, authorizationHandler.Authorize)
+		protected.POST("/payments/:id/capture", idempotent, authorizationHandler.Capture)
+		protected.POST("/payments/:id/void", authorizationHandler.Void)
 		protected.GET("/transactions", handler.GetTransactions)
+		protected.POST("/transactions/:id/refund", idempotent, refundHandler.Refund)
+		protected.GET("/balance", handler.GetBalance)
+		protected.GET("/limits", limitsHandler.GetLimits)
+		protected.GET("/fees/preview", feeHandler.PreviewFee)
+		protected.GET("/merchants", merchantHandler.GetMerchants)
+		protected.GET("/merchants/:id", merchantHandler.GetMerchant)
+		protected.GET("/merchants/:id/payments", merchantHandler.GetPayments)
+		protected.GET("/merchants/:id/settlements", settlementHandler.GetSettlements)
+		protected.POST("/merchants/:id/payment-intents", idempotent, paymentIntentHandler.CreatePaymentIntent)
+		protected.GET("/merchants/:id/payment-intents", paymentIntentHandler.GetMerchantPaymentIntents)
+		protected.GET("/payment-intents/:id", paymentIntentHandler.GetPaymentIntent)
+		protected.POST("/payment-intents/:id/open", paymentIntentHandler.OpenPaymentIntent)
+		protected.POST("/payment-intents/:id/confirm", idempotent, paymentIntentHandler.ConfirmPaymentIntent)
+		protected.POST("/payment-intents/:id/cancel", paymentIntentHandler.CancelPaymentIntent)
+		protected.GET("/merchants/:id/settlements/:settlementID/report", settlementHandler.GetReport)
 		protected.PUT("/profile", handler.UpdateProfile)
+		protected.GET("/notifications", notificationHandler.GetNotifications)
+		protected.POST("/notifications/:id/read", notificationHandler.MarkRead)
+		protected.GET("/fx/rates", fxHandler.ListRates)
+		protected.POST("/fx/quotes", fxHandler.CreateQuote)
+		protected.POST("/fx/convert", idempotent, fxHandler.Convert)
+		protected.POST("/pockets", pocketHandler.CreatePocket)
+		protected.GET("/pockets", pocketHandler.GetPockets)
+		protected.POST("/pockets/:id/deposit", idempotent, pocketHandler.Deposit)
+		protected.POST("/pockets/:id/withdraw", idempotent, pocketHandler.Withdraw)
+		protected.DELETE("/pockets/:id", pocketHandler.DeletePocket)
+	}
+
+	// Admin routes
+	admin := protected.Group("/admin")
+	admin.Use(middleware.AdminMiddleware(userUsecase))
+	{
+		admin.POST("/reconciliations", adminHandler.RunReconciliation)
+		admin.GET("/reconciliations", adminHandler.ListReconciliations)
+		admin.GET("/reconciliations/:id", adminHandler.GetReconciliation)
+		admin.PUT("/users/:id/status", adminHandler.UpdateUserStatus)
+		admin.PUT("/users/:id/tier", limitsHandler.UpdateUserTier)
+		admin.PUT("/users/:id/kyc", limitsHandler.UpdateUserKYCLevel)
+		admin.GET("/users/:id/limits", limitsHandler.GetUserLimits)
+		admin.PUT("/users/:id/limits", limitsHandler.SetUserLimits)
+		admin.DELETE("/users/:id/limits/:currency", limitsHandler.DeleteUserLimits)
+		admin.POST("/merchants", merchantHandler.CreateMerchant)
+		admin.PUT("/merchants/:id/status", merchantHandler.UpdateMerchantStatus)
+		admin.POST("/settlements/run", settlementHandler.RunSettlements)
+		admin.POST("/settlements/:id/paid", settlementHandler.MarkPaid)
+		admin.GET("/settlements/:id/report", settlementHandler.GetAdminReport)
+		admin.PUT("/fx/rates", fxHandler.SetRates)
+		admin.POST("/transactions/:id/reverse", idempotent, refundHandler.Reverse)
 	}
 
 	// Start server
@@ -120,3 +406,12 @@ func main() {
 		log.Fatalf("Failed to start server: %s", err)
 	}
 }
+
+func transferTransactionID(task *asynq.Task) (uuid.UUID, error) {
+	var payload queue.TransferPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return uuid.Nil, err
+	}
+
+	return uuid.Parse(payload.TransactionID)
+}
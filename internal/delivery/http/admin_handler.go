@@ -0,0 +1,113 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AdminHandler struct {
+	userUsecase           *usecase.UserUsecase
+	reconciliationUsecase *usecase.ReconciliationUsecase
+}
+
+func NewAdminHandler(userUsecase *usecase.UserUsecase, reconciliationUsecase *usecase.ReconciliationUsecase) *AdminHandler {
+	return &AdminHandler{
+		userUsecase:           userUsecase,
+		reconciliationUsecase: reconciliationUsecase,
+	}
+}
+
+type UpdateUserStatusRequest struct {
+	Status domain.UserStatus `json:"status" binding:"required"`
+}
+
+func (h *AdminHandler) RunReconciliation(c *gin.Context) {
+	run, err := h.reconciliationUsecase.Run()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": run,
+	})
+}
+
+func (h *AdminHandler) ListReconciliations(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	runs, err := h.reconciliationUsecase.ListRuns(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": runs,
+	})
+}
+
+func (h *AdminHandler) GetReconciliation(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid reconciliation run ID"})
+		return
+	}
+
+	run, err := h.reconciliationUsecase.GetRun(runID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "reconciliation run not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": run,
+	})
+}
+
+func (h *AdminHandler) UpdateUserStatus(c *gin.Context) {
+	var req UpdateUserStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	user, err := h.userUsecase.UpdateStatus(userID, req.Status)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": user,
+	})
+}
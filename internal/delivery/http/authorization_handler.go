@@ -0,0 +1,158 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/usecase"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AuthorizationHandler struct {
+	authorizationUsecase *usecase.AuthorizationUsecase
+}
+
+func NewAuthorizationHandler(authorizationUsecase *usecase.AuthorizationUsecase) *AuthorizationHandler {
+	return &AuthorizationHandler{authorizationUsecase: authorizationUsecase}
+}
+
+// MerchantID is the merchant being paid; only its owner can capture or void
+// the authorization. ExpiresIn is a Go duration such as "30m" or "72h"; when
+// empty the configured default is used.
+type AuthorizeRequest struct {
+	MerchantID string      `json:"merchant_id" binding:"required"`
+	Amount     json.Number `json:"amount" binding:"required"`
+	Currency   string      `json:"currency"`
+	Remarks    string      `json:"remarks" binding:"required"`
+	ExpiresIn  string      `json:"expires_in"`
+}
+
+// An empty Amount captures the whole authorization.
+type CaptureRequest struct {
+	Amount json.Number `json:"amount"`
+}
+
+func (h *AuthorizationHandler) Authorize(c *gin.Context) {
+	var req AuthorizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	amount, err := parseAmount(req.Amount, req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	merchantID, err := uuid.Parse(req.MerchantID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid merchant ID"})
+		return
+	}
+
+	var ttl time.Duration
+	if req.ExpiresIn != "" {
+		ttl, err = time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expires_in"})
+			return
+		}
+	}
+
+	userID, _ := c.Get("user_id")
+	auth, err := h.authorizationUsecase.Authorize(userID.(uuid.UUID), merchantID, amount, req.Remarks, ttl)
+	if limitExceeded(c, err) {
+		return
+	}
+	if err != nil {
+		authorizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": auth,
+	})
+}
+
+func (h *AuthorizationHandler) Capture(c *gin.Context) {
+	authorizationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid authorization ID"})
+		return
+	}
+
+	var req CaptureRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var amount money.Money
+	if req.Amount != "" {
+		auth, err := h.authorizationUsecase.GetAuthorization(userID.(uuid.UUID), authorizationID)
+		if err != nil {
+			authorizationError(c, err)
+			return
+		}
+
+		amount, err = parseAmount(req.Amount, auth.Amount.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	payment, err := h.authorizationUsecase.Capture(userID.(uuid.UUID), authorizationID, amount)
+	if err != nil {
+		authorizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": payment,
+	})
+}
+
+func (h *AuthorizationHandler) Void(c *gin.Context) {
+	authorizationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid authorization ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	auth, err := h.authorizationUsecase.Void(userID.(uuid.UUID), authorizationID)
+	if err != nil {
+		authorizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": auth,
+	})
+}
+
+func authorizationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, usecase.ErrAuthorizationNotFound), errors.Is(err, usecase.ErrMerchantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrAuthorizationClosed), errors.Is(err, usecase.ErrAuthorizationExpired):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrMerchantSuspended):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
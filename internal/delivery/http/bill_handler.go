@@ -0,0 +1,157 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type BillHandler struct {
+	billUsecase *usecase.BillUsecase
+}
+
+func NewBillHandler(billUsecase *usecase.BillUsecase) *BillHandler {
+	return &BillHandler{billUsecase: billUsecase}
+}
+
+// CreateBillRequest splits Amount between Participants. Each participant
+// gives an amount for EXACT bills and a percentage such as "33.33" for
+// PERCENTAGE bills. The creator may be one of the participants.
+type CreateBillRequest struct {
+	Title        string                   `json:"title" binding:"required"`
+	Amount       json.Number              `json:"amount" binding:"required"`
+	Currency     string                   `json:"currency"`
+	SplitMode    domain.SplitMode         `json:"split_mode" binding:"required"`
+	Participants []BillParticipantRequest `json:"participants" binding:"required,min=1,dive"`
+}
+
+type BillParticipantRequest struct {
+	UserID     string      `json:"user_id" binding:"required"`
+	Amount     json.Number `json:"amount"`
+	Percentage json.Number `json:"percentage"`
+}
+
+func (h *BillHandler) CreateBill(c *gin.Context) {
+	var req CreateBillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	total, err := parseAmount(req.Amount, req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	shares := make([]usecase.BillShare, 0, len(req.Participants))
+	for _, p := range req.Participants {
+		userID, err := uuid.Parse(p.UserID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid participant user ID"})
+			return
+		}
+		share := usecase.BillShare{UserID: userID}
+
+		switch req.SplitMode {
+		case domain.SplitModeExact:
+			share.Amount, err = parseAmount(p.Amount, req.Currency)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		case domain.SplitModePercentage:
+			share.ShareBps, err = parsePercentage(p.Percentage)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		shares = append(shares, share)
+	}
+
+	userID, _ := c.Get("user_id")
+	bill, err := h.billUsecase.Create(userID.(uuid.UUID), usecase.BillInput{
+		Title:        req.Title,
+		Total:        total,
+		SplitMode:    req.SplitMode,
+		Participants: shares,
+	})
+	if errors.Is(err, usecase.ErrPayerNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": bill,
+	})
+}
+
+func (h *BillHandler) GetBills(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	bills, err := h.billUsecase.GetBills(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": bills,
+	})
+}
+
+func (h *BillHandler) GetBill(c *gin.Context) {
+	billID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bill ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	bill, err := h.billUsecase.Get(userID.(uuid.UUID), billID)
+	if errors.Is(err, usecase.ErrBillNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": bill,
+	})
+}
+
+// parsePercentage reads a percentage with at most two decimals, such as
+// "12.5", as basis points.
+func parsePercentage(percentage json.Number) (int64, error) {
+	invalid := errors.New("percentage must be a number between 0 and 100 with at most two decimals")
+
+	whole, fraction, _ := strings.Cut(percentage.String(), ".")
+	if len(fraction) > 2 {
+		return 0, invalid
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+
+	bps, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil || bps <= 0 || bps > 10000 {
+		return 0, invalid
+	}
+	return bps, nil
+}
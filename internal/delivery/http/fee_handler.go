@@ -0,0 +1,50 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bangadam/wallet-api/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type FeeHandler struct {
+	feeUsecase *usecase.FeeUsecase
+}
+
+func NewFeeHandler(feeUsecase *usecase.FeeUsecase) *FeeHandler {
+	return &FeeHandler{feeUsecase: feeUsecase}
+}
+
+// PreviewFee answers GET /fees/preview?type=TRANSFER&amount=150000&currency=IDR
+// with what the transaction would cost. promo_code is optional.
+func (h *FeeHandler) PreviewFee(c *gin.Context) {
+	if c.Query("type") == "" || c.Query("amount") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type and amount are required"})
+		return
+	}
+
+	amount, err := parseAmount(json.Number(c.Query("amount")), c.Query("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	fee, err := h.feeUsecase.Preview(userID.(uuid.UUID), c.Query("type"), amount, c.Query("promo_code"))
+	if errors.Is(err, usecase.ErrInvalidPromoCode) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": fee,
+	})
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bangadam/wallet-api/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type FXHandler struct {
+	fxUsecase *usecase.FXUsecase
+}
+
+func NewFXHandler(fxUsecase *usecase.FXUsecase) *FXHandler {
+	return &FXHandler{fxUsecase: fxUsecase}
+}
+
+type SetRatesRequest struct {
+	Rates []usecase.FXRateInput `json:"rates" binding:"required"`
+}
+
+type QuoteRequest struct {
+	Amount         json.Number `json:"amount" binding:"required"`
+	Currency       string      `json:"currency"`
+	TargetCurrency string      `json:"target_currency" binding:"required"`
+}
+
+type ConvertRequest struct {
+	QuoteID string `json:"quote_id" binding:"required"`
+}
+
+func (h *FXHandler) ListRates(c *gin.Context) {
+	rates, err := h.fxUsecase.ListRates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": rates,
+	})
+}
+
+func (h *FXHandler) SetRates(c *gin.Context) {
+	var req SetRatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rates, err := h.fxUsecase.SetRates(req.Rates)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": rates,
+	})
+}
+
+func (h *FXHandler) CreateQuote(c *gin.Context) {
+	var req QuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	amount, err := parseAmount(req.Amount, req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	quote, err := h.fxUsecase.Quote(userID.(uuid.UUID), amount, req.TargetCurrency)
+	if errors.Is(err, usecase.ErrRateUnavailable) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": quote,
+	})
+}
+
+func (h *FXHandler) Convert(c *gin.Context) {
+	var req ConvertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quoteID, err := uuid.Parse(req.QuoteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid quote ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	conversion, err := h.fxUsecase.Convert(userID.(uuid.UUID), quoteID)
+	if errors.Is(err, usecase.ErrQuoteNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": conversion,
+	})
+}
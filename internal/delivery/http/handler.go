@@ -1,9 +1,13 @@
 package http
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 
+	"github.com/bangadam/wallet-api/internal/domain"
 	"github.com/bangadam/wallet-api/internal/usecase"
+	"github.com/bangadam/wallet-api/pkg/money"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -33,19 +37,40 @@ type LoginRequest struct {
 	Pin         string `json:"pin" binding:"required"`
 }
 
+// Amounts are accepted as JSON numbers or decimal strings and are parsed
+// exactly into minor units; Currency defaults to domain.DefaultCurrency.
+// PromoCode optionally waives the fee of a top-up, payment or transfer.
 type TopUpRequest struct {
-	Amount float64 `json:"amount" binding:"required,gt=0"`
+	Amount    json.Number `json:"amount" binding:"required"`
+	Currency  string      `json:"currency"`
+	PromoCode string      `json:"promo_code"`
 }
 
+// PocketID optionally pays or sends from one of the user's pockets.
+// MerchantID optionally pays a merchant, with the merchant's OrderReference.
 type PaymentRequest struct {
-	Amount  float64 `json:"amount" binding:"required,gt=0"`
-	Remarks string  `json:"remarks" binding:"required"`
+	Amount         json.Number `json:"amount" binding:"required"`
+	Currency       string      `json:"currency"`
+	PocketID       string      `json:"pocket_id"`
+	PromoCode      string      `json:"promo_code"`
+	MerchantID     string      `json:"merchant_id"`
+	OrderReference string      `json:"order_reference"`
+	Remarks        string      `json:"remarks" binding:"required"`
 }
 
+// TargetCurrency is the currency the recipient receives. Moving money across
+// currencies must be asked for explicitly with Convert and a QuoteID from
+// POST /fx/quotes.
 type TransferRequest struct {
-	TargetUser string  `json:"target_user" binding:"required"`
-	Amount     float64 `json:"amount" binding:"required,gt=0"`
-	Remarks    string  `json:"remarks" binding:"required"`
+	TargetUser     string      `json:"target_user" binding:"required"`
+	Amount         json.Number `json:"amount" binding:"required"`
+	Currency       string      `json:"currency"`
+	TargetCurrency string      `json:"target_currency"`
+	Convert        bool        `json:"convert"`
+	QuoteID        string      `json:"quote_id"`
+	PocketID       string      `json:"pocket_id"`
+	PromoCode      string      `json:"promo_code"`
+	Remarks        string      `json:"remarks" binding:"required"`
 }
 
 type UpdateProfileRequest struct {
@@ -102,8 +127,23 @@ func (h *Handler) TopUp(c *gin.Context) {
 		return
 	}
 
+	amount, err := parseAmount(req.Amount, req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	userID, _ := c.Get("user_id")
-	tx, err := h.transactionUsecase.TopUp(userID.(uuid.UUID), req.Amount)
+	tx, err := h.transactionUsecase.TopUp(userID.(uuid.UUID), amount, usecase.TopUpOptions{
+		PromoCode: req.PromoCode,
+	})
+	if limitExceeded(c, err) {
+		return
+	}
+	if errors.Is(err, usecase.ErrInvalidPromoCode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -122,13 +162,39 @@ func (h *Handler) Payment(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("user_id")
-	tx, err := h.transactionUsecase.Payment(userID.(uuid.UUID), req.Amount, req.Remarks)
+	amount, err := parseAmount(req.Amount, req.Currency)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	pocketID, err := parseOptionalID(req.PocketID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pocket ID"})
+		return
+	}
+
+	merchantID, err := parseOptionalID(req.MerchantID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid merchant ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	tx, err := h.transactionUsecase.Payment(userID.(uuid.UUID), amount, req.Remarks, usecase.PaymentOptions{
+		PocketID:       pocketID,
+		PromoCode:      req.PromoCode,
+		MerchantID:     merchantID,
+		OrderReference: req.OrderReference,
+	})
+	if limitExceeded(c, err) {
+		return
+	}
+	if err != nil {
+		transactionError(c, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": "SUCCESS",
 		"result": tx,
@@ -148,13 +214,68 @@ func (h *Handler) Transfer(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("user_id")
-	tx, err := h.transactionUsecase.Transfer(userID.(uuid.UUID), targetUserID, req.Amount, req.Remarks)
+	amount, err := parseAmount(req.Amount, req.Currency)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	quoteID, err := parseOptionalID(req.QuoteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid quote ID"})
+		return
+	}
+
+	pocketID, err := parseOptionalID(req.PocketID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pocket ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	tx, err := h.transactionUsecase.Transfer(userID.(uuid.UUID), targetUserID, amount, req.Remarks, usecase.TransferOptions{
+		TargetCurrency: req.TargetCurrency,
+		Convert:        req.Convert,
+		QuoteID:        quoteID,
+		PocketID:       pocketID,
+		PromoCode:      req.PromoCode,
+	})
+	if limitExceeded(c, err) {
+		return
+	}
+	if err != nil {
+		transactionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": tx,
+	})
+}
+
+func (h *Handler) CancelTransfer(c *gin.Context) {
+	transactionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	tx, err := h.transactionUsecase.CancelTransfer(userID.(uuid.UUID), transactionID)
+	if errors.Is(err, usecase.ErrTransactionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, usecase.ErrTransferNotPending) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": "SUCCESS",
 		"result": tx,
@@ -175,6 +296,22 @@ func (h *Handler) GetTransactions(c *gin.Context) {
 	})
 }
 
+func (h *Handler) GetBalance(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	balances, err := h.transactionUsecase.GetBalances(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": gin.H{
+			"wallets": balances,
+		},
+	})
+}
+
 func (h *Handler) UpdateProfile(c *gin.Context) {
 	var req UpdateProfileRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -194,3 +331,72 @@ func (h *Handler) UpdateProfile(c *gin.Context) {
 		"result": user,
 	})
 }
+
+func parseAmount(amount json.Number, currency string) (money.Money, error) {
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
+
+	m, err := money.Parse(amount.String(), currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if !m.IsPositive() {
+		return money.Money{}, errors.New("amount must be greater than zero")
+	}
+
+	return m, nil
+}
+
+// limitExceeded answers 422 with the limit a request hit and reports whether
+// err was a LimitExceededError.
+func limitExceeded(c *gin.Context, err error) bool {
+	var limitErr *usecase.LimitExceededError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error": limitErr.Error(),
+		"limit": limitErr,
+	})
+	return true
+}
+
+// transactionError answers the errors a payment or transfer is refused with.
+// Anything else is a server error, which the idempotency middleware does not
+// store, so the request can be retried with the same key.
+func transactionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, usecase.ErrMerchantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrInsufficientBalance),
+		errors.Is(err, usecase.ErrWalletFrozen),
+		errors.Is(err, usecase.ErrRecipientNotFound),
+		errors.Is(err, usecase.ErrRecipientBalanceCap),
+		errors.Is(err, usecase.ErrSelfTransfer),
+		errors.Is(err, usecase.ErrCrossCurrency),
+		errors.Is(err, usecase.ErrInvalidPromoCode),
+		errors.Is(err, usecase.ErrPocketNotFound),
+		errors.Is(err, usecase.ErrMerchantSuspended),
+		errors.Is(err, usecase.ErrRateUnavailable),
+		errors.Is(err, usecase.ErrQuoteNotFound),
+		errors.Is(err, usecase.ErrQuoteExpired),
+		errors.Is(err, usecase.ErrQuoteUsed),
+		errors.Is(err, usecase.ErrQuoteRequired),
+		errors.Is(err, usecase.ErrQuoteMismatch),
+		errors.Is(err, money.ErrCurrencyMismatch),
+		errors.Is(err, money.ErrOverflow):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// parseOptionalID parses an optional ID field; an empty one is uuid.Nil.
+func parseOptionalID(id string) (uuid.UUID, error) {
+	if id == "" {
+		return uuid.Nil, nil
+	}
+	return uuid.Parse(id)
+}
@@ -0,0 +1,159 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type LimitsHandler struct {
+	limitsUsecase *usecase.LimitsUsecase
+}
+
+func NewLimitsHandler(limitsUsecase *usecase.LimitsUsecase) *LimitsHandler {
+	return &LimitsHandler{limitsUsecase: limitsUsecase}
+}
+
+type UpdateTierRequest struct {
+	Tier string `json:"tier" binding:"required"`
+}
+
+type UpdateKYCLevelRequest struct {
+	KYCLevel domain.KYCLevel `json:"kyc_level" binding:"required"`
+}
+
+func (h *LimitsHandler) GetLimits(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	h.respondLimits(c, userID.(uuid.UUID))
+}
+
+func (h *LimitsHandler) GetUserLimits(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	h.respondLimits(c, userID)
+}
+
+func (h *LimitsHandler) respondLimits(c *gin.Context, userID uuid.UUID) {
+	limits, err := h.limitsUsecase.GetLimits(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": limits,
+	})
+}
+
+func (h *LimitsHandler) SetUserLimits(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req usecase.LimitInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := c.Get("user_id")
+	if _, err := h.limitsUsecase.SetOverride(adminID.(uuid.UUID), userID, req); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.respondLimits(c, userID)
+}
+
+func (h *LimitsHandler) DeleteUserLimits(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	if err := h.limitsUsecase.DeleteOverride(userID, c.Param("currency")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.respondLimits(c, userID)
+}
+
+func (h *LimitsHandler) UpdateUserTier(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req UpdateTierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.limitsUsecase.SetTier(userID, req.Tier)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": user,
+	})
+}
+
+func (h *LimitsHandler) UpdateUserKYCLevel(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req UpdateKYCLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.limitsUsecase.SetKYCLevel(userID, req.KYCLevel)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": user,
+	})
+}
@@ -0,0 +1,170 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type MerchantHandler struct {
+	merchantUsecase *usecase.MerchantUsecase
+}
+
+func NewMerchantHandler(merchantUsecase *usecase.MerchantUsecase) *MerchantHandler {
+	return &MerchantHandler{merchantUsecase: merchantUsecase}
+}
+
+// CreateMerchantRequest onboards a merchant managed by the user OwnerID.
+// Currency defaults to domain.DefaultCurrency.
+type CreateMerchantRequest struct {
+	OwnerID                 string `json:"owner_id" binding:"required"`
+	Name                    string `json:"name" binding:"required"`
+	Currency                string `json:"currency"`
+	SettlementBankCode      string `json:"settlement_bank_code" binding:"required"`
+	SettlementAccountNumber string `json:"settlement_account_number" binding:"required"`
+	SettlementAccountName   string `json:"settlement_account_name" binding:"required"`
+}
+
+type UpdateMerchantStatusRequest struct {
+	Status domain.MerchantStatus `json:"status" binding:"required"`
+}
+
+func (h *MerchantHandler) CreateMerchant(c *gin.Context) {
+	var req CreateMerchantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ownerID, err := uuid.Parse(req.OwnerID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid owner ID"})
+		return
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
+
+	merchant, err := h.merchantUsecase.Create(usecase.MerchantInput{
+		OwnerID:                 ownerID,
+		Name:                    req.Name,
+		Currency:                currency,
+		SettlementBankCode:      req.SettlementBankCode,
+		SettlementAccountNumber: req.SettlementAccountNumber,
+		SettlementAccountName:   req.SettlementAccountName,
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "owner not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": merchant,
+	})
+}
+
+func (h *MerchantHandler) UpdateMerchantStatus(c *gin.Context) {
+	var req UpdateMerchantStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	merchantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid merchant ID"})
+		return
+	}
+
+	err = h.merchantUsecase.UpdateStatus(merchantID, req.Status)
+	if errors.Is(err, usecase.ErrMerchantNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": gin.H{
+			"merchant_id": merchantID,
+			"status":      req.Status,
+		},
+	})
+}
+
+func (h *MerchantHandler) GetMerchants(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	merchants, err := h.merchantUsecase.GetMerchants(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": merchants,
+	})
+}
+
+func (h *MerchantHandler) GetMerchant(c *gin.Context) {
+	merchantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid merchant ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	merchant, err := h.merchantUsecase.GetMerchant(userID.(uuid.UUID), merchantID)
+	if errors.Is(err, usecase.ErrMerchantNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": merchant,
+	})
+}
+
+func (h *MerchantHandler) GetPayments(c *gin.Context) {
+	merchantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid merchant ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	payments, err := h.merchantUsecase.GetPayments(userID.(uuid.UUID), merchantID)
+	if errors.Is(err, usecase.ErrMerchantNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": payments,
+	})
+}
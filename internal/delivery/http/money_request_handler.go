@@ -0,0 +1,245 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type MoneyRequestHandler struct {
+	moneyRequestUsecase *usecase.MoneyRequestUsecase
+}
+
+func NewMoneyRequestHandler(moneyRequestUsecase *usecase.MoneyRequestUsecase) *MoneyRequestHandler {
+	return &MoneyRequestHandler{moneyRequestUsecase: moneyRequestUsecase}
+}
+
+// CreateMoneyRequestRequest asks the user Payer for an amount. ExpiresIn is
+// a Go duration such as "48h" and defaults to the configured TTL.
+type CreateMoneyRequestRequest struct {
+	Payer     string      `json:"payer" binding:"required"`
+	Amount    json.Number `json:"amount" binding:"required"`
+	Currency  string      `json:"currency"`
+	Note      string      `json:"note" binding:"required"`
+	ExpiresIn string      `json:"expires_in"`
+}
+
+type AcceptMoneyRequestRequest struct {
+	PocketID  string `json:"pocket_id"`
+	PromoCode string `json:"promo_code"`
+}
+
+type DeclineMoneyRequestRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (h *MoneyRequestHandler) CreateMoneyRequest(c *gin.Context) {
+	var req CreateMoneyRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payerID, err := uuid.Parse(req.Payer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payer ID"})
+		return
+	}
+
+	amount, err := parseAmount(req.Amount, req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var ttl time.Duration
+	if req.ExpiresIn != "" {
+		ttl, err = time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expires_in"})
+			return
+		}
+	}
+
+	userID, _ := c.Get("user_id")
+	request, err := h.moneyRequestUsecase.Create(userID.(uuid.UUID), payerID, amount, req.Note, ttl)
+	if moneyRequestError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": request,
+	})
+}
+
+func (h *MoneyRequestHandler) GetIncoming(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	requests, err := h.moneyRequestUsecase.GetIncoming(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": requests,
+	})
+}
+
+func (h *MoneyRequestHandler) GetOutgoing(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	requests, err := h.moneyRequestUsecase.GetOutgoing(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": requests,
+	})
+}
+
+func (h *MoneyRequestHandler) GetMoneyRequest(c *gin.Context) {
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid money request ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	request, err := h.moneyRequestUsecase.Get(userID.(uuid.UUID), requestID)
+	if moneyRequestError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": request,
+	})
+}
+
+func (h *MoneyRequestHandler) AcceptMoneyRequest(c *gin.Context) {
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid money request ID"})
+		return
+	}
+
+	var req AcceptMoneyRequestRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	pocketID, err := parseOptionalID(req.PocketID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pocket ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	request, tx, err := h.moneyRequestUsecase.Accept(userID.(uuid.UUID), requestID, usecase.TransferOptions{
+		PocketID:  pocketID,
+		PromoCode: req.PromoCode,
+	})
+	if limitExceeded(c, err) || moneyRequestError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": gin.H{
+			"request":     request,
+			"transaction": tx,
+		},
+	})
+}
+
+func (h *MoneyRequestHandler) DeclineMoneyRequest(c *gin.Context) {
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid money request ID"})
+		return
+	}
+
+	var req DeclineMoneyRequestRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userID, _ := c.Get("user_id")
+	request, err := h.moneyRequestUsecase.Decline(userID.(uuid.UUID), requestID, req.Reason)
+	if moneyRequestError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": request,
+	})
+}
+
+func (h *MoneyRequestHandler) CancelMoneyRequest(c *gin.Context) {
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid money request ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	request, err := h.moneyRequestUsecase.Cancel(userID.(uuid.UUID), requestID)
+	if moneyRequestError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": request,
+	})
+}
+
+// moneyRequestError writes the response for the money request errors that
+// have their own status code and reports whether it did.
+func moneyRequestError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, usecase.ErrMoneyRequestNotFound), errors.Is(err, usecase.ErrPayerNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrMoneyRequestClosed), errors.Is(err, usecase.ErrMoneyRequestExpired):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,242 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PaymentIntentHandler struct {
+	paymentIntentUsecase *usecase.PaymentIntentUsecase
+}
+
+func NewPaymentIntentHandler(paymentIntentUsecase *usecase.PaymentIntentUsecase) *PaymentIntentHandler {
+	return &PaymentIntentHandler{paymentIntentUsecase: paymentIntentUsecase}
+}
+
+// CreatePaymentIntentRequest starts a checkout. Currency must be the
+// merchant's currency; ExpiresIn is a Go duration such as "15m" and defaults
+// to the configured TTL.
+type CreatePaymentIntentRequest struct {
+	Amount         json.Number `json:"amount" binding:"required"`
+	Currency       string      `json:"currency"`
+	OrderReference string      `json:"order_reference" binding:"required"`
+	Description    string      `json:"description"`
+	CallbackURL    string      `json:"callback_url"`
+	ExpiresIn      string      `json:"expires_in"`
+}
+
+type ConfirmPaymentIntentRequest struct {
+	Pin       string `json:"pin" binding:"required"`
+	PocketID  string `json:"pocket_id"`
+	PromoCode string `json:"promo_code"`
+}
+
+func (h *PaymentIntentHandler) CreatePaymentIntent(c *gin.Context) {
+	var req CreatePaymentIntentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	merchantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid merchant ID"})
+		return
+	}
+
+	amount, err := parseAmount(req.Amount, req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var ttl time.Duration
+	if req.ExpiresIn != "" {
+		ttl, err = time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expires_in"})
+			return
+		}
+	}
+
+	userID, _ := c.Get("user_id")
+	intent, err := h.paymentIntentUsecase.Create(userID.(uuid.UUID), merchantID, usecase.PaymentIntentInput{
+		Amount:         amount,
+		OrderReference: req.OrderReference,
+		Description:    req.Description,
+		CallbackURL:    req.CallbackURL,
+		TTL:            ttl,
+	})
+	if paymentIntentError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": intent,
+	})
+}
+
+func (h *PaymentIntentHandler) GetMerchantPaymentIntents(c *gin.Context) {
+	merchantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid merchant ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	intents, err := h.paymentIntentUsecase.GetIntents(userID.(uuid.UUID), merchantID)
+	if paymentIntentError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": intents,
+	})
+}
+
+func (h *PaymentIntentHandler) GetPaymentIntent(c *gin.Context) {
+	intentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payment intent ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	intent, err := h.paymentIntentUsecase.Get(userID.(uuid.UUID), intentID)
+	if paymentIntentError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": intent,
+	})
+}
+
+func (h *PaymentIntentHandler) OpenPaymentIntent(c *gin.Context) {
+	intentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payment intent ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	intent, err := h.paymentIntentUsecase.Open(userID.(uuid.UUID), intentID)
+	if paymentIntentError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": intent,
+	})
+}
+
+func (h *PaymentIntentHandler) ConfirmPaymentIntent(c *gin.Context) {
+	var req ConfirmPaymentIntentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	intentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payment intent ID"})
+		return
+	}
+
+	pocketID, err := parseOptionalID(req.PocketID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pocket ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	intent, tx, err := h.paymentIntentUsecase.Confirm(userID.(uuid.UUID), intentID, req.Pin, usecase.PaymentOptions{
+		PocketID:  pocketID,
+		PromoCode: req.PromoCode,
+	})
+	if limitExceeded(c, err) || paymentIntentError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": gin.H{
+			"payment_intent": intent,
+			"transaction":    tx,
+		},
+	})
+}
+
+func (h *PaymentIntentHandler) CancelPaymentIntent(c *gin.Context) {
+	intentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payment intent ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	intent, err := h.paymentIntentUsecase.Cancel(userID.(uuid.UUID), intentID)
+	if paymentIntentError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": intent,
+	})
+}
+
+// paymentIntentError writes the response for the payment intent errors that
+// have their own status code and reports whether it did.
+func paymentIntentError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, usecase.ErrPaymentIntentNotFound), errors.Is(err, usecase.ErrMerchantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrPaymentIntentClosed),
+		errors.Is(err, usecase.ErrPaymentIntentExpired),
+		errors.Is(err, usecase.ErrPaymentIntentTaken),
+		errors.Is(err, usecase.ErrPaymentIntentNotOpened):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrInvalidPIN), errors.Is(err, usecase.ErrTooManyPINAttempts):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrMerchantSuspended):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
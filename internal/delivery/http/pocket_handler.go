@@ -0,0 +1,150 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/internal/usecase"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PocketHandler struct {
+	pocketUsecase *usecase.PocketUsecase
+}
+
+func NewPocketHandler(pocketUsecase *usecase.PocketUsecase) *PocketHandler {
+	return &PocketHandler{pocketUsecase: pocketUsecase}
+}
+
+type CreatePocketRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Currency string `json:"currency"`
+}
+
+type PocketMoveRequest struct {
+	Amount json.Number `json:"amount" binding:"required"`
+}
+
+func (h *PocketHandler) CreatePocket(c *gin.Context) {
+	var req CreatePocketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
+
+	userID, _ := c.Get("user_id")
+	pocket, err := h.pocketUsecase.Create(userID.(uuid.UUID), req.Name, currency)
+	if errors.Is(err, usecase.ErrPocketExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": pocket,
+	})
+}
+
+func (h *PocketHandler) GetPockets(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	pockets, err := h.pocketUsecase.GetPockets(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": pockets,
+	})
+}
+
+func (h *PocketHandler) Deposit(c *gin.Context) {
+	h.move(c, h.pocketUsecase.Deposit)
+}
+
+func (h *PocketHandler) Withdraw(c *gin.Context) {
+	h.move(c, h.pocketUsecase.Withdraw)
+}
+
+func (h *PocketHandler) move(c *gin.Context, move func(userID, pocketID uuid.UUID, amount money.Money) (*domain.Transaction, error)) {
+	pocketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pocket ID"})
+		return
+	}
+
+	var req PocketMoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	pocket, err := h.pocketUsecase.GetPocket(userID.(uuid.UUID), pocketID)
+	if errors.Is(err, usecase.ErrPocketNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	amount, err := parseAmount(req.Amount, pocket.Balance.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := move(userID.(uuid.UUID), pocketID, amount)
+	if errors.Is(err, usecase.ErrPocketNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": tx,
+	})
+}
+
+func (h *PocketHandler) DeletePocket(c *gin.Context) {
+	pocketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pocket ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	err = h.pocketUsecase.Delete(userID.(uuid.UUID), pocketID)
+	if errors.Is(err, usecase.ErrPocketNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+	})
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bangadam/wallet-api/internal/usecase"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type RefundHandler struct {
+	refundUsecase *usecase.RefundUsecase
+}
+
+func NewRefundHandler(refundUsecase *usecase.RefundUsecase) *RefundHandler {
+	return &RefundHandler{refundUsecase: refundUsecase}
+}
+
+// An empty Amount refunds everything not yet refunded.
+type RefundRequest struct {
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+	Reason   string      `json:"reason"`
+}
+
+type ReverseRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+func (h *RefundHandler) Refund(c *gin.Context) {
+	transactionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction ID"})
+		return
+	}
+
+	var req RefundRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var amount money.Money
+	if req.Amount != "" {
+		amount, err = parseAmount(req.Amount, req.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userID, _ := c.Get("user_id")
+	refund, err := h.refundUsecase.Refund(userID.(uuid.UUID), transactionID, amount, req.Reason)
+	if err != nil {
+		refundError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": refund,
+	})
+}
+
+func (h *RefundHandler) Reverse(c *gin.Context) {
+	transactionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction ID"})
+		return
+	}
+
+	var req ReverseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	refund, err := h.refundUsecase.Reverse(transactionID, req.Reason)
+	if err != nil {
+		refundError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": refund,
+	})
+}
+
+func refundError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, usecase.ErrTransactionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrRefundForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrInsufficientBalance):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,166 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ScheduleHandler struct {
+	scheduleUsecase *usecase.ScheduleUsecase
+}
+
+func NewScheduleHandler(scheduleUsecase *usecase.ScheduleUsecase) *ScheduleHandler {
+	return &ScheduleHandler{scheduleUsecase: scheduleUsecase}
+}
+
+// Dates are RFC 3339 timestamps. Frequency is ONCE (the default), DAILY,
+// WEEKLY or MONTHLY; a recurring schedule runs until EndDate or until
+// MaxOccurrences transfers have been attempted.
+type CreateScheduleRequest struct {
+	TargetUser     string                   `json:"target_user" binding:"required"`
+	Amount         json.Number              `json:"amount" binding:"required"`
+	Currency       string                   `json:"currency"`
+	Remarks        string                   `json:"remarks" binding:"required"`
+	Frequency      domain.ScheduleFrequency `json:"frequency"`
+	StartDate      time.Time                `json:"start_date" binding:"required"`
+	EndDate        *time.Time               `json:"end_date"`
+	MaxOccurrences int                      `json:"max_occurrences"`
+}
+
+func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
+	var req CreateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	targetUserID, err := uuid.Parse(req.TargetUser)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target user ID"})
+		return
+	}
+
+	amount, err := parseAmount(req.Amount, req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	schedule, err := h.scheduleUsecase.Create(userID.(uuid.UUID), usecase.ScheduleInput{
+		TargetUserID:   targetUserID,
+		Amount:         amount,
+		Remarks:        req.Remarks,
+		Frequency:      req.Frequency,
+		StartAt:        req.StartDate,
+		EndAt:          req.EndDate,
+		MaxOccurrences: req.MaxOccurrences,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": schedule,
+	})
+}
+
+func (h *ScheduleHandler) GetSchedules(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	schedules, err := h.scheduleUsecase.GetSchedules(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": schedules,
+	})
+}
+
+func (h *ScheduleHandler) GetRuns(c *gin.Context) {
+	scheduleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	runs, err := h.scheduleUsecase.GetRuns(userID.(uuid.UUID), scheduleID)
+	if err != nil {
+		scheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": runs,
+	})
+}
+
+func (h *ScheduleHandler) PauseSchedule(c *gin.Context) {
+	h.update(c, h.scheduleUsecase.Pause)
+}
+
+func (h *ScheduleHandler) ResumeSchedule(c *gin.Context) {
+	h.update(c, h.scheduleUsecase.Resume)
+}
+
+func (h *ScheduleHandler) update(c *gin.Context, update func(userID, scheduleID uuid.UUID) (*domain.TransferSchedule, error)) {
+	scheduleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	schedule, err := update(userID.(uuid.UUID), scheduleID)
+	if err != nil {
+		scheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": schedule,
+	})
+}
+
+func (h *ScheduleHandler) DeleteSchedule(c *gin.Context) {
+	scheduleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := h.scheduleUsecase.Delete(userID.(uuid.UUID), scheduleID); err != nil {
+		scheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+	})
+}
+
+func scheduleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, usecase.ErrScheduleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrScheduleInactive):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
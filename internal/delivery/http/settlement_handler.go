@@ -0,0 +1,142 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bangadam/wallet-api/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SettlementHandler struct {
+	settlementUsecase *usecase.SettlementUsecase
+}
+
+func NewSettlementHandler(settlementUsecase *usecase.SettlementUsecase) *SettlementHandler {
+	return &SettlementHandler{settlementUsecase: settlementUsecase}
+}
+
+func (h *SettlementHandler) RunSettlements(c *gin.Context) {
+	batches, err := h.settlementUsecase.RunSettlements()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": batches,
+	})
+}
+
+func (h *SettlementHandler) MarkPaid(c *gin.Context) {
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid settlement ID"})
+		return
+	}
+
+	batch, err := h.settlementUsecase.MarkPaid(batchID)
+	if errors.Is(err, usecase.ErrSettlementNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, usecase.ErrSettlementPaid) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": batch,
+	})
+}
+
+func (h *SettlementHandler) GetSettlements(c *gin.Context) {
+	merchantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid merchant ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	batches, err := h.settlementUsecase.GetBatches(userID.(uuid.UUID), merchantID)
+	if errors.Is(err, usecase.ErrMerchantNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": batches,
+	})
+}
+
+// GetReport downloads the report of a settlement of one of the user's
+// merchants.
+func (h *SettlementHandler) GetReport(c *gin.Context) {
+	merchantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid merchant ID"})
+		return
+	}
+	batchID, err := uuid.Parse(c.Param("settlementID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid settlement ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	batch, err := h.settlementUsecase.GetBatch(userID.(uuid.UUID), batchID)
+	if err == nil && batch.MerchantID != merchantID {
+		err = usecase.ErrSettlementNotFound
+	}
+	if errors.Is(err, usecase.ErrSettlementNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.writeReport(c, batchID)
+}
+
+// GetAdminReport downloads the report of any settlement.
+func (h *SettlementHandler) GetAdminReport(c *gin.Context) {
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid settlement ID"})
+		return
+	}
+
+	h.writeReport(c, batchID)
+}
+
+func (h *SettlementHandler) writeReport(c *gin.Context, batchID uuid.UUID) {
+	var report bytes.Buffer
+	err := h.settlementUsecase.WriteReport(&report, batchID)
+	if errors.Is(err, usecase.ErrSettlementNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="settlement-%s.csv"`, batchID))
+	c.Data(http.StatusOK, "text/csv", report.Bytes())
+}
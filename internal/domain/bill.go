@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+)
+
+type SplitMode string
+
+const (
+	SplitModeEqual      SplitMode = "EQUAL"
+	SplitModeExact      SplitMode = "EXACT"
+	SplitModePercentage SplitMode = "PERCENTAGE"
+)
+
+// Bill splits Total between its participants. Every participant other than
+// the creator is sent a money request for their share.
+type Bill struct {
+	ID        uuid.UUID   `gorm:"type:uuid;primary_key" json:"bill_id"`
+	CreatorID uuid.UUID   `gorm:"type:uuid;index" json:"creator_id"`
+	Title     string      `json:"title"`
+	Total     money.Money `gorm:"embedded;embeddedPrefix:total_" json:"total"`
+	SplitMode SplitMode   `json:"split_mode"`
+	CreatedAt time.Time   `json:"created_date"`
+
+	// Participants, PaidAmount and Settled are filled in when the bill is
+	// read; payment is derived from the participants' transfers.
+	Participants []BillParticipant `gorm:"-" json:"participants,omitempty"`
+	PaidAmount   money.Money       `gorm:"-" json:"paid_amount"`
+	Settled      bool              `gorm:"-" json:"settled"`
+}
+
+// BillParticipant is one user's share of a bill. ShareBps is the
+// participant's percentage in basis points for PERCENTAGE bills. The creator
+// has no request and their share counts as paid.
+type BillParticipant struct {
+	ID        uuid.UUID   `gorm:"type:uuid;primary_key" json:"participant_id"`
+	BillID    uuid.UUID   `gorm:"type:uuid;index" json:"bill_id"`
+	UserID    uuid.UUID   `gorm:"type:uuid;index" json:"user_id"`
+	Share     money.Money `gorm:"embedded;embeddedPrefix:share_" json:"share"`
+	ShareBps  int64       `json:"share_bps,omitempty"`
+	RequestID *uuid.UUID  `gorm:"type:uuid" json:"request_id,omitempty"`
+	Position  int         `json:"-"`
+
+	RequestStatus  MoneyRequestStatus `gorm:"-" json:"request_status,omitempty"`
+	TransferStatus TransactionStatus  `gorm:"-" json:"transfer_status,omitempty"`
+	Paid           bool               `gorm:"-" json:"paid"`
+}
+
+type BillRepository interface {
+	Create(bill *Bill) error
+	CreateParticipants(participants []BillParticipant) error
+	GetByID(id uuid.UUID) (*Bill, error)
+	GetParticipants(billID uuid.UUID) ([]BillParticipant, error)
+	// GetByUserID returns the bills userID created or takes part in, newest
+	// first.
+	GetByUserID(userID uuid.UUID) ([]Bill, error)
+}
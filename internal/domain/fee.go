@@ -0,0 +1,10 @@
+package domain
+
+// FeeType is the kind of money movement a fee rule applies to.
+type FeeType string
+
+const (
+	FeeTypeTopUp    FeeType = "TOP_UP"
+	FeeTypePayment  FeeType = "PAYMENT"
+	FeeTypeTransfer FeeType = "TRANSFER"
+)
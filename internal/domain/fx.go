@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+)
+
+// FXRate is the mid-market price of one unit of BaseCurrency in
+// QuoteCurrency. Rates are kept as decimal strings so that they never pass
+// through floating point.
+type FXRate struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key" json:"-"`
+	BaseCurrency  string    `gorm:"size:3;uniqueIndex:idx_fx_rates_pair" json:"base_currency"`
+	QuoteCurrency string    `gorm:"size:3;uniqueIndex:idx_fx_rates_pair" json:"quote_currency"`
+	Rate          string    `gorm:"size:32" json:"rate"`
+	// SpreadBps is charged on conversions at this rate, in basis points.
+	SpreadBps int       `json:"spread_bps"`
+	CreatedAt time.Time `json:"created_date"`
+	UpdatedAt time.Time `json:"updated_date"`
+}
+
+// FXQuote locks a rate for one user and amount until ExpiresAt.
+type FXQuote struct {
+	ID           uuid.UUID   `gorm:"type:uuid;primary_key" json:"quote_id"`
+	UserID       uuid.UUID   `gorm:"type:uuid;index" json:"user_id"`
+	SourceAmount money.Money `gorm:"embedded;embeddedPrefix:source_amount_" json:"source_amount"`
+	TargetAmount money.Money `gorm:"embedded;embeddedPrefix:target_amount_" json:"target_amount"`
+	Rate         string      `gorm:"size:32" json:"rate"`
+	SpreadBps    int         `json:"spread_bps"`
+	ExpiresAt    time.Time   `json:"expires_date"`
+	UsedAt       *time.Time  `json:"used_date,omitempty"`
+	CreatedAt    time.Time   `json:"created_date"`
+}
+
+type FXRepository interface {
+	UpsertRate(rate *FXRate) error
+	GetRate(baseCurrency, quoteCurrency string) (*FXRate, error)
+	ListRates() ([]FXRate, error)
+	CreateQuote(quote *FXQuote) error
+	GetQuoteForUpdate(id uuid.UUID) (*FXQuote, error)
+	UpdateQuote(quote *FXQuote) error
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrIdempotencyKeyExists = errors.New("idempotency key already exists")
+
+// IdempotencyKey stores the first response produced for a client supplied
+// Idempotency-Key. StatusCode stays zero while that request is in flight.
+type IdempotencyKey struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key"`
+	UserID       uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_idempotency_user_key"`
+	Key          string    `gorm:"uniqueIndex:idx_idempotency_user_key"`
+	RequestHash  string
+	StatusCode   int
+	ResponseBody []byte
+	ExpiresAt    time.Time `gorm:"index"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
+
+type IdempotencyRepository interface {
+	// Create returns ErrIdempotencyKeyExists if the user already used the key.
+	Create(key *IdempotencyKey) error
+	Get(userID uuid.UUID, key string) (*IdempotencyKey, error)
+	Update(key *IdempotencyKey) error
+	Delete(id uuid.UUID) error
+	// DeleteExpired removes keys that expired before t and returns how many.
+	DeleteExpired(t time.Time) (int64, error)
+}
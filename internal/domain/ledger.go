@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+)
+
+type AccountType string
+
+const (
+	AccountTypeAsset     AccountType = "ASSET"
+	AccountTypeLiability AccountType = "LIABILITY"
+	AccountTypeRevenue   AccountType = "REVENUE"
+	AccountTypeEquity    AccountType = "EQUITY"
+)
+
+// Codes of the system accounts the wallet posts against. Together with a
+// currency they identify a single LedgerAccount.
+const (
+	AccountCashInClearing  = "system:cash_in_clearing"
+	AccountMerchantPayable = "system:merchant_payable"
+	AccountOpeningBalance  = "system:opening_balance"
+	// AccountFXPosition takes the other side of every conversion leg.
+	AccountFXPosition = "system:fx_position"
+	// AccountFeeRevenue is credited with every fee charged to a user or
+	// merchant.
+	AccountFeeRevenue = "system:fee_revenue"
+	// AccountPayoutInTransit holds merchant settlements that have been
+	// batched but not yet confirmed paid to the merchant's bank.
+	AccountPayoutInTransit = "system:payout_in_transit"
+)
+
+var ErrUnbalancedEntry = errors.New("journal entry postings do not sum to zero")
+
+func UserAccountCode(userID uuid.UUID) string {
+	return "user:" + userID.String()
+}
+
+func PocketAccountCode(pocketID uuid.UUID) string {
+	return "pocket:" + pocketID.String()
+}
+
+func MerchantAccountCode(merchantID uuid.UUID) string {
+	return "merchant:" + merchantID.String()
+}
+
+type LedgerAccount struct {
+	ID        uuid.UUID   `gorm:"type:uuid;primary_key" json:"account_id"`
+	Code      string      `gorm:"uniqueIndex:idx_ledger_account_code_currency" json:"code"`
+	Currency  string      `gorm:"size:3;uniqueIndex:idx_ledger_account_code_currency" json:"currency"`
+	Type      AccountType `json:"account_type"`
+	UserID    *uuid.UUID  `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	CreatedAt time.Time   `json:"created_date"`
+}
+
+// JournalEntry is one balanced business event. TransactionID links it to the
+// wallet Transaction that caused it.
+type JournalEntry struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key" json:"entry_id"`
+	TransactionID uuid.UUID `gorm:"type:uuid;index" json:"transaction_id"`
+	Description   string    `json:"description"`
+	Postings      []Posting `gorm:"foreignKey:EntryID" json:"postings"`
+	CreatedAt     time.Time `json:"created_date"`
+}
+
+// Posting amounts are signed: debits are positive and credits negative, so
+// the postings of a balanced entry sum to zero in every currency.
+type Posting struct {
+	ID        uuid.UUID   `gorm:"type:uuid;primary_key" json:"posting_id"`
+	EntryID   uuid.UUID   `gorm:"type:uuid;index" json:"entry_id"`
+	AccountID uuid.UUID   `gorm:"type:uuid;index" json:"account_id"`
+	Amount    money.Money `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`
+	CreatedAt time.Time   `json:"created_date"`
+}
+
+func (e *JournalEntry) Validate() error {
+	if len(e.Postings) < 2 {
+		return fmt.Errorf("%w: at least two postings are required", ErrUnbalancedEntry)
+	}
+
+	// An entry moves one currency; conversions post one entry per side
+	// against the FX position account.
+	currency := e.Postings[0].Amount.Currency
+	var sum int64
+	for _, p := range e.Postings {
+		if p.Amount.IsZero() {
+			return fmt.Errorf("%w: zero amount posting", ErrUnbalancedEntry)
+		}
+		if p.Amount.Currency != currency {
+			return fmt.Errorf("%w: postings mix %s and %s", ErrUnbalancedEntry, currency, p.Amount.Currency)
+		}
+		sum += p.Amount.Minor
+	}
+
+	if sum != 0 {
+		return fmt.Errorf("%w: %s is off by %s", ErrUnbalancedEntry, currency, money.New(sum, currency))
+	}
+
+	return nil
+}
+
+type LedgerRepository interface {
+	GetOrCreateAccount(account *LedgerAccount) (*LedgerAccount, error)
+	GetAccount(code, currency string) (*LedgerAccount, error)
+	CreateEntry(entry *JournalEntry) error
+	GetEntriesByTransactionID(transactionID uuid.UUID) ([]JournalEntry, error)
+	// SumPostings returns the signed (debit positive) sum of an account.
+	SumPostings(accountID uuid.UUID) (int64, error)
+}
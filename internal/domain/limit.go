@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LimitKind string
+
+const (
+	LimitPerTransaction  LimitKind = "PER_TRANSACTION"
+	LimitDailyOutgoing   LimitKind = "DAILY_OUTGOING"
+	LimitMonthlyIncoming LimitKind = "MONTHLY_INCOMING"
+	// LimitBalanceCap is the most a user may hold in a currency, wallet and
+	// pockets together, given their KYC level.
+	LimitBalanceCap LimitKind = "BALANCE_CAP"
+)
+
+// LimitOverride replaces some of the tier limits of one user in one currency.
+// Amounts are in minor units; nil keeps the tier limit.
+type LimitOverride struct {
+	UserID               uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Currency             string    `gorm:"size:3;primaryKey"`
+	PerTransactionMinor  *int64
+	DailyOutgoingMinor   *int64
+	MonthlyIncomingMinor *int64
+	UpdatedBy            uuid.UUID `gorm:"type:uuid"`
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+}
+
+type LimitRepository interface {
+	GetOverride(userID uuid.UUID, currency string) (*LimitOverride, error)
+	GetOverrides(userID uuid.UUID) ([]LimitOverride, error)
+	UpsertOverride(override *LimitOverride) error
+	DeleteOverride(userID uuid.UUID, currency string) error
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+)
+
+type MerchantStatus string
+
+const (
+	MerchantStatusActive MerchantStatus = "ACTIVE"
+	// MerchantStatusSuspended stops a merchant from receiving payments.
+	MerchantStatusSuspended MerchantStatus = "SUSPENDED"
+)
+
+// Merchant is a business users pay. It is managed by its owner, a user, and
+// its balance is what it has been paid and not yet settled to its bank
+// account.
+type Merchant struct {
+	ID      uuid.UUID      `gorm:"type:uuid;primary_key" json:"merchant_id"`
+	OwnerID uuid.UUID      `gorm:"type:uuid;index" json:"owner_id"`
+	Name    string         `json:"name"`
+	Status  MerchantStatus `gorm:"default:ACTIVE" json:"status"`
+	Balance money.Money    `gorm:"embedded;embeddedPrefix:balance_" json:"balance"`
+	// PayoutInTransit is what settlement batches have taken from the balance
+	// and not yet paid out.
+	PayoutInTransit         money.Money `gorm:"embedded;embeddedPrefix:payout_in_transit_" json:"payout_in_transit"`
+	SettlementBankCode      string      `json:"settlement_bank_code"`
+	SettlementAccountNumber string      `json:"settlement_account_number"`
+	SettlementAccountName   string      `json:"settlement_account_name"`
+	CreatedAt               time.Time   `json:"created_date"`
+	UpdatedAt               time.Time   `json:"updated_date"`
+}
+
+type MerchantRepository interface {
+	Create(merchant *Merchant) error
+	GetByID(id uuid.UUID) (*Merchant, error)
+	GetByIDForUpdate(id uuid.UUID) (*Merchant, error)
+	GetByOwnerID(ownerID uuid.UUID) ([]Merchant, error)
+	// List pages through merchants ordered by ID, starting after afterID.
+	List(afterID uuid.UUID, limit int) ([]Merchant, error)
+	UpdateBalance(merchantID uuid.UUID, balance money.Money) error
+	UpdatePayoutInTransit(merchantID uuid.UUID, inTransit money.Money) error
+	UpdateStatus(merchantID uuid.UUID, status MerchantStatus) error
+}
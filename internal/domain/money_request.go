@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+)
+
+type MoneyRequestStatus string
+
+const (
+	MoneyRequestStatusPending   MoneyRequestStatus = "PENDING"
+	MoneyRequestStatusAccepted  MoneyRequestStatus = "ACCEPTED"
+	MoneyRequestStatusDeclined  MoneyRequestStatus = "DECLINED"
+	MoneyRequestStatusCancelled MoneyRequestStatus = "CANCELLED"
+	MoneyRequestStatusExpired   MoneyRequestStatus = "EXPIRED"
+)
+
+// MoneyRequest asks PayerID to send Amount to RequesterID. Accepting it
+// creates the transfer TransferID; if that transfer fails or is cancelled the
+// request is PENDING again without one. BillID is set for a share of a bill.
+type MoneyRequest struct {
+	ID          uuid.UUID          `gorm:"type:uuid;primary_key" json:"request_id"`
+	RequesterID uuid.UUID          `gorm:"type:uuid;index" json:"requester_id"`
+	PayerID     uuid.UUID          `gorm:"type:uuid;index" json:"payer_id"`
+	Amount      money.Money        `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`
+	Note        string             `json:"note"`
+	Status      MoneyRequestStatus `gorm:"index" json:"status"`
+	TransferID  *uuid.UUID         `gorm:"type:uuid;index" json:"transfer_id,omitempty"`
+	BillID      *uuid.UUID         `gorm:"type:uuid;index" json:"bill_id,omitempty"`
+	ExpiresAt   time.Time          `gorm:"index" json:"expires_date"`
+	CreatedAt   time.Time          `json:"created_date"`
+	UpdatedAt   time.Time          `json:"updated_date"`
+
+	History []MoneyRequestEvent `gorm:"-" json:"history,omitempty"`
+}
+
+// MoneyRequestEvent records a status a request moved to and who moved it
+// there. ActorID is nil for expiry.
+type MoneyRequestEvent struct {
+	ID        uuid.UUID          `gorm:"type:uuid;primary_key" json:"event_id"`
+	RequestID uuid.UUID          `gorm:"type:uuid;index" json:"request_id"`
+	Status    MoneyRequestStatus `json:"status"`
+	ActorID   *uuid.UUID         `gorm:"type:uuid" json:"actor_id,omitempty"`
+	Reason    string             `json:"reason,omitempty"`
+	CreatedAt time.Time          `json:"created_date"`
+}
+
+type MoneyRequestRepository interface {
+	Create(request *MoneyRequest) error
+	GetByID(id uuid.UUID) (*MoneyRequest, error)
+	GetByIDForUpdate(id uuid.UUID) (*MoneyRequest, error)
+	// GetByTransferIDForUpdate locks the request paid by a transfer.
+	GetByTransferIDForUpdate(transferID uuid.UUID) (*MoneyRequest, error)
+	// GetIncoming returns the requests made of payerID, newest first.
+	GetIncoming(payerID uuid.UUID) ([]MoneyRequest, error)
+	// GetOutgoing returns the requests made by requesterID, newest first.
+	GetOutgoing(requesterID uuid.UUID) ([]MoneyRequest, error)
+	Update(request *MoneyRequest) error
+	// GetExpired returns up to limit pending requests whose expiry is before
+	// now.
+	GetExpired(now time.Time, limit int) ([]MoneyRequest, error)
+	CreateEvent(event *MoneyRequestEvent) error
+	GetEvents(requestID uuid.UUID) ([]MoneyRequestEvent, error)
+}
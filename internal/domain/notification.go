@@ -0,0 +1,22 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Notification struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key" json:"notification_id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;index" json:"user_id"`
+	Title     string     `json:"title"`
+	Message   string     `json:"message"`
+	ReadAt    *time.Time `json:"read_date,omitempty"`
+	CreatedAt time.Time  `json:"created_date"`
+}
+
+type NotificationRepository interface {
+	Create(notification *Notification) error
+	GetByUserID(userID uuid.UUID) ([]Notification, error)
+	MarkRead(userID, id uuid.UUID) error
+}
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending OutboxStatus = "PENDING"
+	OutboxStatusSent    OutboxStatus = "SENT"
+	// OutboxStatusCancelled rows are never published.
+	OutboxStatusCancelled OutboxStatus = "CANCELLED"
+)
+
+// OutboxMessage is a queue task written in the same database transaction as
+// the state change that produced it and published afterwards by the relay.
+// TaskID is used as the asynq task ID so publishing a row twice is harmless.
+type OutboxMessage struct {
+	ID        uuid.UUID    `gorm:"type:uuid;primary_key"`
+	TaskType  string       `gorm:"index"`
+	TaskID    string       `gorm:"index"`
+	Payload   []byte       `gorm:"type:jsonb"`
+	Status    OutboxStatus `gorm:"index"`
+	Attempts  int
+	LastError string
+	CreatedAt time.Time
+	SentAt    *time.Time
+}
+
+type OutboxRepository interface {
+	Create(msg *OutboxMessage) error
+	// GetPendingForUpdate locks up to limit unsent rows, skipping rows that
+	// another relay has already locked.
+	GetPendingForUpdate(limit int) ([]OutboxMessage, error)
+	Update(msg *OutboxMessage) error
+	HasPending(taskType, taskID string) (bool, error)
+	// CancelPending stops unsent rows of a task from being published.
+	CancelPending(taskType, taskID string) error
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+)
+
+type PaymentIntentStatus string
+
+const (
+	PaymentIntentStatusCreated PaymentIntentStatus = "CREATED"
+	// PaymentIntentStatusRequiresConfirmation is an intent a user has opened
+	// in the app; only that user can confirm it.
+	PaymentIntentStatusRequiresConfirmation PaymentIntentStatus = "REQUIRES_CONFIRMATION"
+	PaymentIntentStatusSucceeded            PaymentIntentStatus = "SUCCEEDED"
+	PaymentIntentStatusExpired              PaymentIntentStatus = "EXPIRED"
+	PaymentIntentStatusCancelled            PaymentIntentStatus = "CANCELLED"
+)
+
+// PaymentIntent is a checkout a merchant starts server-side and a user
+// completes in the app. The merchant is told about the outcome at
+// CallbackURL.
+type PaymentIntent struct {
+	ID             uuid.UUID           `gorm:"type:uuid;primary_key" json:"payment_intent_id"`
+	MerchantID     uuid.UUID           `gorm:"type:uuid;index" json:"merchant_id"`
+	PayerID        *uuid.UUID          `gorm:"type:uuid;index" json:"payer_id,omitempty"`
+	Status         PaymentIntentStatus `gorm:"index" json:"status"`
+	Amount         money.Money         `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`
+	OrderReference string              `json:"order_reference"`
+	Description    string              `json:"description"`
+	CallbackURL    string              `json:"callback_url"`
+	TransactionID  *uuid.UUID          `gorm:"type:uuid" json:"transaction_id,omitempty"`
+	ExpiresAt      time.Time           `gorm:"index" json:"expires_date"`
+	CreatedAt      time.Time           `json:"created_date"`
+	UpdatedAt      time.Time           `json:"updated_date"`
+}
+
+// Open reports whether the intent can still be paid or cancelled.
+func (p *PaymentIntent) Open() bool {
+	return p.Status == PaymentIntentStatusCreated || p.Status == PaymentIntentStatusRequiresConfirmation
+}
+
+type PaymentIntentRepository interface {
+	Create(intent *PaymentIntent) error
+	GetByID(id uuid.UUID) (*PaymentIntent, error)
+	GetByIDForUpdate(id uuid.UUID) (*PaymentIntent, error)
+	GetByMerchantID(merchantID uuid.UUID) ([]PaymentIntent, error)
+	Update(intent *PaymentIntent) error
+	// GetExpired returns up to limit open intents whose expiry is before now.
+	GetExpired(now time.Time, limit int) ([]PaymentIntent, error)
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+)
+
+// ReferenceTypePocket marks a move between a wallet and one of the user's
+// pockets; its ReferenceID is the pocket. Moves into a pocket are debits of
+// the wallet and moves out of it are credits.
+const ReferenceTypePocket = "pocket"
+
+var ErrPocketNameTaken = errors.New("pocket name is already taken")
+
+// Pocket is money a user has set aside from a wallet. It is not part of the
+// wallet balance and cannot be spent until it is moved back, except by a
+// payment or transfer that names the pocket as its source. Names are unique
+// per user regardless of case.
+type Pocket struct {
+	ID        uuid.UUID   `gorm:"type:uuid;primary_key" json:"pocket_id"`
+	UserID    uuid.UUID   `gorm:"type:uuid;uniqueIndex:idx_pockets_user_lower_name" json:"user_id"`
+	Name      string      `gorm:"uniqueIndex:idx_pockets_user_lower_name,expression:lower(name)" json:"name"`
+	Balance   money.Money `gorm:"embedded;embeddedPrefix:balance_" json:"balance"`
+	CreatedAt time.Time   `json:"created_date"`
+	UpdatedAt time.Time   `json:"updated_date"`
+}
+
+type PocketRepository interface {
+	// Create returns ErrPocketNameTaken if the user already has a pocket
+	// with the same name.
+	Create(pocket *Pocket) error
+	GetByID(id uuid.UUID) (*Pocket, error)
+	GetByIDForUpdate(id uuid.UUID) (*Pocket, error)
+	GetByUserID(userID uuid.UUID) ([]Pocket, error)
+	UpdateBalance(pocketID uuid.UUID, balance money.Money) error
+	Delete(pocketID uuid.UUID) error
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+)
+
+type ReconciliationStatus string
+type DiscrepancyKind string
+
+const (
+	ReconciliationStatusRunning   ReconciliationStatus = "RUNNING"
+	ReconciliationStatusCompleted ReconciliationStatus = "COMPLETED"
+	ReconciliationStatusFailed    ReconciliationStatus = "FAILED"
+
+	// DiscrepancyBalanceMismatch: users.balance differs from the sum of the
+	// user's successful credits minus debits.
+	DiscrepancyBalanceMismatch DiscrepancyKind = "BALANCE_MISMATCH"
+	// DiscrepancyChainBreak: a transaction's BalanceBefore does not follow
+	// the previous BalanceAfter, or its own before/after/amount disagree.
+	DiscrepancyChainBreak DiscrepancyKind = "CHAIN_BREAK"
+	// DiscrepancyLedgerMismatch: users.balance differs from the ledger.
+	DiscrepancyLedgerMismatch DiscrepancyKind = "LEDGER_MISMATCH"
+)
+
+type ReconciliationRun struct {
+	ID               uuid.UUID                   `gorm:"type:uuid;primary_key" json:"run_id"`
+	Status           ReconciliationStatus        `json:"status"`
+	UsersChecked     int                         `json:"users_checked"`
+	DiscrepancyCount int                         `json:"discrepancy_count"`
+	Error            string                      `json:"error,omitempty"`
+	Discrepancies    []ReconciliationDiscrepancy `gorm:"foreignKey:RunID" json:"discrepancies,omitempty"`
+	StartedAt        time.Time                   `json:"started_date"`
+	FinishedAt       *time.Time                  `json:"finished_date,omitempty"`
+}
+
+type ReconciliationDiscrepancy struct {
+	ID            uuid.UUID       `gorm:"type:uuid;primary_key" json:"discrepancy_id"`
+	RunID         uuid.UUID       `gorm:"type:uuid;index" json:"run_id"`
+	UserID        uuid.UUID       `gorm:"type:uuid;index" json:"user_id"`
+	Kind          DiscrepancyKind `json:"kind"`
+	Expected      money.Money     `gorm:"embedded;embeddedPrefix:expected_" json:"expected"`
+	Actual        money.Money     `gorm:"embedded;embeddedPrefix:actual_" json:"actual"`
+	TransactionID *uuid.UUID      `gorm:"type:uuid" json:"transaction_id,omitempty"`
+	Detail        string          `json:"detail"`
+	WalletFrozen  bool            `json:"wallet_frozen"`
+	CreatedAt     time.Time       `json:"created_date"`
+}
+
+type ReconciliationRepository interface {
+	CreateRun(run *ReconciliationRun) error
+	UpdateRun(run *ReconciliationRun) error
+	CreateDiscrepancy(discrepancy *ReconciliationDiscrepancy) error
+	ListRuns(limit int) ([]ReconciliationRun, error)
+	// GetRun loads a run together with its discrepancies.
+	GetRun(id uuid.UUID) (*ReconciliationRun, error)
+}
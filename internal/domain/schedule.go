@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+)
+
+type ScheduleFrequency string
+type ScheduleStatus string
+type ScheduleRunStatus string
+
+const (
+	ScheduleFrequencyOnce    ScheduleFrequency = "ONCE"
+	ScheduleFrequencyDaily   ScheduleFrequency = "DAILY"
+	ScheduleFrequencyWeekly  ScheduleFrequency = "WEEKLY"
+	ScheduleFrequencyMonthly ScheduleFrequency = "MONTHLY"
+
+	ScheduleStatusActive    ScheduleStatus = "ACTIVE"
+	ScheduleStatusPaused    ScheduleStatus = "PAUSED"
+	ScheduleStatusCompleted ScheduleStatus = "COMPLETED"
+
+	ScheduleRunStatusSuccess ScheduleRunStatus = "SUCCESS"
+	ScheduleRunStatusFailed  ScheduleRunStatus = "FAILED"
+)
+
+// TransferSchedule sends the same transfer once at StartAt or repeatedly at
+// Frequency until EndAt or until MaxOccurrences have run (zero means no
+// limit). NextRunAt is occurrence NextIndex and is nil once the schedule is
+// completed.
+type TransferSchedule struct {
+	ID             uuid.UUID         `gorm:"type:uuid;primary_key" json:"schedule_id"`
+	UserID         uuid.UUID         `gorm:"type:uuid;index" json:"user_id"`
+	TargetUserID   uuid.UUID         `gorm:"type:uuid" json:"target_user_id"`
+	Amount         money.Money       `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`
+	Remarks        string            `json:"remarks"`
+	Frequency      ScheduleFrequency `json:"frequency"`
+	StartAt        time.Time         `json:"start_date"`
+	EndAt          *time.Time        `json:"end_date,omitempty"`
+	MaxOccurrences int               `json:"max_occurrences,omitempty"`
+	Status         ScheduleStatus    `gorm:"index" json:"status"`
+	NextRunAt      *time.Time        `gorm:"index" json:"next_run_date,omitempty"`
+	NextIndex      int               `json:"-"`
+	RunCount       int               `json:"run_count"`
+	CreatedAt      time.Time         `json:"created_date"`
+	UpdatedAt      time.Time         `json:"updated_date"`
+}
+
+// OccurrenceAt returns when occurrence n (counting from zero) is due. Monthly
+// occurrences fall on the day of StartAt, or the last day of shorter months.
+func (s *TransferSchedule) OccurrenceAt(n int) time.Time {
+	switch s.Frequency {
+	case ScheduleFrequencyDaily:
+		return s.StartAt.AddDate(0, 0, n)
+	case ScheduleFrequencyWeekly:
+		return s.StartAt.AddDate(0, 0, 7*n)
+	case ScheduleFrequencyMonthly:
+		first := time.Date(s.StartAt.Year(), s.StartAt.Month()+time.Month(n), 1,
+			s.StartAt.Hour(), s.StartAt.Minute(), s.StartAt.Second(), s.StartAt.Nanosecond(), s.StartAt.Location())
+		lastDay := first.AddDate(0, 1, -1).Day()
+		return first.AddDate(0, 0, min(s.StartAt.Day(), lastDay)-1)
+	default:
+		return s.StartAt
+	}
+}
+
+// ScheduleRun records one occurrence of a schedule: the transfer it created
+// or why it could not be created.
+type ScheduleRun struct {
+	ID            uuid.UUID         `gorm:"type:uuid;primary_key" json:"run_id"`
+	ScheduleID    uuid.UUID         `gorm:"type:uuid;uniqueIndex:idx_schedule_runs_occurrence" json:"schedule_id"`
+	ScheduledFor  time.Time         `gorm:"uniqueIndex:idx_schedule_runs_occurrence" json:"scheduled_date"`
+	Status        ScheduleRunStatus `json:"status"`
+	TransactionID *uuid.UUID        `gorm:"type:uuid" json:"transaction_id,omitempty"`
+	FailureReason string            `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time         `json:"created_date"`
+}
+
+type ScheduleRepository interface {
+	Create(schedule *TransferSchedule) error
+	GetByID(id uuid.UUID) (*TransferSchedule, error)
+	GetByIDForUpdate(id uuid.UUID) (*TransferSchedule, error)
+	GetByUserID(userID uuid.UUID) ([]TransferSchedule, error)
+	// GetDue returns active schedules whose next occurrence is at or before t.
+	GetDue(t time.Time, limit int) ([]TransferSchedule, error)
+	Update(schedule *TransferSchedule) error
+	Delete(id uuid.UUID) error
+	CreateRun(run *ScheduleRun) error
+	GetRuns(scheduleID uuid.UUID) ([]ScheduleRun, error)
+}
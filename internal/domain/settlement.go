@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+)
+
+type SettlementStatus string
+type SettlementItemType string
+
+const (
+	// SettlementStatusInTransit is a payout that has left the merchant's
+	// balance and is on its way to the merchant's bank account.
+	SettlementStatusInTransit SettlementStatus = "PAYOUT_IN_TRANSIT"
+	SettlementStatusPaid      SettlementStatus = "PAID"
+
+	SettlementItemPayment SettlementItemType = "PAYMENT"
+	SettlementItemRefund  SettlementItemType = "REFUND"
+)
+
+// SettlementBatch pays out a merchant's payments, less the refunds of them
+// and the settlement fee, that were not in an earlier batch. The bank
+// account is copied from the merchant when the batch is made.
+type SettlementBatch struct {
+	ID                      uuid.UUID        `gorm:"type:uuid;primary_key" json:"settlement_id"`
+	MerchantID              uuid.UUID        `gorm:"type:uuid;index" json:"merchant_id"`
+	Status                  SettlementStatus `json:"status"`
+	PaymentCount            int              `json:"payment_count"`
+	RefundCount             int              `json:"refund_count"`
+	Gross                   money.Money      `gorm:"embedded;embeddedPrefix:gross_" json:"gross"`
+	Refunds                 money.Money      `gorm:"embedded;embeddedPrefix:refunds_" json:"refunds"`
+	Fee                     money.Money      `gorm:"embedded;embeddedPrefix:fee_" json:"fee"`
+	Net                     money.Money      `gorm:"embedded;embeddedPrefix:net_" json:"net"`
+	SettlementBankCode      string           `json:"settlement_bank_code"`
+	SettlementAccountNumber string           `json:"settlement_account_number"`
+	SettlementAccountName   string           `json:"settlement_account_name"`
+	// CutoffAt is when the batch was made; it covers everything the merchant
+	// received before then.
+	CutoffAt  time.Time  `json:"cutoff_date"`
+	CreatedAt time.Time  `json:"created_date"`
+	PaidAt    *time.Time `json:"paid_date,omitempty"`
+}
+
+// SettlementItem is one payment or refund in a batch. A transaction is
+// settled at most once.
+type SettlementItem struct {
+	ID             uuid.UUID          `gorm:"type:uuid;primary_key" json:"item_id"`
+	BatchID        uuid.UUID          `gorm:"type:uuid;index" json:"settlement_id"`
+	TransactionID  uuid.UUID          `gorm:"type:uuid;uniqueIndex" json:"transaction_id"`
+	Type           SettlementItemType `json:"type"`
+	Amount         money.Money        `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`
+	OrderReference string             `json:"order_reference,omitempty"`
+	TransactedAt   time.Time          `json:"transacted_date"`
+}
+
+type SettlementRepository interface {
+	CreateBatch(batch *SettlementBatch) error
+	CreateItems(items []SettlementItem) error
+	GetBatch(id uuid.UUID) (*SettlementBatch, error)
+	GetBatchForUpdate(id uuid.UUID) (*SettlementBatch, error)
+	GetBatchesByMerchantID(merchantID uuid.UUID) ([]SettlementBatch, error)
+	GetItems(batchID uuid.UUID) ([]SettlementItem, error)
+	UpdateBatch(batch *SettlementBatch) error
+	// GetUnsettled returns the successful payments to a merchant and refunds
+	// of them that were settled before cutoff and are in no batch yet.
+	GetUnsettled(merchantID uuid.UUID, cutoff time.Time) ([]Transaction, error)
+}
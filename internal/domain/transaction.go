@@ -3,6 +3,7 @@ package domain
 import (
 	"time"
 
+	"github.com/bangadam/wallet-api/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -16,6 +17,36 @@ const (
 	TransactionStatusSuccess TransactionStatus = "SUCCESS"
 	TransactionStatusFailed  TransactionStatus = "FAILED"
 	TransactionStatusPending TransactionStatus = "PENDING"
+	// TransactionStatusCancelled is a transfer the sender called off before
+	// the worker settled it.
+	TransactionStatusCancelled TransactionStatus = "CANCELLED"
+
+	// Two-phase payments: an authorization holds funds until it is captured,
+	// voided or expires.
+	TransactionStatusAuthorized TransactionStatus = "AUTHORIZED"
+	TransactionStatusCaptured   TransactionStatus = "CAPTURED"
+	TransactionStatusVoided     TransactionStatus = "VOIDED"
+	TransactionStatusExpired    TransactionStatus = "EXPIRED"
+
+	// ReferenceTypeTransfer marks the recipient's credit of a transfer; its
+	// ReferenceID is the sender's transaction.
+	ReferenceTypeTransfer = "transfer"
+	// ReferenceTypeConversion marks both legs of a currency conversion; their
+	// ReferenceID is the quote the conversion was made at.
+	ReferenceTypeConversion = "conversion"
+	// ReferenceTypeAuthorization marks the debit that captured an
+	// authorization; its ReferenceID is the authorization.
+	ReferenceTypeAuthorization = "authorization"
+	// ReferenceTypeRefund and ReferenceTypeReversal mark the legs that give
+	// back all or part of a payment or transfer; their ReferenceID is the
+	// original transaction.
+	ReferenceTypeRefund   = "refund"
+	ReferenceTypeReversal = "reversal"
+	// ReferenceTypeFee marks the debit that charges the fee of a top-up,
+	// payment or transfer; its ReferenceID is that transaction. The fee of a
+	// transfer is held and settled or released with the transfer. A refund
+	// gives back its share of the fee as a credit with the same reference.
+	ReferenceTypeFee = "fee"
 )
 
 type Transaction struct {
@@ -23,20 +54,50 @@ type Transaction struct {
 	UserID        uuid.UUID         `json:"user_id"`
 	Type          TransactionType   `json:"transaction_type"`
 	Status        TransactionStatus `json:"status"`
-	Amount        float64           `json:"amount"`
+	Amount        money.Money       `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`
 	Remarks       string            `json:"remarks"`
-	BalanceBefore float64           `json:"balance_before"`
-	BalanceAfter  float64           `json:"balance_after"`
-	ReferenceID   uuid.UUID         `json:"reference_id,omitempty"`
+	BalanceBefore money.Money       `gorm:"embedded;embeddedPrefix:balance_before_" json:"balance_before"`
+	BalanceAfter  money.Money       `gorm:"embedded;embeddedPrefix:balance_after_" json:"balance_after"`
+	ReferenceID   uuid.UUID         `gorm:"uniqueIndex:idx_transactions_transfer_credit,where:reference_type = 'transfer'" json:"reference_id,omitempty"`
 	ReferenceType string            `json:"reference_type,omitempty"`
 	TargetUserID  *uuid.UUID        `json:"target_user_id,omitempty"`
-	CreatedAt     time.Time         `json:"created_date"`
-	UpdatedAt     time.Time         `json:"updated_date"`
+	FailureReason string            `json:"failure_reason,omitempty"`
+	// ExchangeRate and SpreadBps are set on conversion legs.
+	ExchangeRate string `gorm:"size:32" json:"exchange_rate,omitempty"`
+	SpreadBps    int    `json:"spread_bps,omitempty"`
+	// MerchantID is the merchant a payment was made to, or a refund taken
+	// from, and OrderReference the merchant's reference of what was paid for.
+	MerchantID     *uuid.UUID `gorm:"type:uuid;index" json:"merchant_id,omitempty"`
+	OrderReference string     `json:"order_reference,omitempty"`
+	// PocketID is the pocket a transfer was sent from; failing or cancelling
+	// the transfer puts the money back there.
+	PocketID *uuid.UUID `gorm:"type:uuid" json:"pocket_id,omitempty"`
+	// ExpiresAt is when an authorization lapses if it is not captured.
+	ExpiresAt *time.Time `gorm:"index" json:"expires_date,omitempty"`
+	CreatedAt time.Time  `json:"created_date"`
+	UpdatedAt time.Time  `json:"updated_date"`
+	// SettledAt is when the balance change was applied; BalanceBefore and
+	// BalanceAfter form a chain in this order.
+	SettledAt *time.Time `gorm:"index" json:"settled_date,omitempty"`
 }
 
 type TransactionRepository interface {
 	Create(tx *Transaction) error
 	GetByUserID(userID uuid.UUID) ([]Transaction, error)
 	GetByID(id uuid.UUID) (*Transaction, error)
+	GetByIDForUpdate(id uuid.UUID) (*Transaction, error)
+	GetByReference(referenceID uuid.UUID, referenceType string) ([]Transaction, error)
+	// GetByMerchantID returns the payments made to a merchant and the refund
+	// credits of them, newest first.
+	GetByMerchantID(merchantID uuid.UUID) ([]Transaction, error)
+	GetPendingTransfers(createdBefore time.Time, limit int) ([]Transaction, error)
+	GetExpiredAuthorizations(expiredBefore time.Time, limit int) ([]Transaction, error)
+	// SumOutgoing sums the payments, transfers and open authorizations a user
+	// made in a currency since a time, in minor units.
+	SumOutgoing(userID uuid.UUID, currency string, since time.Time) (int64, error)
+	// SumIncoming sums the top-ups and transfers a user received in a
+	// currency since a time, including transfers still pending, in minor
+	// units.
+	SumIncoming(userID uuid.UUID, currency string, since time.Time) (int64, error)
 	Update(tx *Transaction) error
 }
@@ -0,0 +1,26 @@
+package domain
+
+// Repositories groups repositories that share a single database transaction.
+type Repositories struct {
+	Users         UserRepository
+	Wallets       WalletRepository
+	Pockets       PocketRepository
+	Transactions  TransactionRepository
+	Ledger        LedgerRepository
+	Outbox        OutboxRepository
+	Notifications NotificationRepository
+	FX            FXRepository
+	Schedules     ScheduleRepository
+	Limits        LimitRepository
+	Merchants     MerchantRepository
+	Settlements   SettlementRepository
+	Intents       PaymentIntentRepository
+	Requests      MoneyRequestRepository
+	Bills         BillRepository
+}
+
+// UnitOfWork runs fn inside one database transaction. The transaction is
+// committed when fn returns nil and rolled back otherwise.
+type UnitOfWork interface {
+	Do(fn func(repos *Repositories) error) error
+}
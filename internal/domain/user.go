@@ -6,22 +6,55 @@ import (
 	"github.com/google/uuid"
 )
 
+// DefaultCurrency is the currency of requests that do not name one.
+const DefaultCurrency = "IDR"
+
+type UserRole string
+type UserStatus string
+type KYCLevel string
+
+const (
+	UserRoleUser  UserRole = "USER"
+	UserRoleAdmin UserRole = "ADMIN"
+
+	UserStatusActive UserStatus = "ACTIVE"
+	// UserStatusFrozen blocks every balance change on the wallet.
+	UserStatusFrozen UserStatus = "FROZEN"
+
+	// KYC levels cap how much a user may hold; see LimitsConfig.BalanceCaps.
+	KYCLevelUnverified KYCLevel = "UNVERIFIED"
+	KYCLevelVerified   KYCLevel = "VERIFIED"
+)
+
 type User struct {
-	ID          uuid.UUID `gorm:"type:uuid;primary_key" json:"user_id"`
-	FirstName   string    `json:"first_name"`
-	LastName    string    `json:"last_name"`
-	PhoneNumber string    `gorm:"unique" json:"phone_number"`
-	Address     string    `json:"address"`
-	Pin         string    `json:"-"`
-	Balance     float64   `json:"balance"`
-	CreatedAt   time.Time `json:"created_date"`
-	UpdatedAt   time.Time `json:"updated_date"`
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key" json:"user_id"`
+	FirstName   string     `json:"first_name"`
+	LastName    string     `json:"last_name"`
+	PhoneNumber string     `gorm:"unique" json:"phone_number"`
+	Address     string     `json:"address"`
+	Pin         string     `json:"-"`
+	Role        UserRole   `gorm:"default:USER" json:"role"`
+	Status      UserStatus `gorm:"default:ACTIVE" json:"status"`
+	// Tier selects the transaction limits that apply to the user; empty
+	// means the configured default tier.
+	Tier      string    `json:"tier,omitempty"`
+	KYCLevel  KYCLevel  `gorm:"default:UNVERIFIED" json:"kyc_level"`
+	CreatedAt time.Time `json:"created_date"`
+	UpdatedAt time.Time `json:"updated_date"`
+
+	// FailedPINAttempts counts wrong PINs in a row when confirming payments;
+	// PINLockedUntil blocks confirmations after too many of them.
+	FailedPINAttempts int        `json:"-"`
+	PINLockedUntil    *time.Time `json:"-"`
 }
 
 type UserRepository interface {
 	Create(user *User) error
 	GetByPhoneNumber(phoneNumber string) (*User, error)
 	GetByID(id uuid.UUID) (*User, error)
+	GetByIDForUpdate(id uuid.UUID) (*User, error)
 	Update(user *User) error
-	UpdateBalance(userID uuid.UUID, amount float64) error
+	UpdateStatus(userID uuid.UUID, status UserStatus) error
+	// List pages through users ordered by ID, starting after afterID.
+	List(afterID uuid.UUID, limit int) ([]User, error)
 }
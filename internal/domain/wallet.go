@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+)
+
+// Wallet holds a user's balance in one currency. A user has at most one
+// wallet per currency; wallets are opened by the first credit.
+type Wallet struct {
+	ID          uuid.UUID   `gorm:"type:uuid;primary_key" json:"wallet_id"`
+	UserID      uuid.UUID   `gorm:"type:uuid;uniqueIndex:idx_wallets_user_currency" json:"user_id"`
+	Currency    string      `gorm:"size:3;uniqueIndex:idx_wallets_user_currency" json:"currency"`
+	Balance     money.Money `gorm:"embedded;embeddedPrefix:balance_" json:"balance"`
+	HeldBalance money.Money `gorm:"embedded;embeddedPrefix:held_balance_" json:"held_balance"`
+	CreatedAt   time.Time   `json:"created_date"`
+	UpdatedAt   time.Time   `json:"updated_date"`
+}
+
+// AvailableBalance is the part of the balance that is not on hold.
+func (w *Wallet) AvailableBalance() money.Money {
+	return w.Balance.Sub(w.HeldBalance)
+}
+
+type WalletRepository interface {
+	// GetOrCreate returns the user's wallet in currency, opening an empty one
+	// if it does not exist yet.
+	GetOrCreate(userID uuid.UUID, currency string) (*Wallet, error)
+	Get(userID uuid.UUID, currency string) (*Wallet, error)
+	GetForUpdate(userID uuid.UUID, currency string) (*Wallet, error)
+	GetByUserID(userID uuid.UUID) ([]Wallet, error)
+	UpdateBalance(walletID uuid.UUID, balance money.Money) error
+	UpdateHeldBalance(walletID uuid.UUID, held money.Money) error
+}
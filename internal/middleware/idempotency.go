@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyMiddleware makes a route safe to retry. The first response for a
+// user and Idempotency-Key is stored and replayed for later requests with the
+// same body; reusing the key with a different body is rejected with 422.
+// Server errors and panics are not stored so that the client can retry them.
+// It must run after AuthMiddleware.
+func IdempotencyMiddleware(repo domain.IdempotencyRepository, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		userID, _ := c.Get("user_id")
+		uid := userID.(uuid.UUID)
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := requestHash(c.Request.Method, c.FullPath(), body)
+
+		existing, err := repo.Get(uid, key)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		if existing != nil && time.Now().After(existing.ExpiresAt) {
+			if err := repo.Delete(existing.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			existing = nil
+		}
+
+		if existing != nil {
+			replay(c, existing, hash)
+			return
+		}
+
+		record := &domain.IdempotencyKey{
+			UserID:      uid,
+			Key:         key,
+			RequestHash: hash,
+			ExpiresAt:   time.Now().Add(ttl),
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+		if err := repo.Create(record); err != nil {
+			if errors.Is(err, domain.ErrIdempotencyKeyExists) {
+				c.JSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still in progress"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			c.Abort()
+			return
+		}
+
+		// A panicking handler would otherwise leave the key in flight, answering
+		// 409 until it expires. The panic goes on to the recovery middleware.
+		defer func() {
+			if r := recover(); r != nil {
+				release(repo, record)
+				panic(r)
+			}
+		}()
+
+		writer := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		if writer.Status() >= http.StatusInternalServerError {
+			release(repo, record)
+			return
+		}
+
+		record.StatusCode = writer.Status()
+		record.ResponseBody = writer.body.Bytes()
+		record.UpdatedAt = time.Now()
+		if err := repo.Update(record); err != nil {
+			// Without the response the key would answer 409 until it expires.
+			// Dropping it lets a retry through; the handler's own checks have
+			// to catch a repeat from then on.
+			log.Printf("Failed to store response for Idempotency-Key %q: %s", key, err)
+			release(repo, record)
+		}
+	}
+}
+
+// release deletes a key whose response is not stored so the request can be
+// retried with it.
+func release(repo domain.IdempotencyRepository, record *domain.IdempotencyKey) {
+	if err := repo.Delete(record.ID); err != nil {
+		log.Printf("Failed to release Idempotency-Key %q: %s", record.Key, err)
+	}
+}
+
+func replay(c *gin.Context, existing *domain.IdempotencyKey, hash string) {
+	defer c.Abort()
+
+	if existing.RequestHash != hash {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+		return
+	}
+
+	if !existing.Completed() {
+		c.JSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still in progress"})
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(existing.StatusCode, "application/json; charset=utf-8", existing.ResponseBody)
+}
+
+func requestHash(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder keeps a copy of everything written to the client.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
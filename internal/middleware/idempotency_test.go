@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type memIdempotencyRepo struct {
+	keys      map[string]domain.IdempotencyKey
+	failWrite bool
+}
+
+func newMemIdempotencyRepo() *memIdempotencyRepo {
+	return &memIdempotencyRepo{keys: make(map[string]domain.IdempotencyKey)}
+}
+
+func (r *memIdempotencyRepo) Create(key *domain.IdempotencyKey) error {
+	mapKey := key.UserID.String() + "/" + key.Key
+	if _, ok := r.keys[mapKey]; ok {
+		return domain.ErrIdempotencyKeyExists
+	}
+	if key.ID == uuid.Nil {
+		key.ID = uuid.New()
+	}
+	r.keys[mapKey] = *key
+	return nil
+}
+
+func (r *memIdempotencyRepo) Get(userID uuid.UUID, key string) (*domain.IdempotencyKey, error) {
+	record, ok := r.keys[userID.String()+"/"+key]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &record, nil
+}
+
+func (r *memIdempotencyRepo) Update(key *domain.IdempotencyKey) error {
+	if r.failWrite {
+		return errors.New("connection reset")
+	}
+	r.keys[key.UserID.String()+"/"+key.Key] = *key
+	return nil
+}
+
+func (r *memIdempotencyRepo) Delete(id uuid.UUID) error {
+	for mapKey, record := range r.keys {
+		if record.ID == id {
+			delete(r.keys, mapKey)
+		}
+	}
+	return nil
+}
+
+func (r *memIdempotencyRepo) DeleteExpired(t time.Time) (int64, error) {
+	var deleted int64
+	for mapKey, record := range r.keys {
+		if record.ExpiresAt.Before(t) {
+			delete(r.keys, mapKey)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := uuid.New()
+
+	setup := func(repo *memIdempotencyRepo) (*gin.Engine, *int) {
+		calls := 0
+		router := gin.New()
+		router.Use(func(c *gin.Context) { c.Set("user_id", userID) })
+		router.POST("/pay", IdempotencyMiddleware(repo, time.Hour), func(c *gin.Context) {
+			calls++
+			c.JSON(http.StatusOK, gin.H{"status": "SUCCESS", "call": calls})
+		})
+		return router, &calls
+	}
+
+	send := func(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/pay", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("replays the stored response", func(t *testing.T) {
+		router, calls := setup(newMemIdempotencyRepo())
+
+		first := send(router, "key-1", `{"amount":"100"}`)
+		second := send(router, "key-1", `{"amount":"100"}`)
+
+		assert.Equal(t, 1, *calls)
+		assert.Equal(t, http.StatusOK, second.Code)
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	})
+
+	t.Run("rejects the key with a different body", func(t *testing.T) {
+		router, calls := setup(newMemIdempotencyRepo())
+
+		send(router, "key-1", `{"amount":"100"}`)
+		rec := send(router, "key-1", `{"amount":"200"}`)
+
+		assert.Equal(t, 1, *calls)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	})
+
+	t.Run("rejects the key while its request is in flight", func(t *testing.T) {
+		repo := newMemIdempotencyRepo()
+		router, calls := setup(repo)
+		require.NoError(t, repo.Create(&domain.IdempotencyKey{
+			UserID:      userID,
+			Key:         "key-1",
+			RequestHash: requestHash(http.MethodPost, "/pay", []byte(`{"amount":"100"}`)),
+			ExpiresAt:   time.Now().Add(time.Hour),
+		}))
+
+		rec := send(router, "key-1", `{"amount":"100"}`)
+
+		assert.Equal(t, 0, *calls)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("reuses the key after it expired", func(t *testing.T) {
+		repo := newMemIdempotencyRepo()
+		router, calls := setup(repo)
+
+		send(router, "key-1", `{"amount":"100"}`)
+		record := repo.keys[userID.String()+"/key-1"]
+		record.ExpiresAt = time.Now().Add(-time.Minute)
+		repo.keys[userID.String()+"/key-1"] = record
+
+		rec := send(router, "key-1", `{"amount":"200"}`)
+
+		assert.Equal(t, 2, *calls)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+	})
+
+	t.Run("releases the key when the response cannot be stored", func(t *testing.T) {
+		repo := newMemIdempotencyRepo()
+		repo.failWrite = true
+		router, calls := setup(repo)
+
+		send(router, "key-1", `{"amount":"100"}`)
+		assert.Empty(t, repo.keys, "a key left in flight would answer 409 until it expires")
+
+		repo.failWrite = false
+		rec := send(router, "key-1", `{"amount":"100"}`)
+		assert.Equal(t, 2, *calls)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("releases the key when the handler panics", func(t *testing.T) {
+		repo := newMemIdempotencyRepo()
+		calls := 0
+		router := gin.New()
+		router.Use(gin.RecoveryWithWriter(io.Discard), func(c *gin.Context) { c.Set("user_id", userID) })
+		router.POST("/pay", IdempotencyMiddleware(repo, time.Hour), func(c *gin.Context) {
+			calls++
+			if calls == 1 {
+				panic("boom")
+			}
+			c.JSON(http.StatusOK, gin.H{"status": "SUCCESS"})
+		})
+
+		rec := send(router, "key-1", `{"amount":"100"}`)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code, "the panic reaches the recovery middleware")
+		assert.Empty(t, repo.keys)
+
+		rec = send(router, "key-1", `{"amount":"100"}`)
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
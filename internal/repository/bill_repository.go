@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type billRepository struct {
+	db *gorm.DB
+}
+
+func NewBillRepository(db *gorm.DB) domain.BillRepository {
+	return &billRepository{db: db}
+}
+
+func (r *billRepository) Create(bill *domain.Bill) error {
+	if bill.ID == uuid.Nil {
+		bill.ID = uuid.New()
+	}
+	return r.db.Create(bill).Error
+}
+
+func (r *billRepository) CreateParticipants(participants []domain.BillParticipant) error {
+	if len(participants) == 0 {
+		return nil
+	}
+	return r.db.Create(&participants).Error
+}
+
+func (r *billRepository) GetByID(id uuid.UUID) (*domain.Bill, error) {
+	var bill domain.Bill
+	err := r.db.First(&bill, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &bill, nil
+}
+
+func (r *billRepository) GetParticipants(billID uuid.UUID) ([]domain.BillParticipant, error) {
+	var participants []domain.BillParticipant
+	err := r.db.Where("bill_id = ?", billID).Order("position asc").Find(&participants).Error
+	if err != nil {
+		return nil, err
+	}
+	return participants, nil
+}
+
+func (r *billRepository) GetByUserID(userID uuid.UUID) ([]domain.Bill, error) {
+	var bills []domain.Bill
+	err := r.db.
+		Where("creator_id = ? OR id IN (SELECT bill_id FROM bill_participants WHERE user_id = ?)", userID, userID).
+		Order("created_at desc").
+		Find(&bills).Error
+	if err != nil {
+		return nil, err
+	}
+	return bills, nil
+}
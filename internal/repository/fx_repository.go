@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type fxRepository struct {
+	db *gorm.DB
+}
+
+func NewFXRepository(db *gorm.DB) domain.FXRepository {
+	return &fxRepository{db: db}
+}
+
+func (r *fxRepository) UpsertRate(rate *domain.FXRate) error {
+	if rate.ID == uuid.Nil {
+		rate.ID = uuid.New()
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "base_currency"}, {Name: "quote_currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "spread_bps", "updated_at"}),
+	}).Create(rate).Error
+}
+
+func (r *fxRepository) GetRate(baseCurrency, quoteCurrency string) (*domain.FXRate, error) {
+	var rate domain.FXRate
+	err := r.db.Where("base_currency = ? AND quote_currency = ?", baseCurrency, quoteCurrency).First(&rate).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func (r *fxRepository) ListRates() ([]domain.FXRate, error) {
+	var rates []domain.FXRate
+	err := r.db.Order("base_currency asc, quote_currency asc").Find(&rates).Error
+	if err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+func (r *fxRepository) CreateQuote(quote *domain.FXQuote) error {
+	if quote.ID == uuid.Nil {
+		quote.ID = uuid.New()
+	}
+	return r.db.Create(quote).Error
+}
+
+// GetQuoteForUpdate locks the row until the surrounding transaction ends.
+func (r *fxRepository) GetQuoteForUpdate(id uuid.UUID) (*domain.FXQuote, error) {
+	var quote domain.FXQuote
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&quote, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+func (r *fxRepository) UpdateQuote(quote *domain.FXQuote) error {
+	return r.db.Save(quote).Error
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) domain.IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+func (r *idempotencyRepository) Create(key *domain.IdempotencyKey) error {
+	if key.ID == uuid.Nil {
+		key.ID = uuid.New()
+	}
+
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrIdempotencyKeyExists
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) Get(userID uuid.UUID, key string) (*domain.IdempotencyKey, error) {
+	var idempotencyKey domain.IdempotencyKey
+	err := r.db.Where("user_id = ? AND key = ?", userID, key).First(&idempotencyKey).Error
+	if err != nil {
+		return nil, err
+	}
+	return &idempotencyKey, nil
+}
+
+func (r *idempotencyRepository) Update(key *domain.IdempotencyKey) error {
+	return r.db.Save(key).Error
+}
+
+func (r *idempotencyRepository) Delete(id uuid.UUID) error {
+	return r.db.Delete(&domain.IdempotencyKey{}, "id = ?", id).Error
+}
+
+func (r *idempotencyRepository) DeleteExpired(t time.Time) (int64, error) {
+	result := r.db.Where("expires_at < ?", t).Delete(&domain.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ledgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) domain.LedgerRepository {
+	return &ledgerRepository{db: db}
+}
+
+func (r *ledgerRepository) GetOrCreateAccount(account *domain.LedgerAccount) (*domain.LedgerAccount, error) {
+	if account.ID == uuid.Nil {
+		account.ID = uuid.New()
+	}
+
+	err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(account).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetAccount(account.Code, account.Currency)
+}
+
+func (r *ledgerRepository) GetAccount(code, currency string) (*domain.LedgerAccount, error) {
+	var account domain.LedgerAccount
+	err := r.db.Where("code = ? AND currency = ?", code, currency).First(&account).Error
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *ledgerRepository) CreateEntry(entry *domain.JournalEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	for i := range entry.Postings {
+		if entry.Postings[i].ID == uuid.Nil {
+			entry.Postings[i].ID = uuid.New()
+		}
+		entry.Postings[i].EntryID = entry.ID
+	}
+
+	return r.db.Create(entry).Error
+}
+
+func (r *ledgerRepository) GetEntriesByTransactionID(transactionID uuid.UUID) ([]domain.JournalEntry, error) {
+	var entries []domain.JournalEntry
+	err := r.db.Preload("Postings").
+		Where("transaction_id = ?", transactionID).
+		Order("created_at asc").
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *ledgerRepository) SumPostings(accountID uuid.UUID) (int64, error) {
+	var sum int64
+	err := r.db.Model(&domain.Posting{}).
+		Where("account_id = ?", accountID).
+		Select("COALESCE(SUM(amount_minor), 0)").
+		Scan(&sum).Error
+	return sum, err
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type limitRepository struct {
+	db *gorm.DB
+}
+
+func NewLimitRepository(db *gorm.DB) domain.LimitRepository {
+	return &limitRepository{db: db}
+}
+
+func (r *limitRepository) GetOverride(userID uuid.UUID, currency string) (*domain.LimitOverride, error) {
+	var override domain.LimitOverride
+	err := r.db.First(&override, "user_id = ? AND currency = ?", userID, currency).Error
+	if err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+func (r *limitRepository) GetOverrides(userID uuid.UUID) ([]domain.LimitOverride, error) {
+	var overrides []domain.LimitOverride
+	err := r.db.Where("user_id = ?", userID).Order("currency asc").Find(&overrides).Error
+	if err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
+
+func (r *limitRepository) UpsertOverride(override *domain.LimitOverride) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"per_transaction_minor", "daily_outgoing_minor", "monthly_incoming_minor", "updated_by", "updated_at",
+		}),
+	}).Create(override).Error
+}
+
+func (r *limitRepository) DeleteOverride(userID uuid.UUID, currency string) error {
+	return r.db.Delete(&domain.LimitOverride{}, "user_id = ? AND currency = ?", userID, currency).Error
+}
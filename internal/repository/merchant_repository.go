@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type merchantRepository struct {
+	db *gorm.DB
+}
+
+func NewMerchantRepository(db *gorm.DB) domain.MerchantRepository {
+	return &merchantRepository{db: db}
+}
+
+func (r *merchantRepository) Create(merchant *domain.Merchant) error {
+	if merchant.ID == uuid.Nil {
+		merchant.ID = uuid.New()
+	}
+	return r.db.Create(merchant).Error
+}
+
+func (r *merchantRepository) GetByID(id uuid.UUID) (*domain.Merchant, error) {
+	var merchant domain.Merchant
+	err := r.db.First(&merchant, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &merchant, nil
+}
+
+// GetByIDForUpdate locks the row until the surrounding transaction ends.
+func (r *merchantRepository) GetByIDForUpdate(id uuid.UUID) (*domain.Merchant, error) {
+	var merchant domain.Merchant
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&merchant, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &merchant, nil
+}
+
+func (r *merchantRepository) GetByOwnerID(ownerID uuid.UUID) ([]domain.Merchant, error) {
+	var merchants []domain.Merchant
+	err := r.db.Where("owner_id = ?", ownerID).Order("created_at asc").Find(&merchants).Error
+	if err != nil {
+		return nil, err
+	}
+	return merchants, nil
+}
+
+func (r *merchantRepository) List(afterID uuid.UUID, limit int) ([]domain.Merchant, error) {
+	var merchants []domain.Merchant
+	err := r.db.Where("id > ?", afterID).Order("id asc").Limit(limit).Find(&merchants).Error
+	if err != nil {
+		return nil, err
+	}
+	return merchants, nil
+}
+
+func (r *merchantRepository) UpdatePayoutInTransit(merchantID uuid.UUID, inTransit money.Money) error {
+	return r.db.Model(&domain.Merchant{}).
+		Where("id = ?", merchantID).
+		Updates(map[string]interface{}{
+			"payout_in_transit_minor":    inTransit.Minor,
+			"payout_in_transit_currency": inTransit.Currency,
+			"updated_at":                 time.Now(),
+		}).
+		Error
+}
+
+func (r *merchantRepository) UpdateBalance(merchantID uuid.UUID, balance money.Money) error {
+	return r.db.Model(&domain.Merchant{}).
+		Where("id = ?", merchantID).
+		Updates(map[string]interface{}{
+			"balance_minor":    balance.Minor,
+			"balance_currency": balance.Currency,
+			"updated_at":       time.Now(),
+		}).
+		Error
+}
+
+func (r *merchantRepository) UpdateStatus(merchantID uuid.UUID, status domain.MerchantStatus) error {
+	return r.db.Model(&domain.Merchant{}).
+		Where("id = ?", merchantID).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"updated_at": time.Now(),
+		}).
+		Error
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Migrate brings the schema up to date and runs the data migrations that
+// AutoMigrate cannot express.
+func Migrate(db *gorm.DB) error {
+	err := db.AutoMigrate(
+		&domain.User{},
+		&domain.Wallet{},
+		&domain.Pocket{},
+		&domain.Transaction{},
+		&domain.LedgerAccount{},
+		&domain.JournalEntry{},
+		&domain.Posting{},
+		&domain.IdempotencyKey{},
+		&domain.OutboxMessage{},
+		&domain.Notification{},
+		&domain.ReconciliationRun{},
+		&domain.ReconciliationDiscrepancy{},
+		&domain.FXRate{},
+		&domain.FXQuote{},
+		&domain.TransferSchedule{},
+		&domain.ScheduleRun{},
+		&domain.LimitOverride{},
+		&domain.Merchant{},
+		&domain.SettlementBatch{},
+		&domain.SettlementItem{},
+		&domain.PaymentIntent{},
+		&domain.MoneyRequest{},
+		&domain.MoneyRequestEvent{},
+		&domain.Bill{},
+		&domain.BillParticipant{},
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := migrateFloatMoneyColumns(db); err != nil {
+		return err
+	}
+
+	if err := migrateUserWallets(db); err != nil {
+		return err
+	}
+
+	if err := migrateOpeningBalances(db); err != nil {
+		return err
+	}
+
+	if err := dropCaseSensitivePocketIndex(db); err != nil {
+		return err
+	}
+
+	return backfillSettledAt(db)
+}
+
+// migrateFloatMoneyColumns converts the legacy float64 columns into the
+// <column>_minor/<column>_currency pairs used by money.Money and drops them.
+// Legacy rows carry no currency, so they are assumed to be DefaultCurrency.
+func migrateFloatMoneyColumns(db *gorm.DB) error {
+	exp, err := money.Exponent(domain.DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	scale := int64(math.Pow10(exp))
+
+	legacy := []struct {
+		model   interface{}
+		table   string
+		columns []string
+	}{
+		{&domain.Transaction{}, "transactions", []string{"amount", "balance_before", "balance_after"}},
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, l := range legacy {
+			for _, column := range l.columns {
+				if !tx.Migrator().HasColumn(l.model, column) {
+					continue
+				}
+
+				query := fmt.Sprintf(
+					"UPDATE %s SET %s_minor = ROUND(CAST(%s AS numeric) * ?), %s_currency = ? WHERE %s IS NOT NULL",
+					l.table, column, column, column, column,
+				)
+				if err := tx.Exec(query, scale, domain.DefaultCurrency).Error; err != nil {
+					return fmt.Errorf("failed to migrate %s.%s: %w", l.table, column, err)
+				}
+
+				if err := tx.Migrator().DropColumn(l.model, column); err != nil {
+					return fmt.Errorf("failed to drop %s.%s: %w", l.table, column, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// migrateUserWallets moves the single balance that used to live on users into
+// a wallet per user and drops the old columns. Legacy float balances carry no
+// currency and are assumed to be DefaultCurrency. Holds are recomputed from
+// the transfers that are still pending and whose recipient credit has not
+// been written yet.
+func migrateUserWallets(db *gorm.DB) error {
+	exp, err := money.Exponent(domain.DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	scale := int64(math.Pow10(exp))
+
+	migrator := db.Migrator()
+
+	var minor, currency string
+	var args []interface{}
+	switch {
+	case migrator.HasColumn("users", "balance"):
+		minor = "ROUND(CAST(users.balance AS numeric) * ?)"
+		currency = "?"
+		args = []interface{}{scale, domain.DefaultCurrency}
+	case migrator.HasColumn("users", "balance_minor"):
+		minor = "users.balance_minor"
+		currency = "COALESCE(NULLIF(users.balance_currency, ''), ?)"
+		args = []interface{}{domain.DefaultCurrency}
+	default:
+		return nil
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO wallets (id, user_id, currency, balance_minor, balance_currency, held_balance_minor, held_balance_currency, created_at, updated_at)
+		SELECT gen_random_uuid(), users.id, w.currency, w.minor, w.currency, COALESCE((
+			SELECT SUM(t.amount_minor) FROM transactions t
+			WHERE t.user_id = users.id
+				AND t.amount_currency = w.currency
+				AND t.status = ?
+				AND t.type = ?
+				AND t.target_user_id IS NOT NULL
+				AND NOT EXISTS (
+					SELECT 1 FROM transactions c
+					WHERE c.reference_id = t.id AND c.reference_type = ?
+				)
+		), 0), w.currency, NOW(), NOW()
+		FROM users, LATERAL (SELECT %s AS minor, %s AS currency) w
+		ON CONFLICT DO NOTHING`, minor, currency)
+	args = append([]interface{}{domain.TransactionStatusPending, domain.TransactionTypeDebit, domain.ReferenceTypeTransfer}, args...)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(query, args...).Error; err != nil {
+			return fmt.Errorf("failed to move balances into wallets: %w", err)
+		}
+
+		for _, column := range []string{"balance", "balance_minor", "balance_currency", "held_balance_minor", "held_balance_currency"} {
+			if !tx.Migrator().HasColumn("users", column) {
+				continue
+			}
+			if err := tx.Migrator().DropColumn("users", column); err != nil {
+				return fmt.Errorf("failed to drop users.%s: %w", column, err)
+			}
+		}
+		return nil
+	})
+}
+
+// migrateOpeningBalances posts an opening journal entry for every wallet whose
+// balance predates the ledger, so that balances can be verified against
+// postings from then on.
+func migrateOpeningBalances(db *gorm.DB) error {
+	var wallets []domain.Wallet
+	err := db.Where("balance_minor <> 0").
+		Where("NOT EXISTS (SELECT 1 FROM ledger_accounts WHERE ledger_accounts.code = 'user:' || wallets.user_id AND ledger_accounts.currency = wallets.currency)").
+		Find(&wallets).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		ledger := NewLedgerRepository(tx)
+		now := time.Now()
+
+		for _, wallet := range wallets {
+			userID := wallet.UserID
+			opening, err := ledger.GetOrCreateAccount(&domain.LedgerAccount{
+				Code:      domain.AccountOpeningBalance,
+				Currency:  wallet.Currency,
+				Type:      domain.AccountTypeEquity,
+				CreatedAt: now,
+			})
+			if err != nil {
+				return err
+			}
+
+			account, err := ledger.GetOrCreateAccount(&domain.LedgerAccount{
+				Code:      domain.UserAccountCode(userID),
+				Currency:  wallet.Currency,
+				Type:      domain.AccountTypeLiability,
+				UserID:    &userID,
+				CreatedAt: now,
+			})
+			if err != nil {
+				return err
+			}
+
+			err = ledger.CreateEntry(&domain.JournalEntry{
+				TransactionID: uuid.Nil,
+				Description:   "opening balance",
+				CreatedAt:     now,
+				Postings: []domain.Posting{
+					{AccountID: opening.ID, Amount: wallet.Balance, CreatedAt: now},
+					{AccountID: account.ID, Amount: wallet.Balance.Neg(), CreatedAt: now},
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to open ledger for user %s: %w", userID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// dropCaseSensitivePocketIndex drops the unique index on pockets (user_id,
+// name), which idx_pockets_user_lower_name replaces with a case-insensitive
+// one.
+func dropCaseSensitivePocketIndex(db *gorm.DB) error {
+	if !db.Migrator().HasIndex(&domain.Pocket{}, "idx_pockets_user_name") {
+		return nil
+	}
+	return db.Migrator().DropIndex(&domain.Pocket{}, "idx_pockets_user_name")
+}
+
+// backfillSettledAt dates successful transactions recorded before SettledAt
+// existed by their last update, which is when the legacy code applied them.
+func backfillSettledAt(db *gorm.DB) error {
+	return db.Model(&domain.Transaction{}).
+		Where("status = ? AND settled_at IS NULL", domain.TransactionStatusSuccess).
+		Update("settled_at", gorm.Expr("updated_at")).
+		Error
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type moneyRequestRepository struct {
+	db *gorm.DB
+}
+
+func NewMoneyRequestRepository(db *gorm.DB) domain.MoneyRequestRepository {
+	return &moneyRequestRepository{db: db}
+}
+
+func (r *moneyRequestRepository) Create(request *domain.MoneyRequest) error {
+	if request.ID == uuid.Nil {
+		request.ID = uuid.New()
+	}
+	return r.db.Create(request).Error
+}
+
+func (r *moneyRequestRepository) GetByID(id uuid.UUID) (*domain.MoneyRequest, error) {
+	var request domain.MoneyRequest
+	err := r.db.First(&request, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetByIDForUpdate locks the row until the surrounding transaction ends.
+func (r *moneyRequestRepository) GetByIDForUpdate(id uuid.UUID) (*domain.MoneyRequest, error) {
+	var request domain.MoneyRequest
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *moneyRequestRepository) GetByTransferIDForUpdate(transferID uuid.UUID) (*domain.MoneyRequest, error) {
+	var request domain.MoneyRequest
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, "transfer_id = ?", transferID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *moneyRequestRepository) GetIncoming(payerID uuid.UUID) ([]domain.MoneyRequest, error) {
+	var requests []domain.MoneyRequest
+	err := r.db.Where("payer_id = ?", payerID).Order("created_at desc").Find(&requests).Error
+	if err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (r *moneyRequestRepository) GetOutgoing(requesterID uuid.UUID) ([]domain.MoneyRequest, error) {
+	var requests []domain.MoneyRequest
+	err := r.db.Where("requester_id = ?", requesterID).Order("created_at desc").Find(&requests).Error
+	if err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (r *moneyRequestRepository) Update(request *domain.MoneyRequest) error {
+	return r.db.Save(request).Error
+}
+
+func (r *moneyRequestRepository) GetExpired(now time.Time, limit int) ([]domain.MoneyRequest, error) {
+	var requests []domain.MoneyRequest
+	err := r.db.Where("status = ? AND expires_at < ?", domain.MoneyRequestStatusPending, now).
+		Order("expires_at asc").
+		Limit(limit).
+		Find(&requests).Error
+	if err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (r *moneyRequestRepository) CreateEvent(event *domain.MoneyRequestEvent) error {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	return r.db.Create(event).Error
+}
+
+func (r *moneyRequestRepository) GetEvents(requestID uuid.UUID) ([]domain.MoneyRequestEvent, error) {
+	var events []domain.MoneyRequestEvent
+	err := r.db.Where("request_id = ?", requestID).Order("created_at asc").Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
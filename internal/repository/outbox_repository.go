@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) domain.OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) Create(msg *domain.OutboxMessage) error {
+	if msg.ID == uuid.Nil {
+		msg.ID = uuid.New()
+	}
+	return r.db.Create(msg).Error
+}
+
+func (r *outboxRepository) GetPendingForUpdate(limit int) ([]domain.OutboxMessage, error) {
+	var messages []domain.OutboxMessage
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ?", domain.OutboxStatusPending).
+		Order("created_at asc").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *outboxRepository) Update(msg *domain.OutboxMessage) error {
+	return r.db.Save(msg).Error
+}
+
+func (r *outboxRepository) HasPending(taskType, taskID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.OutboxMessage{}).
+		Where("task_type = ? AND task_id = ? AND status = ?", taskType, taskID, domain.OutboxStatusPending).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *outboxRepository) CancelPending(taskType, taskID string) error {
+	return r.db.Model(&domain.OutboxMessage{}).
+		Where("task_type = ? AND task_id = ? AND status = ?", taskType, taskID, domain.OutboxStatusPending).
+		Update("status", domain.OutboxStatusCancelled).Error
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type paymentIntentRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentIntentRepository(db *gorm.DB) domain.PaymentIntentRepository {
+	return &paymentIntentRepository{db: db}
+}
+
+func (r *paymentIntentRepository) Create(intent *domain.PaymentIntent) error {
+	if intent.ID == uuid.Nil {
+		intent.ID = uuid.New()
+	}
+	return r.db.Create(intent).Error
+}
+
+func (r *paymentIntentRepository) GetByID(id uuid.UUID) (*domain.PaymentIntent, error) {
+	var intent domain.PaymentIntent
+	err := r.db.First(&intent, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+// GetByIDForUpdate locks the row until the surrounding transaction ends.
+func (r *paymentIntentRepository) GetByIDForUpdate(id uuid.UUID) (*domain.PaymentIntent, error) {
+	var intent domain.PaymentIntent
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&intent, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+func (r *paymentIntentRepository) GetByMerchantID(merchantID uuid.UUID) ([]domain.PaymentIntent, error) {
+	var intents []domain.PaymentIntent
+	err := r.db.Where("merchant_id = ?", merchantID).Order("created_at desc").Find(&intents).Error
+	if err != nil {
+		return nil, err
+	}
+	return intents, nil
+}
+
+func (r *paymentIntentRepository) Update(intent *domain.PaymentIntent) error {
+	return r.db.Save(intent).Error
+}
+
+func (r *paymentIntentRepository) GetExpired(now time.Time, limit int) ([]domain.PaymentIntent, error) {
+	var intents []domain.PaymentIntent
+	err := r.db.
+		Where("status IN ? AND expires_at < ?", []domain.PaymentIntentStatus{
+			domain.PaymentIntentStatusCreated,
+			domain.PaymentIntentStatusRequiresConfirmation,
+		}, now).
+		Order("expires_at asc").
+		Limit(limit).
+		Find(&intents).Error
+	if err != nil {
+		return nil, err
+	}
+	return intents, nil
+}
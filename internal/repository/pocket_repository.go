@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type pocketRepository struct {
+	db *gorm.DB
+}
+
+func NewPocketRepository(db *gorm.DB) domain.PocketRepository {
+	return &pocketRepository{db: db}
+}
+
+func (r *pocketRepository) Create(pocket *domain.Pocket) error {
+	if pocket.ID == uuid.Nil {
+		pocket.ID = uuid.New()
+	}
+
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(pocket)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrPocketNameTaken
+	}
+	return nil
+}
+
+func (r *pocketRepository) GetByID(id uuid.UUID) (*domain.Pocket, error) {
+	var pocket domain.Pocket
+	err := r.db.First(&pocket, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pocket, nil
+}
+
+// GetByIDForUpdate locks the row until the surrounding transaction ends.
+func (r *pocketRepository) GetByIDForUpdate(id uuid.UUID) (*domain.Pocket, error) {
+	var pocket domain.Pocket
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&pocket, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pocket, nil
+}
+
+func (r *pocketRepository) GetByUserID(userID uuid.UUID) ([]domain.Pocket, error) {
+	var pockets []domain.Pocket
+	err := r.db.Where("user_id = ?", userID).Order("created_at asc").Find(&pockets).Error
+	if err != nil {
+		return nil, err
+	}
+	return pockets, nil
+}
+
+func (r *pocketRepository) UpdateBalance(pocketID uuid.UUID, balance money.Money) error {
+	return r.db.Model(&domain.Pocket{}).
+		Where("id = ?", pocketID).
+		Updates(map[string]interface{}{
+			"balance_minor":    balance.Minor,
+			"balance_currency": balance.Currency,
+			"updated_at":       time.Now(),
+		}).
+		Error
+}
+
+func (r *pocketRepository) Delete(pocketID uuid.UUID) error {
+	return r.db.Delete(&domain.Pocket{}, "id = ?", pocketID).Error
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type reconciliationRepository struct {
+	db *gorm.DB
+}
+
+func NewReconciliationRepository(db *gorm.DB) domain.ReconciliationRepository {
+	return &reconciliationRepository{db: db}
+}
+
+func (r *reconciliationRepository) CreateRun(run *domain.ReconciliationRun) error {
+	if run.ID == uuid.Nil {
+		run.ID = uuid.New()
+	}
+	return r.db.Omit("Discrepancies").Create(run).Error
+}
+
+func (r *reconciliationRepository) UpdateRun(run *domain.ReconciliationRun) error {
+	return r.db.Omit("Discrepancies").Save(run).Error
+}
+
+func (r *reconciliationRepository) CreateDiscrepancy(discrepancy *domain.ReconciliationDiscrepancy) error {
+	if discrepancy.ID == uuid.Nil {
+		discrepancy.ID = uuid.New()
+	}
+	return r.db.Create(discrepancy).Error
+}
+
+func (r *reconciliationRepository) ListRuns(limit int) ([]domain.ReconciliationRun, error) {
+	var runs []domain.ReconciliationRun
+	err := r.db.Order("started_at desc").Limit(limit).Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (r *reconciliationRepository) GetRun(id uuid.UUID) (*domain.ReconciliationRun, error) {
+	var run domain.ReconciliationRun
+	err := r.db.Preload("Discrepancies", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at asc")
+	}).First(&run, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type scheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduleRepository(db *gorm.DB) domain.ScheduleRepository {
+	return &scheduleRepository{db: db}
+}
+
+func (r *scheduleRepository) Create(schedule *domain.TransferSchedule) error {
+	if schedule.ID == uuid.Nil {
+		schedule.ID = uuid.New()
+	}
+	return r.db.Create(schedule).Error
+}
+
+func (r *scheduleRepository) GetByID(id uuid.UUID) (*domain.TransferSchedule, error) {
+	var schedule domain.TransferSchedule
+	err := r.db.First(&schedule, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// GetByIDForUpdate locks the row until the surrounding transaction ends.
+func (r *scheduleRepository) GetByIDForUpdate(id uuid.UUID) (*domain.TransferSchedule, error) {
+	var schedule domain.TransferSchedule
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&schedule, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *scheduleRepository) GetByUserID(userID uuid.UUID) ([]domain.TransferSchedule, error) {
+	var schedules []domain.TransferSchedule
+	err := r.db.Where("user_id = ?", userID).Order("created_at asc").Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *scheduleRepository) GetDue(t time.Time, limit int) ([]domain.TransferSchedule, error) {
+	var schedules []domain.TransferSchedule
+	err := r.db.Where("status = ? AND next_run_at <= ?", domain.ScheduleStatusActive, t).
+		Order("next_run_at asc").
+		Limit(limit).
+		Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *scheduleRepository) Update(schedule *domain.TransferSchedule) error {
+	return r.db.Save(schedule).Error
+}
+
+func (r *scheduleRepository) Delete(id uuid.UUID) error {
+	if err := r.db.Delete(&domain.ScheduleRun{}, "schedule_id = ?", id).Error; err != nil {
+		return err
+	}
+	return r.db.Delete(&domain.TransferSchedule{}, "id = ?", id).Error
+}
+
+func (r *scheduleRepository) CreateRun(run *domain.ScheduleRun) error {
+	if run.ID == uuid.Nil {
+		run.ID = uuid.New()
+	}
+	return r.db.Create(run).Error
+}
+
+func (r *scheduleRepository) GetRuns(scheduleID uuid.UUID) ([]domain.ScheduleRun, error) {
+	var runs []domain.ScheduleRun
+	err := r.db.Where("schedule_id = ?", scheduleID).Order("scheduled_for desc").Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
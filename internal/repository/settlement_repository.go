@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type settlementRepository struct {
+	db *gorm.DB
+}
+
+func NewSettlementRepository(db *gorm.DB) domain.SettlementRepository {
+	return &settlementRepository{db: db}
+}
+
+func (r *settlementRepository) CreateBatch(batch *domain.SettlementBatch) error {
+	if batch.ID == uuid.Nil {
+		batch.ID = uuid.New()
+	}
+	return r.db.Create(batch).Error
+}
+
+func (r *settlementRepository) CreateItems(items []domain.SettlementItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return r.db.Create(&items).Error
+}
+
+func (r *settlementRepository) GetBatch(id uuid.UUID) (*domain.SettlementBatch, error) {
+	var batch domain.SettlementBatch
+	err := r.db.First(&batch, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// GetBatchForUpdate locks the row until the surrounding transaction ends.
+func (r *settlementRepository) GetBatchForUpdate(id uuid.UUID) (*domain.SettlementBatch, error) {
+	var batch domain.SettlementBatch
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&batch, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func (r *settlementRepository) GetBatchesByMerchantID(merchantID uuid.UUID) ([]domain.SettlementBatch, error) {
+	var batches []domain.SettlementBatch
+	err := r.db.Where("merchant_id = ?", merchantID).Order("created_at desc").Find(&batches).Error
+	if err != nil {
+		return nil, err
+	}
+	return batches, nil
+}
+
+func (r *settlementRepository) GetItems(batchID uuid.UUID) ([]domain.SettlementItem, error) {
+	var items []domain.SettlementItem
+	err := r.db.Where("batch_id = ?", batchID).Order("transacted_at asc").Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *settlementRepository) UpdateBatch(batch *domain.SettlementBatch) error {
+	return r.db.Save(batch).Error
+}
+
+func (r *settlementRepository) GetUnsettled(merchantID uuid.UUID, cutoff time.Time) ([]domain.Transaction, error) {
+	var transactions []domain.Transaction
+	err := r.db.
+		Where("merchant_id = ? AND status = ? AND settled_at < ?", merchantID, domain.TransactionStatusSuccess, cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM settlement_items WHERE settlement_items.transaction_id = transactions.id)").
+		Order("settled_at asc").
+		Find(&transactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
@@ -1,9 +1,12 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/bangadam/wallet-api/internal/domain"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type transactionRepository struct {
@@ -39,6 +42,88 @@ func (r *transactionRepository) GetByID(id uuid.UUID) (*domain.Transaction, erro
 	return &transaction, nil
 }
 
+// GetByIDForUpdate locks the row until the surrounding transaction ends.
+func (r *transactionRepository) GetByIDForUpdate(id uuid.UUID) (*domain.Transaction, error) {
+	var transaction domain.Transaction
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transaction, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (r *transactionRepository) GetByReference(referenceID uuid.UUID, referenceType string) ([]domain.Transaction, error) {
+	var transactions []domain.Transaction
+	err := r.db.Where("reference_id = ? AND reference_type = ?", referenceID, referenceType).
+		Order("created_at asc").
+		Find(&transactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+func (r *transactionRepository) GetByMerchantID(merchantID uuid.UUID) ([]domain.Transaction, error) {
+	var transactions []domain.Transaction
+	err := r.db.Where("merchant_id = ?", merchantID).Order("created_at desc").Find(&transactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+func (r *transactionRepository) GetPendingTransfers(createdBefore time.Time, limit int) ([]domain.Transaction, error) {
+	var transactions []domain.Transaction
+	err := r.db.Where("status = ? AND target_user_id IS NOT NULL AND created_at < ?", domain.TransactionStatusPending, createdBefore).
+		Order("created_at asc").
+		Limit(limit).
+		Find(&transactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+func (r *transactionRepository) GetExpiredAuthorizations(expiredBefore time.Time, limit int) ([]domain.Transaction, error) {
+	var transactions []domain.Transaction
+	err := r.db.Where("status = ? AND expires_at < ?", domain.TransactionStatusAuthorized, expiredBefore).
+		Order("expires_at asc").
+		Limit(limit).
+		Find(&transactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+func (r *transactionRepository) SumOutgoing(userID uuid.UUID, currency string, since time.Time) (int64, error) {
+	var sum int64
+	err := r.db.Model(&domain.Transaction{}).
+		Select("COALESCE(SUM(amount_minor), 0)").
+		Where("user_id = ? AND type = ? AND amount_currency = ? AND created_at >= ?",
+			userID, domain.TransactionTypeDebit, currency, since).
+		Where("status IN ?", []domain.TransactionStatus{
+			domain.TransactionStatusSuccess, domain.TransactionStatusPending, domain.TransactionStatusAuthorized,
+		}).
+		Where("reference_type IN ?", []string{"", domain.ReferenceTypeAuthorization}).
+		Scan(&sum).Error
+	return sum, err
+}
+
+func (r *transactionRepository) SumIncoming(userID uuid.UUID, currency string, since time.Time) (int64, error) {
+	var sum int64
+	err := r.db.Model(&domain.Transaction{}).
+		Select("COALESCE(SUM(amount_minor), 0)").
+		Where("amount_currency = ? AND created_at >= ?", currency, since).
+		Where(r.db.
+			Where("user_id = ? AND type = ? AND status = ? AND reference_type IN ?",
+				userID, domain.TransactionTypeCredit, domain.TransactionStatusSuccess, []string{"", domain.ReferenceTypeTransfer}).
+			Or("target_user_id = ? AND type = ? AND status = ?",
+				userID, domain.TransactionTypeDebit, domain.TransactionStatusPending)).
+		Scan(&sum).Error
+	return sum, err
+}
+
 func (r *transactionRepository) Update(tx *domain.Transaction) error {
 	return r.db.Save(tx).Error
 }
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"github.com/bangadam/wallet-api/internal/domain"
+	"gorm.io/gorm"
+)
+
+type unitOfWork struct {
+	db *gorm.DB
+}
+
+func NewUnitOfWork(db *gorm.DB) domain.UnitOfWork {
+	return &unitOfWork{db: db}
+}
+
+func (u *unitOfWork) Do(fn func(repos *domain.Repositories) error) error {
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&domain.Repositories{
+			Users:         NewUserRepository(tx),
+			Wallets:       NewWalletRepository(tx),
+			Pockets:       NewPocketRepository(tx),
+			Transactions:  NewTransactionRepository(tx),
+			Ledger:        NewLedgerRepository(tx),
+			Outbox:        NewOutboxRepository(tx),
+			Notifications: NewNotificationRepository(tx),
+			FX:            NewFXRepository(tx),
+			Schedules:     NewScheduleRepository(tx),
+			Limits:        NewLimitRepository(tx),
+			Merchants:     NewMerchantRepository(tx),
+			Settlements:   NewSettlementRepository(tx),
+			Intents:       NewPaymentIntentRepository(tx),
+			Requests:      NewMoneyRequestRepository(tx),
+			Bills:         NewBillRepository(tx),
+		})
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/bangadam/wallet-api/internal/domain"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type userRepository struct {
@@ -39,13 +40,32 @@ func (r *userRepository) GetByID(id uuid.UUID) (*domain.User, error) {
 	return &user, nil
 }
 
+// GetByIDForUpdate locks the row until the surrounding transaction ends.
+func (r *userRepository) GetByIDForUpdate(id uuid.UUID) (*domain.User, error) {
+	var user domain.User
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *userRepository) Update(user *domain.User) error {
 	return r.db.Save(user).Error
 }
 
-func (r *userRepository) UpdateBalance(userID uuid.UUID, amount float64) error {
+func (r *userRepository) UpdateStatus(userID uuid.UUID, status domain.UserStatus) error {
 	return r.db.Model(&domain.User{}).
 		Where("id = ?", userID).
-		Update("balance", amount).
+		Update("status", status).
 		Error
 }
+
+func (r *userRepository) List(afterID uuid.UUID, limit int) ([]domain.User, error) {
+	var users []domain.User
+	err := r.db.Where("id > ?", afterID).Order("id asc").Limit(limit).Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
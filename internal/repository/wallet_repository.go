@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type walletRepository struct {
+	db *gorm.DB
+}
+
+func NewWalletRepository(db *gorm.DB) domain.WalletRepository {
+	return &walletRepository{db: db}
+}
+
+func (r *walletRepository) GetOrCreate(userID uuid.UUID, currency string) (*domain.Wallet, error) {
+	now := time.Now()
+	wallet := &domain.Wallet{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Currency:    currency,
+		Balance:     money.Zero(currency),
+		HeldBalance: money.Zero(currency),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(wallet).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Get(userID, currency)
+}
+
+func (r *walletRepository) Get(userID uuid.UUID, currency string) (*domain.Wallet, error) {
+	var wallet domain.Wallet
+	err := r.db.Where("user_id = ? AND currency = ?", userID, currency).First(&wallet).Error
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// GetForUpdate locks the row until the surrounding transaction ends.
+func (r *walletRepository) GetForUpdate(userID uuid.UUID, currency string) (*domain.Wallet, error) {
+	var wallet domain.Wallet
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND currency = ?", userID, currency).
+		First(&wallet).Error
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (r *walletRepository) GetByUserID(userID uuid.UUID) ([]domain.Wallet, error) {
+	var wallets []domain.Wallet
+	err := r.db.Where("user_id = ?", userID).Order("currency asc").Find(&wallets).Error
+	if err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
+func (r *walletRepository) UpdateBalance(walletID uuid.UUID, balance money.Money) error {
+	return r.db.Model(&domain.Wallet{}).
+		Where("id = ?", walletID).
+		Updates(map[string]interface{}{
+			"balance_minor":    balance.Minor,
+			"balance_currency": balance.Currency,
+			"updated_at":       time.Now(),
+		}).
+		Error
+}
+
+func (r *walletRepository) UpdateHeldBalance(walletID uuid.UUID, held money.Money) error {
+	return r.db.Model(&domain.Wallet{}).
+		Where("id = ?", walletID).
+		Updates(map[string]interface{}{
+			"held_balance_minor":    held.Minor,
+			"held_balance_currency": held.Currency,
+			"updated_at":            time.Now(),
+		}).
+		Error
+}
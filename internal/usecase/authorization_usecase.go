@@ -0,0 +1,326 @@
+package usecase
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAuthorizationNotFound = errors.New("authorization not found")
+	ErrAuthorizationClosed   = errors.New("authorization is no longer open")
+	ErrAuthorizationExpired  = errors.New("authorization has expired")
+	ErrCaptureExceedsHold    = errors.New("capture amount exceeds the authorized amount")
+)
+
+type AuthorizationConfig struct {
+	// DefaultTTL is used when an authorization does not ask for one; no
+	// authorization may stay open longer than MaxTTL.
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+	BatchSize  int
+}
+
+// AuthorizationUsecase runs two-phase payments to merchants. The payer
+// authorizes a payment to a merchant, which places a hold on their wallet for
+// the amount and its PAYMENT fee. The merchant's owner then captures all or
+// part of it as a payment to the merchant, releasing the rest, or voids it;
+// expiry releases it too.
+type AuthorizationUsecase struct {
+	uow             domain.UnitOfWork
+	transactionRepo domain.TransactionRepository
+	merchantRepo    domain.MerchantRepository
+	limits          *LimitsUsecase
+	fees            *FeeUsecase
+	config          AuthorizationConfig
+}
+
+func NewAuthorizationUsecase(uow domain.UnitOfWork, transactionRepo domain.TransactionRepository, merchantRepo domain.MerchantRepository, limits *LimitsUsecase, fees *FeeUsecase, config AuthorizationConfig) *AuthorizationUsecase {
+	return &AuthorizationUsecase{
+		uow:             uow,
+		transactionRepo: transactionRepo,
+		merchantRepo:    merchantRepo,
+		limits:          limits,
+		fees:            fees,
+		config:          config,
+	}
+}
+
+func (u *AuthorizationUsecase) Authorize(userID, merchantID uuid.UUID, amount money.Money, remarks string, ttl time.Duration) (*domain.Transaction, error) {
+	if ttl <= 0 {
+		ttl = u.config.DefaultTTL
+	}
+	if ttl > u.config.MaxTTL {
+		ttl = u.config.MaxTTL
+	}
+
+	var tx *domain.Transaction
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		merchant, err := repos.Merchants.GetByID(merchantID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMerchantNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if merchant.Status != domain.MerchantStatusActive {
+			return ErrMerchantSuspended
+		}
+		if !merchant.Balance.SameCurrency(amount) {
+			return money.ErrCurrencyMismatch
+		}
+
+		fee, err := u.fees.feeOf(repos, userID, domain.FeeTypePayment, amount, "")
+		if err != nil {
+			return err
+		}
+
+		if err := u.limits.CheckOutgoing(repos, userID, amount); err != nil {
+			return err
+		}
+
+		wallet, err := placeHold(repos, userID, amount)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(ttl)
+		tx = &domain.Transaction{
+			ID:            uuid.New(),
+			UserID:        userID,
+			Type:          domain.TransactionTypeDebit,
+			Status:        domain.TransactionStatusAuthorized,
+			Amount:        amount,
+			Remarks:       remarks,
+			BalanceBefore: wallet.Balance,
+			BalanceAfter:  wallet.Balance.Sub(amount),
+			MerchantID:    &merchantID,
+			ExpiresAt:     &expiresAt,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := repos.Transactions.Create(tx); err != nil {
+			return err
+		}
+
+		return holdFee(repos, tx, fee.Fee)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// GetAuthorization returns an authorization to its payer or to the owner of
+// its merchant.
+func (u *AuthorizationUsecase) GetAuthorization(userID, authorizationID uuid.UUID) (*domain.Transaction, error) {
+	auth, err := u.transactionRepo.GetByID(authorizationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAuthorizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if auth.ExpiresAt == nil || auth.MerchantID == nil {
+		return nil, ErrAuthorizationNotFound
+	}
+	if auth.UserID == userID {
+		return auth, nil
+	}
+	if _, err := ownedMerchant(u.merchantRepo, userID, *auth.MerchantID); err != nil {
+		return nil, ErrAuthorizationNotFound
+	}
+
+	return auth, nil
+}
+
+// Capture charges amount from an open authorization, or all of it when amount
+// is zero, pays it to the merchant and releases whatever was not captured.
+// Only the merchant's owner may capture. The fee is that of a payment of the
+// captured amount, taken from the fee held at authorization and never more
+// than it. It returns the payment.
+func (u *AuthorizationUsecase) Capture(ownerID, authorizationID uuid.UUID, amount money.Money) (*domain.Transaction, error) {
+	var payment *domain.Transaction
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		auth, err := lockAuthorization(repos, ownerID, authorizationID)
+		if err != nil {
+			return err
+		}
+
+		if time.Now().After(*auth.ExpiresAt) {
+			return ErrAuthorizationExpired
+		}
+
+		if amount.IsZero() {
+			amount = auth.Amount
+		}
+		if !amount.SameCurrency(auth.Amount) {
+			return money.ErrCurrencyMismatch
+		}
+		if auth.Amount.LessThan(amount) {
+			return ErrCaptureExceedsHold
+		}
+
+		userID := auth.UserID
+		before, after, err := captureHold(repos, userID, amount)
+		if err != nil {
+			return err
+		}
+
+		if remaining := auth.Amount.Sub(amount); remaining.IsPositive() {
+			if err := releaseHold(repos, userID, remaining); err != nil {
+				return err
+			}
+		}
+
+		if err := creditMerchant(repos, *auth.MerchantID, amount); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		payment = &domain.Transaction{
+			ID:            uuid.New(),
+			UserID:        userID,
+			Type:          domain.TransactionTypeDebit,
+			Status:        domain.TransactionStatusSuccess,
+			Amount:        amount,
+			Remarks:       auth.Remarks,
+			BalanceBefore: before,
+			BalanceAfter:  after,
+			MerchantID:    auth.MerchantID,
+			ReferenceID:   auth.ID,
+			ReferenceType: domain.ReferenceTypeAuthorization,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+			SettledAt:     &now,
+		}
+		if err := repos.Transactions.Create(payment); err != nil {
+			return err
+		}
+
+		err = postJournal(repos, payment.ID, "payment",
+			debitLine(userAccount(userID), amount),
+			creditLine(merchantAccount(*auth.MerchantID), amount),
+		)
+		if err != nil {
+			return err
+		}
+
+		fee, err := u.fees.feeOf(repos, userID, domain.FeeTypePayment, amount, "")
+		if err != nil {
+			return err
+		}
+		if err := captureFee(repos, auth, fee.Fee); err != nil {
+			return err
+		}
+
+		auth.Status = domain.TransactionStatusCaptured
+		auth.UpdatedAt = now
+		return repos.Transactions.Update(auth)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// Void cancels an open authorization on behalf of the merchant's owner and
+// releases its hold.
+func (u *AuthorizationUsecase) Void(ownerID, authorizationID uuid.UUID) (*domain.Transaction, error) {
+	var auth *domain.Transaction
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		var err error
+		auth, err = lockAuthorization(repos, ownerID, authorizationID)
+		if err != nil {
+			return err
+		}
+
+		return closeAuthorization(repos, auth, domain.TransactionStatusVoided)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return auth, nil
+}
+
+// ExpireAuthorizations releases the holds of authorizations that were neither
+// captured nor voided in time and returns how many it expired.
+func (u *AuthorizationUsecase) ExpireAuthorizations() (int, error) {
+	now := time.Now()
+	authorizations, err := u.transactionRepo.GetExpiredAuthorizations(now, u.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, auth := range authorizations {
+		err := u.uow.Do(func(repos *domain.Repositories) error {
+			locked, err := repos.Transactions.GetByIDForUpdate(auth.ID)
+			if err != nil {
+				return err
+			}
+			if locked.Status != domain.TransactionStatusAuthorized || !now.After(*locked.ExpiresAt) {
+				return nil
+			}
+
+			if err := closeAuthorization(repos, locked, domain.TransactionStatusExpired); err != nil {
+				return err
+			}
+			expired++
+			return nil
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+
+	return expired, nil
+}
+
+// lockAuthorization locks an authorization to a merchant owned by ownerID.
+// Authorizations of other merchants are reported as not found.
+func lockAuthorization(repos *domain.Repositories, ownerID, authorizationID uuid.UUID) (*domain.Transaction, error) {
+	auth, err := repos.Transactions.GetByIDForUpdate(authorizationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAuthorizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if auth.ExpiresAt == nil || auth.MerchantID == nil {
+		return nil, ErrAuthorizationNotFound
+	}
+	if _, err := ownedMerchant(repos.Merchants, ownerID, *auth.MerchantID); err != nil {
+		return nil, ErrAuthorizationNotFound
+	}
+	if auth.Status != domain.TransactionStatusAuthorized {
+		return nil, ErrAuthorizationClosed
+	}
+
+	return auth, nil
+}
+
+// closeAuthorization releases the hold of a locked open authorization and of
+// its fee.
+func closeAuthorization(repos *domain.Repositories, auth *domain.Transaction, status domain.TransactionStatus) error {
+	if err := releaseHold(repos, auth.UserID, auth.Amount); err != nil {
+		return err
+	}
+
+	auth.Status = status
+	auth.UpdatedAt = time.Now()
+	if err := releaseFee(repos, auth); err != nil {
+		return err
+	}
+	return repos.Transactions.Update(auth)
+}
@@ -0,0 +1,213 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAuthorizationUsecase(store *memStore, fees *FeeUsecase) *AuthorizationUsecase {
+	return NewAuthorizationUsecase(store, store.repos().Transactions, store.repos().Merchants, noLimits(store), fees, AuthorizationConfig{
+		DefaultTTL: time.Hour,
+		MaxTTL:     24 * time.Hour,
+		BatchSize:  100,
+	})
+}
+
+func TestAuthorizationUsecase(t *testing.T) {
+	t.Run("captures part of an authorization for the merchant and releases the rest", func(t *testing.T) {
+		store := newMemStore()
+		_, merchant := newTestMerchant(t, store)
+		userID := store.addUser(idr(10000))
+		uc := newTestAuthorizationUsecase(store, noFees(store))
+
+		auth, err := uc.Authorize(userID, merchant.ID, idr(4000), "hotel", 0)
+		require.NoError(t, err)
+		assert.Equal(t, domain.TransactionStatusAuthorized, auth.Status)
+		assert.Equal(t, merchant.ID, *auth.MerchantID)
+		assert.Equal(t, idr(4000), store.wallet(userID).HeldBalance)
+
+		_, err = uc.Capture(merchant.OwnerID, auth.ID, idr(5000))
+		assert.ErrorIs(t, err, ErrCaptureExceedsHold)
+
+		payment, err := uc.Capture(merchant.OwnerID, auth.ID, idr(3000))
+		require.NoError(t, err)
+		assert.Equal(t, auth.ID, payment.ReferenceID)
+		assert.Equal(t, userID, payment.UserID)
+		assert.Equal(t, merchant.ID, *payment.MerchantID)
+		assert.Equal(t, domain.TransactionStatusCaptured, store.transactions[auth.ID].Status)
+		assert.Equal(t, idr(7000), store.wallet(userID).Balance)
+		assert.Equal(t, idr(0), store.wallet(userID).HeldBalance)
+		assert.Equal(t, idr(3000), store.merchants[merchant.ID].Balance)
+
+		_, err = uc.Capture(merchant.OwnerID, auth.ID, money.Money{})
+		assert.ErrorIs(t, err, ErrAuthorizationClosed)
+	})
+
+	t.Run("only the merchant's owner can capture or void", func(t *testing.T) {
+		store := newMemStore()
+		_, merchant := newTestMerchant(t, store)
+		userID := store.addUser(idr(10000))
+		strangerID := store.addUser(idr(0))
+		uc := newTestAuthorizationUsecase(store, noFees(store))
+
+		auth, err := uc.Authorize(userID, merchant.ID, idr(4000), "hotel", 0)
+		require.NoError(t, err)
+
+		for _, id := range []uuid.UUID{userID, strangerID} {
+			_, err = uc.Capture(id, auth.ID, money.Money{})
+			assert.ErrorIs(t, err, ErrAuthorizationNotFound)
+			_, err = uc.Void(id, auth.ID)
+			assert.ErrorIs(t, err, ErrAuthorizationNotFound)
+		}
+		assert.Equal(t, domain.TransactionStatusAuthorized, store.transactions[auth.ID].Status)
+		assert.Equal(t, idr(4000), store.wallet(userID).HeldBalance)
+
+		_, err = uc.GetAuthorization(userID, auth.ID)
+		assert.NoError(t, err, "the payer can see the authorization")
+		_, err = uc.GetAuthorization(merchant.OwnerID, auth.ID)
+		assert.NoError(t, err, "the merchant's owner can see the authorization")
+		_, err = uc.GetAuthorization(strangerID, auth.ID)
+		assert.ErrorIs(t, err, ErrAuthorizationNotFound)
+	})
+
+	t.Run("refuses authorizations to unknown or suspended merchants", func(t *testing.T) {
+		store := newMemStore()
+		merchants, merchant := newTestMerchant(t, store)
+		userID := store.addUser(idr(10000))
+		uc := newTestAuthorizationUsecase(store, noFees(store))
+
+		_, err := uc.Authorize(userID, uuid.New(), idr(4000), "hotel", 0)
+		assert.ErrorIs(t, err, ErrMerchantNotFound)
+
+		err = merchants.UpdateStatus(merchant.ID, domain.MerchantStatusSuspended)
+		require.NoError(t, err)
+		_, err = uc.Authorize(userID, merchant.ID, idr(4000), "hotel", 0)
+		assert.ErrorIs(t, err, ErrMerchantSuspended)
+		assert.Equal(t, idr(0), store.wallet(userID).HeldBalance)
+	})
+
+	t.Run("voids an authorization", func(t *testing.T) {
+		store := newMemStore()
+		_, merchant := newTestMerchant(t, store)
+		userID := store.addUser(idr(10000))
+		uc := newTestAuthorizationUsecase(store, noFees(store))
+
+		auth, err := uc.Authorize(userID, merchant.ID, idr(4000), "hotel", 0)
+		require.NoError(t, err)
+
+		_, err = uc.Void(merchant.OwnerID, auth.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.TransactionStatusVoided, store.transactions[auth.ID].Status)
+		assert.Equal(t, idr(10000), store.wallet(userID).Balance)
+		assert.Equal(t, idr(0), store.wallet(userID).HeldBalance)
+	})
+
+	t.Run("expires lapsed authorizations", func(t *testing.T) {
+		store := newMemStore()
+		_, merchant := newTestMerchant(t, store)
+		userID := store.addUser(idr(10000))
+		uc := newTestAuthorizationUsecase(store, noFees(store))
+
+		lapsed, err := uc.Authorize(userID, merchant.ID, idr(1000), "hotel", 0)
+		require.NoError(t, err)
+		open, err := uc.Authorize(userID, merchant.ID, idr(2000), "taxi", 0)
+		require.NoError(t, err)
+
+		tx := store.transactions[lapsed.ID]
+		past := time.Now().Add(-time.Minute)
+		tx.ExpiresAt = &past
+		store.transactions[lapsed.ID] = tx
+
+		_, err = uc.Capture(merchant.OwnerID, lapsed.ID, money.Money{})
+		assert.ErrorIs(t, err, ErrAuthorizationExpired)
+
+		expired, err := uc.ExpireAuthorizations()
+		require.NoError(t, err)
+		assert.Equal(t, 1, expired)
+		assert.Equal(t, domain.TransactionStatusExpired, store.transactions[lapsed.ID].Status)
+		assert.Equal(t, domain.TransactionStatusAuthorized, store.transactions[open.ID].Status)
+		assert.Equal(t, idr(2000), store.wallet(userID).HeldBalance)
+	})
+}
+
+func TestAuthorizationUsecase_Fees(t *testing.T) {
+	setup := func(t *testing.T) (*memStore, *domain.Merchant, *AuthorizationUsecase) {
+		store := newMemStore()
+		_, merchant := newTestMerchant(t, store)
+		uc := newTestAuthorizationUsecase(store, newTestFeeUsecase(t, store))
+		return store, merchant, uc
+	}
+
+	t.Run("charges the same fee as a direct payment", func(t *testing.T) {
+		store, merchant, uc := setup(t)
+		userID := store.addUser(idr(100000))
+		payerID := store.addUser(idr(100000))
+		repos := store.repos()
+		payments := newTransactionUsecaseWith(store, noLimits(store), newTestFeeUsecase(t, store))
+
+		direct, err := payments.Payment(payerID, idr(50000), "groceries", PaymentOptions{MerchantID: merchant.ID})
+		require.NoError(t, err)
+		directFees := store.transactionsByReference(direct.ID, domain.ReferenceTypeFee)
+		require.Len(t, directFees, 1)
+
+		auth, err := uc.Authorize(userID, merchant.ID, idr(50000), "groceries", 0)
+		require.NoError(t, err)
+		assert.Equal(t, idr(50000).Add(directFees[0].Amount), store.wallet(userID).HeldBalance, "the fee is held with the amount")
+
+		_, err = uc.Capture(merchant.OwnerID, auth.ID, money.Money{})
+		require.NoError(t, err)
+
+		fees := store.transactionsByReference(auth.ID, domain.ReferenceTypeFee)
+		require.Len(t, fees, 1)
+		assert.Equal(t, directFees[0].Amount, fees[0].Amount)
+		assert.Equal(t, domain.TransactionStatusSuccess, fees[0].Status)
+		assert.Equal(t, store.wallet(payerID).Balance, store.wallet(userID).Balance)
+		assert.Equal(t, idr(0), store.wallet(userID).HeldBalance)
+		ledger, err := ledgerBalance(repos.Ledger, userID, "IDR")
+		require.NoError(t, err)
+		directLedger, err := ledgerBalance(repos.Ledger, payerID, "IDR")
+		require.NoError(t, err)
+		assert.Equal(t, directLedger, ledger)
+	})
+
+	t.Run("charges the fee of the captured part only", func(t *testing.T) {
+		store, merchant, uc := setup(t)
+		userID := store.addUser(idr(200000))
+
+		auth, err := uc.Authorize(userID, merchant.ID, idr(150000), "hotel", 0)
+		require.NoError(t, err)
+		assert.Equal(t, idr(151500), store.wallet(userID).HeldBalance)
+
+		_, err = uc.Capture(merchant.OwnerID, auth.ID, idr(80000))
+		require.NoError(t, err)
+
+		fees := store.transactionsByReference(auth.ID, domain.ReferenceTypeFee)
+		require.Len(t, fees, 1)
+		assert.Equal(t, idr(800), fees[0].Amount)
+		assert.Equal(t, idr(200000-80000-800), store.wallet(userID).Balance)
+		assert.Equal(t, idr(0), store.wallet(userID).HeldBalance)
+	})
+
+	t.Run("releases the fee when the authorization is voided", func(t *testing.T) {
+		store, merchant, uc := setup(t)
+		userID := store.addUser(idr(100000))
+
+		auth, err := uc.Authorize(userID, merchant.ID, idr(50000), "hotel", 0)
+		require.NoError(t, err)
+
+		_, err = uc.Void(merchant.OwnerID, auth.ID)
+		require.NoError(t, err)
+
+		fees := store.transactionsByReference(auth.ID, domain.ReferenceTypeFee)
+		require.Len(t, fees, 1)
+		assert.Equal(t, domain.TransactionStatusVoided, fees[0].Status)
+		assert.Equal(t, idr(100000), store.wallet(userID).Balance)
+		assert.Equal(t, idr(0), store.wallet(userID).HeldBalance)
+	})
+}
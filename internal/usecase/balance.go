@@ -0,0 +1,248 @@
+package usecase
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInsufficientBalance = errors.New("balance is not enough")
+	ErrRecipientNotFound   = errors.New("recipient not found")
+	ErrWalletFrozen        = errors.New("wallet is frozen")
+	ErrCrossCurrency       = errors.New("amount and target currency differ; request a conversion to move money across currencies")
+	ErrSelfTransfer        = errors.New("cannot transfer to yourself")
+)
+
+// The helpers below lock the user row and then the wallet row with
+// SELECT ... FOR UPDATE and must be called from inside UnitOfWork.Do so the
+// locks, the balance change and the transaction row commit together. The
+// wallet is picked by the currency of the amount. Balance is the ledger
+// balance; funds on hold for pending transfers are part of it but not
+// available to spend.
+
+// lockWallet locks the user's wallet in the currency of amount. Wallets that
+// do not exist yet are opened when open is set, otherwise the user has no
+// funds in that currency.
+func lockWallet(repos *domain.Repositories, userID uuid.UUID, amount money.Money, open bool) (*domain.Wallet, error) {
+	user, err := repos.Users.GetByIDForUpdate(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Status == domain.UserStatusFrozen {
+		return nil, ErrWalletFrozen
+	}
+
+	if err := checkAmount(amount); err != nil {
+		return nil, err
+	}
+
+	if open {
+		if _, err := repos.Wallets.GetOrCreate(userID, amount.Currency); err != nil {
+			return nil, err
+		}
+	}
+
+	wallet, err := repos.Wallets.GetForUpdate(userID, amount.Currency)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInsufficientBalance
+		}
+		return nil, err
+	}
+
+	return wallet, nil
+}
+
+func creditBalance(repos *domain.Repositories, userID uuid.UUID, amount money.Money) (before, after money.Money, err error) {
+	wallet, err := lockWallet(repos, userID, amount, true)
+	if err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+
+	return addBalance(repos, wallet, amount)
+}
+
+// returnBalance credits money given back to the user, such as a refund. Like
+// releaseHold it is allowed on frozen wallets since it never moves money out.
+func returnBalance(repos *domain.Repositories, userID uuid.UUID, amount money.Money) (before, after money.Money, err error) {
+	if _, err := repos.Users.GetByIDForUpdate(userID); err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+
+	if err := checkAmount(amount); err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+
+	if _, err := repos.Wallets.GetOrCreate(userID, amount.Currency); err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+
+	wallet, err := repos.Wallets.GetForUpdate(userID, amount.Currency)
+	if err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+
+	return addBalance(repos, wallet, amount)
+}
+
+func addBalance(repos *domain.Repositories, wallet *domain.Wallet, amount money.Money) (before, after money.Money, err error) {
+	after, err = wallet.Balance.CheckedAdd(amount)
+	if err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+	if err := repos.Wallets.UpdateBalance(wallet.ID, after); err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+
+	return wallet.Balance, after, nil
+}
+
+func debitBalance(repos *domain.Repositories, userID uuid.UUID, amount money.Money) (before, after money.Money, err error) {
+	wallet, err := lockWallet(repos, userID, amount, false)
+	if err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+
+	if wallet.AvailableBalance().LessThan(amount) {
+		return money.Money{}, money.Money{}, ErrInsufficientBalance
+	}
+
+	after, err = wallet.Balance.CheckedSub(amount)
+	if err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+	if err := repos.Wallets.UpdateBalance(wallet.ID, after); err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+
+	return wallet.Balance, after, nil
+}
+
+// placeHold reserves available funds without changing the ledger balance.
+func placeHold(repos *domain.Repositories, userID uuid.UUID, amount money.Money) (*domain.Wallet, error) {
+	wallet, err := lockWallet(repos, userID, amount, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if wallet.AvailableBalance().LessThan(amount) {
+		return nil, ErrInsufficientBalance
+	}
+
+	wallet.HeldBalance, err = wallet.HeldBalance.CheckedAdd(amount)
+	if err != nil {
+		return nil, err
+	}
+	if err := repos.Wallets.UpdateHeldBalance(wallet.ID, wallet.HeldBalance); err != nil {
+		return nil, err
+	}
+
+	return wallet, nil
+}
+
+// releaseHold returns held funds to the available balance. It is allowed on
+// frozen wallets since it never moves money out.
+func releaseHold(repos *domain.Repositories, userID uuid.UUID, amount money.Money) error {
+	if _, err := repos.Users.GetByIDForUpdate(userID); err != nil {
+		return err
+	}
+
+	if err := checkAmount(amount); err != nil {
+		return err
+	}
+
+	wallet, err := repos.Wallets.GetForUpdate(userID, amount.Currency)
+	if err != nil {
+		return err
+	}
+
+	held, err := wallet.HeldBalance.CheckedSub(amount)
+	if err != nil {
+		return err
+	}
+	if held.IsNegative() {
+		held = money.Zero(held.Currency)
+	}
+
+	return repos.Wallets.UpdateHeldBalance(wallet.ID, held)
+}
+
+// captureHold turns held funds into a debit of the ledger balance.
+func captureHold(repos *domain.Repositories, userID uuid.UUID, amount money.Money) (before, after money.Money, err error) {
+	wallet, err := lockWallet(repos, userID, amount, false)
+	if err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+
+	if wallet.HeldBalance.LessThan(amount) || wallet.Balance.LessThan(amount) {
+		return money.Money{}, money.Money{}, ErrInsufficientBalance
+	}
+
+	held, err := wallet.HeldBalance.CheckedSub(amount)
+	if err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+	after, err = wallet.Balance.CheckedSub(amount)
+	if err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+
+	if err := repos.Wallets.UpdateHeldBalance(wallet.ID, held); err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+	if err := repos.Wallets.UpdateBalance(wallet.ID, after); err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+
+	return wallet.Balance, after, nil
+}
+
+// lockUsers locks several user rows in a stable order so that two transfers
+// between the same pair of users cannot deadlock each other.
+func lockUsers(repos *domain.Repositories, ids ...uuid.UUID) error {
+	sorted := slices.Clone(ids)
+	slices.SortFunc(sorted, func(a, b uuid.UUID) int {
+		return bytes.Compare(a[:], b[:])
+	})
+
+	for _, id := range sorted {
+		if _, err := repos.Users.GetByIDForUpdate(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAmount rejects non-positive amounts and unknown currencies.
+func checkAmount(amount money.Money) error {
+	if !amount.IsPositive() {
+		return errors.New("amount must be greater than zero")
+	}
+	return money.ValidateCurrency(amount.Currency)
+}
+
+// needsConversion reports whether a move of amount into targetCurrency
+// crosses currencies, which is only allowed when the caller asked for a
+// conversion at a quote. An empty target means the amount's currency.
+func needsConversion(amount money.Money, targetCurrency string, convert bool, quoteID uuid.UUID) (bool, error) {
+	if targetCurrency == "" || targetCurrency == amount.Currency {
+		return false, nil
+	}
+	if err := money.ValidateCurrency(targetCurrency); err != nil {
+		return false, err
+	}
+	if !convert {
+		return false, ErrCrossCurrency
+	}
+	if quoteID == uuid.Nil {
+		return false, ErrQuoteRequired
+	}
+	return true, nil
+}
@@ -0,0 +1,284 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrBillNotFound = errors.New("bill not found")
+
+// BillShare is one participant of a new bill. Amount is used by EXACT bills
+// and ShareBps, a percentage in basis points, by PERCENTAGE bills.
+type BillShare struct {
+	UserID   uuid.UUID
+	Amount   money.Money
+	ShareBps int64
+}
+
+type BillInput struct {
+	Title        string
+	Total        money.Money
+	SplitMode    domain.SplitMode
+	Participants []BillShare
+}
+
+// BillUsecase splits bills into money requests. Whether a participant has
+// paid is read from the transfer their request produced, so it never goes
+// out of step with the wallets.
+type BillUsecase struct {
+	uow             domain.UnitOfWork
+	billRepo        domain.BillRepository
+	requests        *MoneyRequestUsecase
+	transactionRepo domain.TransactionRepository
+}
+
+func NewBillUsecase(uow domain.UnitOfWork, billRepo domain.BillRepository, requests *MoneyRequestUsecase, transactionRepo domain.TransactionRepository) *BillUsecase {
+	return &BillUsecase{
+		uow:             uow,
+		billRepo:        billRepo,
+		requests:        requests,
+		transactionRepo: transactionRepo,
+	}
+}
+
+// Create splits a bill and sends every participant other than the creator a
+// money request for their share.
+func (u *BillUsecase) Create(creatorID uuid.UUID, in BillInput) (*domain.Bill, error) {
+	title := strings.TrimSpace(in.Title)
+	if title == "" {
+		return nil, errors.New("bill title is required")
+	}
+	if !in.Total.IsPositive() {
+		return nil, errors.New("total must be positive")
+	}
+
+	seen := make(map[uuid.UUID]bool, len(in.Participants))
+	others := 0
+	for _, p := range in.Participants {
+		if seen[p.UserID] {
+			return nil, errors.New("a participant can only be listed once")
+		}
+		seen[p.UserID] = true
+		if p.UserID != creatorID {
+			others++
+		}
+	}
+	if others == 0 {
+		return nil, errors.New("a bill needs at least one participant other than its creator")
+	}
+
+	shares, err := splitShares(in.Total, in.SplitMode, in.Participants)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	bill := &domain.Bill{
+		ID:        uuid.New(),
+		CreatorID: creatorID,
+		Title:     title,
+		Total:     in.Total,
+		SplitMode: in.SplitMode,
+		CreatedAt: now,
+	}
+
+	err = u.uow.Do(func(repos *domain.Repositories) error {
+		if err := repos.Bills.Create(bill); err != nil {
+			return err
+		}
+
+		participants := make([]domain.BillParticipant, 0, len(in.Participants))
+		for i, p := range in.Participants {
+			participant := domain.BillParticipant{
+				ID:       uuid.New(),
+				BillID:   bill.ID,
+				UserID:   p.UserID,
+				Share:    shares[i],
+				ShareBps: p.ShareBps,
+				Position: i,
+			}
+
+			if p.UserID != creatorID {
+				request := &domain.MoneyRequest{
+					ID:          uuid.New(),
+					RequesterID: creatorID,
+					PayerID:     p.UserID,
+					Amount:      shares[i],
+					Note:        title,
+					Status:      domain.MoneyRequestStatusPending,
+					BillID:      &bill.ID,
+					ExpiresAt:   now.Add(u.requests.config.DefaultTTL),
+					CreatedAt:   now,
+					UpdatedAt:   now,
+				}
+				if err := createMoneyRequest(repos, request); err != nil {
+					return err
+				}
+				participant.RequestID = &request.ID
+			}
+
+			participants = append(participants, participant)
+		}
+
+		return repos.Bills.CreateParticipants(participants)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return u.describe(bill)
+}
+
+// splitShares returns the share of each participant, in order. Minor units
+// that do not divide evenly go to the first participants.
+func splitShares(total money.Money, mode domain.SplitMode, participants []BillShare) ([]money.Money, error) {
+	if len(participants) == 0 {
+		return nil, errors.New("a bill needs participants")
+	}
+
+	shares := make([]money.Money, len(participants))
+	switch mode {
+	case domain.SplitModeEqual:
+		n := int64(len(participants))
+		for i := range participants {
+			minor := total.Minor / n
+			if int64(i) < total.Minor%n {
+				minor++
+			}
+			shares[i] = money.New(minor, total.Currency)
+		}
+
+	case domain.SplitModeExact:
+		sum := money.Zero(total.Currency)
+		for i, p := range participants {
+			if !p.Amount.SameCurrency(total) {
+				return nil, money.ErrCurrencyMismatch
+			}
+			shares[i] = p.Amount
+			sum = sum.Add(p.Amount)
+		}
+		if sum.Cmp(total) != 0 {
+			return nil, errors.New("shares must add up to the total")
+		}
+
+	case domain.SplitModePercentage:
+		var bps, assigned int64
+		for i, p := range participants {
+			if p.ShareBps <= 0 {
+				return nil, errors.New("every percentage must be positive")
+			}
+			bps += p.ShareBps
+			minor := total.Minor/10000*p.ShareBps + total.Minor%10000*p.ShareBps/10000
+			shares[i] = money.New(minor, total.Currency)
+			assigned += minor
+		}
+		if bps != 10000 {
+			return nil, errors.New("percentages must add up to 100")
+		}
+		for i := 0; assigned < total.Minor; i = (i + 1) % len(shares) {
+			shares[i] = shares[i].Add(money.New(1, total.Currency))
+			assigned++
+		}
+
+	default:
+		return nil, errors.New("split mode must be EQUAL, EXACT or PERCENTAGE")
+	}
+
+	for _, share := range shares {
+		if !share.IsPositive() {
+			return nil, errors.New("every participant's share must be positive")
+		}
+	}
+
+	return shares, nil
+}
+
+// Get returns a bill to its creator or one of its participants.
+func (u *BillUsecase) Get(userID, billID uuid.UUID) (*domain.Bill, error) {
+	bill, err := u.billRepo.GetByID(billID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBillNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bill, err = u.describe(bill)
+	if err != nil {
+		return nil, err
+	}
+
+	if bill.CreatorID == userID {
+		return bill, nil
+	}
+	for _, p := range bill.Participants {
+		if p.UserID == userID {
+			return bill, nil
+		}
+	}
+
+	return nil, ErrBillNotFound
+}
+
+// GetBills lists the bills the user created or takes part in.
+func (u *BillUsecase) GetBills(userID uuid.UUID) ([]domain.Bill, error) {
+	bills, err := u.billRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range bills {
+		if _, err := u.describe(&bills[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return bills, nil
+}
+
+// describe fills in the participants of a bill and who has paid. A share is
+// paid once the transfer of its request has succeeded.
+func (u *BillUsecase) describe(bill *domain.Bill) (*domain.Bill, error) {
+	participants, err := u.billRepo.GetParticipants(bill.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	paid := money.Zero(bill.Total.Currency)
+	for i := range participants {
+		p := &participants[i]
+		if p.RequestID == nil {
+			p.Paid = true
+		} else {
+			request, err := u.requests.requestRepo.GetByID(*p.RequestID)
+			if err != nil {
+				return nil, err
+			}
+			p.RequestStatus = request.Status
+
+			if request.TransferID != nil {
+				tx, err := u.transactionRepo.GetByID(*request.TransferID)
+				if err != nil {
+					return nil, err
+				}
+				p.TransferStatus = tx.Status
+				p.Paid = tx.Status == domain.TransactionStatusSuccess
+			}
+		}
+
+		if p.Paid {
+			paid = paid.Add(p.Share)
+		}
+	}
+
+	bill.Participants = participants
+	bill.PaidAmount = paid
+	bill.Settled = paid.Cmp(bill.Total) == 0
+	return bill, nil
+}
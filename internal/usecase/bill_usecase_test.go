@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBillUsecase(t *testing.T) {
+	store := newMemStore()
+	creatorID := store.addUser(idr(0))
+	payerID := store.addUser(idr(50000))
+	otherID := store.addUser(idr(50000))
+	requests := newTestMoneyRequestUsecase(store)
+	uc := NewBillUsecase(store, store.repos().Bills, requests, store.repos().Transactions)
+
+	bill, err := uc.Create(creatorID, BillInput{
+		Title:     "Team lunch",
+		Total:     idr(10000),
+		SplitMode: domain.SplitModeEqual,
+		Participants: []BillShare{
+			{UserID: creatorID},
+			{UserID: payerID},
+			{UserID: otherID},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, bill.Participants, 3)
+	assert.Equal(t, idr(3334), bill.Participants[0].Share)
+	assert.True(t, bill.Participants[0].Paid, "the creator's share needs no request")
+	assert.Nil(t, bill.Participants[0].RequestID)
+	assert.Equal(t, idr(3333), bill.Participants[1].Share)
+	assert.Equal(t, idr(3334), bill.PaidAmount)
+	assert.False(t, bill.Settled)
+
+	incoming, err := requests.GetIncoming(payerID)
+	require.NoError(t, err)
+	require.Len(t, incoming, 1)
+	assert.Equal(t, bill.ID, *incoming[0].BillID)
+
+	_, tx, err := requests.Accept(payerID, incoming[0].ID, TransferOptions{})
+	require.NoError(t, err)
+
+	bill, err = uc.Get(payerID, bill.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.MoneyRequestStatusAccepted, bill.Participants[1].RequestStatus)
+	assert.False(t, bill.Participants[1].Paid, "a pending transfer is not paid yet")
+
+	require.NoError(t, newTestTransactionUsecase(store).ProcessTransfer(tx.ID))
+
+	bill, err = uc.Get(creatorID, bill.ID)
+	require.NoError(t, err)
+	assert.True(t, bill.Participants[1].Paid)
+	assert.Equal(t, idr(6667), bill.PaidAmount)
+
+	_, failed, err := requests.Accept(otherID, *bill.Participants[2].RequestID, TransferOptions{})
+	require.NoError(t, err)
+	require.NoError(t, newTestTransactionUsecase(store).FailTransfer(failed.ID, "task archived"))
+
+	bill, err = uc.Get(otherID, bill.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.MoneyRequestStatusPending, bill.Participants[2].RequestStatus, "a failed transfer lets the share be paid again")
+	assert.False(t, bill.Participants[2].Paid)
+
+	_, err = uc.Get(store.addUser(idr(0)), bill.ID)
+	assert.ErrorIs(t, err, ErrBillNotFound)
+}
+
+func TestSplitShares(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+
+	shares, err := splitShares(idr(10001), domain.SplitModePercentage, []BillShare{
+		{UserID: a, ShareBps: 5000},
+		{UserID: b, ShareBps: 2500},
+		{UserID: c, ShareBps: 2500},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []money.Money{idr(5001), idr(2500), idr(2500)}, shares)
+
+	_, err = splitShares(idr(10000), domain.SplitModePercentage, []BillShare{
+		{UserID: a, ShareBps: 5000},
+		{UserID: b, ShareBps: 4000},
+	})
+	assert.Error(t, err)
+
+	_, err = splitShares(idr(10000), domain.SplitModeExact, []BillShare{
+		{UserID: a, Amount: idr(6000)},
+		{UserID: b, Amount: idr(3000)},
+	})
+	assert.Error(t, err)
+
+	_, err = splitShares(idr(1), domain.SplitModeEqual, []BillShare{{UserID: a}, {UserID: b}})
+	assert.Error(t, err, "a share of zero is rejected")
+}
@@ -0,0 +1,1250 @@
+package usecase
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var errInjected = errors.New("injected failure")
+
+// memStore is an in-memory database for usecase tests. UnitOfWork.Do rolls
+// the tables back when fn fails, and failAt makes the n-th repository call
+// fail so that every step of a flow can be interrupted. It also stands in for
+// the task queue: taskStates holds the queue state of each task, and unknown
+// tasks have none.
+type memStore struct {
+	memTables
+
+	deletedTasks []string
+	taskStates   map[string]string
+
+	calls  int
+	failAt int
+}
+
+// memTables holds the rows of every repository. A new repository adds its
+// table here and to clone.
+type memTables struct {
+	users         map[uuid.UUID]domain.User
+	wallets       map[string]domain.Wallet
+	pockets       map[uuid.UUID]domain.Pocket
+	transactions  map[uuid.UUID]domain.Transaction
+	accounts      map[string]domain.LedgerAccount
+	postings      []domain.Posting
+	entries       []domain.JournalEntry
+	outbox        []domain.OutboxMessage
+	notifications []domain.Notification
+	rates         map[string]domain.FXRate
+	quotes        map[uuid.UUID]domain.FXQuote
+	schedules     map[uuid.UUID]domain.TransferSchedule
+	runs          []domain.ScheduleRun
+	overrides     map[string]domain.LimitOverride
+	merchants     map[uuid.UUID]domain.Merchant
+	settlements   map[uuid.UUID]domain.SettlementBatch
+	items         []domain.SettlementItem
+	intents       map[uuid.UUID]domain.PaymentIntent
+	requests      map[uuid.UUID]domain.MoneyRequest
+	requestEvents []domain.MoneyRequestEvent
+	bills         map[uuid.UUID]domain.Bill
+	participants  []domain.BillParticipant
+}
+
+func newMemStore() *memStore {
+	return &memStore{memTables: memTables{
+		users:        make(map[uuid.UUID]domain.User),
+		wallets:      make(map[string]domain.Wallet),
+		pockets:      make(map[uuid.UUID]domain.Pocket),
+		transactions: make(map[uuid.UUID]domain.Transaction),
+		accounts:     make(map[string]domain.LedgerAccount),
+		rates:        make(map[string]domain.FXRate),
+		quotes:       make(map[uuid.UUID]domain.FXQuote),
+		schedules:    make(map[uuid.UUID]domain.TransferSchedule),
+		overrides:    make(map[string]domain.LimitOverride),
+		merchants:    make(map[uuid.UUID]domain.Merchant),
+		settlements:  make(map[uuid.UUID]domain.SettlementBatch),
+		intents:      make(map[uuid.UUID]domain.PaymentIntent),
+		requests:     make(map[uuid.UUID]domain.MoneyRequest),
+		bills:        make(map[uuid.UUID]domain.Bill),
+	}}
+}
+
+// clone copies the tables so that changes to the copy leave t alone.
+func (t memTables) clone() memTables {
+	return memTables{
+		users:         maps.Clone(t.users),
+		wallets:       maps.Clone(t.wallets),
+		pockets:       maps.Clone(t.pockets),
+		transactions:  maps.Clone(t.transactions),
+		accounts:      maps.Clone(t.accounts),
+		postings:      slices.Clone(t.postings),
+		entries:       slices.Clone(t.entries),
+		outbox:        slices.Clone(t.outbox),
+		notifications: slices.Clone(t.notifications),
+		rates:         maps.Clone(t.rates),
+		quotes:        maps.Clone(t.quotes),
+		schedules:     maps.Clone(t.schedules),
+		runs:          slices.Clone(t.runs),
+		overrides:     maps.Clone(t.overrides),
+		merchants:     maps.Clone(t.merchants),
+		settlements:   maps.Clone(t.settlements),
+		items:         slices.Clone(t.items),
+		intents:       maps.Clone(t.intents),
+		requests:      maps.Clone(t.requests),
+		requestEvents: slices.Clone(t.requestEvents),
+		bills:         maps.Clone(t.bills),
+		participants:  slices.Clone(t.participants),
+	}
+}
+
+func (s *memStore) step(name string) error {
+	s.calls++
+	if s.failAt != 0 && s.calls == s.failAt {
+		return fmt.Errorf("%s: %w", name, errInjected)
+	}
+	return nil
+}
+
+func (s *memStore) Do(fn func(repos *domain.Repositories) error) error {
+	snapshot := s.memTables.clone()
+	if err := fn(s.repos()); err != nil {
+		s.memTables = snapshot
+		return err
+	}
+	return nil
+}
+
+func (s *memStore) repos() *domain.Repositories {
+	return &domain.Repositories{
+		Users:         &memUserRepo{s},
+		Wallets:       &memWalletRepo{s},
+		Pockets:       &memPocketRepo{s},
+		Transactions:  &memTransactionRepo{s},
+		Ledger:        &memLedgerRepo{s},
+		Outbox:        &memOutboxRepo{s},
+		Notifications: &memNotificationRepo{s},
+		FX:            &memFXRepo{s},
+		Schedules:     &memScheduleRepo{s},
+		Limits:        &memLimitRepo{s},
+		Merchants:     &memMerchantRepo{s},
+		Settlements:   &memSettlementRepo{s},
+		Intents:       &memPaymentIntentRepo{s},
+		Requests:      &memMoneyRequestRepo{s},
+		Bills:         &memBillRepo{s},
+	}
+}
+
+func (s *memStore) TaskState(taskID string) (string, error) {
+	return s.taskStates[taskID], nil
+}
+
+func (s *memStore) DeleteTask(taskID string) error {
+	s.deletedTasks = append(s.deletedTasks, taskID)
+	return nil
+}
+
+func (s *memStore) addUser(balance money.Money) uuid.UUID {
+	id := uuid.New()
+	s.users[id] = domain.User{ID: id}
+	s.wallets[walletKey(id, balance.Currency)] = domain.Wallet{
+		ID:          uuid.New(),
+		UserID:      id,
+		Currency:    balance.Currency,
+		Balance:     balance,
+		HeldBalance: money.Zero(balance.Currency),
+	}
+	return id
+}
+
+func walletKey(userID uuid.UUID, currency string) string {
+	return userID.String() + "/" + currency
+}
+
+func (s *memStore) wallet(userID uuid.UUID) domain.Wallet {
+	return s.wallets[walletKey(userID, "IDR")]
+}
+
+func (s *memStore) setBalance(userID uuid.UUID, balance money.Money) {
+	wallet := s.wallets[walletKey(userID, balance.Currency)]
+	wallet.Balance = balance
+	s.wallets[walletKey(userID, balance.Currency)] = wallet
+}
+
+func (s *memStore) transactionsByReference(referenceID uuid.UUID, referenceType string) []domain.Transaction {
+	var result []domain.Transaction
+	for _, tx := range s.transactions {
+		if tx.ReferenceID == referenceID && tx.ReferenceType == referenceType {
+			result = append(result, tx)
+		}
+	}
+	return result
+}
+
+// noFees is a fee schedule without rules, which charges nothing.
+func noFees(store *memStore) *FeeUsecase {
+	fees, _ := NewFeeUsecase(store.repos().Users, noLimits(store), FeesConfig{})
+	return fees
+}
+
+// noLimits is a limits engine without tiers, which limits nothing.
+func noLimits(store *memStore) *LimitsUsecase {
+	repos := store.repos()
+	limits, _ := NewLimitsUsecase(store, repos.Users, repos.Limits, LimitsConfig{})
+	return limits
+}
+
+func idr(minor int64) money.Money {
+	return money.New(minor, "IDR")
+}
+
+type memUserRepo struct{ s *memStore }
+
+func (r *memUserRepo) Create(user *domain.User) error {
+	if err := r.s.step("Users.Create"); err != nil {
+		return err
+	}
+	r.s.users[user.ID] = *user
+	return nil
+}
+
+func (r *memUserRepo) GetByPhoneNumber(phoneNumber string) (*domain.User, error) {
+	if err := r.s.step("Users.GetByPhoneNumber"); err != nil {
+		return nil, err
+	}
+	for _, user := range r.s.users {
+		if user.PhoneNumber == phoneNumber {
+			return &user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memUserRepo) GetByID(id uuid.UUID) (*domain.User, error) {
+	if err := r.s.step("Users.GetByID"); err != nil {
+		return nil, err
+	}
+	user, ok := r.s.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+func (r *memUserRepo) GetByIDForUpdate(id uuid.UUID) (*domain.User, error) {
+	return r.GetByID(id)
+}
+
+func (r *memUserRepo) Update(user *domain.User) error {
+	if err := r.s.step("Users.Update"); err != nil {
+		return err
+	}
+	r.s.users[user.ID] = *user
+	return nil
+}
+
+func (r *memUserRepo) UpdateStatus(userID uuid.UUID, status domain.UserStatus) error {
+	if err := r.s.step("Users.UpdateStatus"); err != nil {
+		return err
+	}
+	user := r.s.users[userID]
+	user.Status = status
+	r.s.users[userID] = user
+	return nil
+}
+
+func (r *memUserRepo) List(afterID uuid.UUID, limit int) ([]domain.User, error) {
+	if err := r.s.step("Users.List"); err != nil {
+		return nil, err
+	}
+	var result []domain.User
+	for _, user := range r.s.users {
+		if bytes.Compare(user.ID[:], afterID[:]) > 0 {
+			result = append(result, user)
+		}
+	}
+	slices.SortFunc(result, func(a, b domain.User) int {
+		return bytes.Compare(a.ID[:], b.ID[:])
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+type memWalletRepo struct{ s *memStore }
+
+func (r *memWalletRepo) GetOrCreate(userID uuid.UUID, currency string) (*domain.Wallet, error) {
+	if err := r.s.step("Wallets.GetOrCreate"); err != nil {
+		return nil, err
+	}
+	key := walletKey(userID, currency)
+	if _, ok := r.s.wallets[key]; !ok {
+		r.s.wallets[key] = domain.Wallet{
+			ID:          uuid.New(),
+			UserID:      userID,
+			Currency:    currency,
+			Balance:     money.Zero(currency),
+			HeldBalance: money.Zero(currency),
+		}
+	}
+	wallet := r.s.wallets[key]
+	return &wallet, nil
+}
+
+func (r *memWalletRepo) Get(userID uuid.UUID, currency string) (*domain.Wallet, error) {
+	if err := r.s.step("Wallets.Get"); err != nil {
+		return nil, err
+	}
+	wallet, ok := r.s.wallets[walletKey(userID, currency)]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &wallet, nil
+}
+
+func (r *memWalletRepo) GetForUpdate(userID uuid.UUID, currency string) (*domain.Wallet, error) {
+	return r.Get(userID, currency)
+}
+
+func (r *memWalletRepo) GetByUserID(userID uuid.UUID) ([]domain.Wallet, error) {
+	if err := r.s.step("Wallets.GetByUserID"); err != nil {
+		return nil, err
+	}
+	var result []domain.Wallet
+	for _, wallet := range r.s.wallets {
+		if wallet.UserID == userID {
+			result = append(result, wallet)
+		}
+	}
+	return result, nil
+}
+
+func (r *memWalletRepo) update(walletID uuid.UUID, fn func(*domain.Wallet)) {
+	for key, wallet := range r.s.wallets {
+		if wallet.ID == walletID {
+			fn(&wallet)
+			r.s.wallets[key] = wallet
+		}
+	}
+}
+
+func (r *memWalletRepo) UpdateBalance(walletID uuid.UUID, balance money.Money) error {
+	if err := r.s.step("Wallets.UpdateBalance"); err != nil {
+		return err
+	}
+	r.update(walletID, func(w *domain.Wallet) { w.Balance = balance })
+	return nil
+}
+
+func (r *memWalletRepo) UpdateHeldBalance(walletID uuid.UUID, held money.Money) error {
+	if err := r.s.step("Wallets.UpdateHeldBalance"); err != nil {
+		return err
+	}
+	r.update(walletID, func(w *domain.Wallet) { w.HeldBalance = held })
+	return nil
+}
+
+type memPocketRepo struct{ s *memStore }
+
+func (r *memPocketRepo) Create(pocket *domain.Pocket) error {
+	if err := r.s.step("Pockets.Create"); err != nil {
+		return err
+	}
+	for _, existing := range r.s.pockets {
+		if existing.UserID == pocket.UserID && strings.EqualFold(existing.Name, pocket.Name) {
+			return domain.ErrPocketNameTaken
+		}
+	}
+	r.s.pockets[pocket.ID] = *pocket
+	return nil
+}
+
+func (r *memPocketRepo) GetByID(id uuid.UUID) (*domain.Pocket, error) {
+	if err := r.s.step("Pockets.GetByID"); err != nil {
+		return nil, err
+	}
+	pocket, ok := r.s.pockets[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &pocket, nil
+}
+
+func (r *memPocketRepo) GetByIDForUpdate(id uuid.UUID) (*domain.Pocket, error) {
+	return r.GetByID(id)
+}
+
+func (r *memPocketRepo) GetByUserID(userID uuid.UUID) ([]domain.Pocket, error) {
+	if err := r.s.step("Pockets.GetByUserID"); err != nil {
+		return nil, err
+	}
+	var result []domain.Pocket
+	for _, pocket := range r.s.pockets {
+		if pocket.UserID == userID {
+			result = append(result, pocket)
+		}
+	}
+	return result, nil
+}
+
+func (r *memPocketRepo) UpdateBalance(pocketID uuid.UUID, balance money.Money) error {
+	if err := r.s.step("Pockets.UpdateBalance"); err != nil {
+		return err
+	}
+	pocket := r.s.pockets[pocketID]
+	pocket.Balance = balance
+	r.s.pockets[pocketID] = pocket
+	return nil
+}
+
+func (r *memPocketRepo) Delete(pocketID uuid.UUID) error {
+	if err := r.s.step("Pockets.Delete"); err != nil {
+		return err
+	}
+	delete(r.s.pockets, pocketID)
+	return nil
+}
+
+type memTransactionRepo struct{ s *memStore }
+
+func (r *memTransactionRepo) Create(tx *domain.Transaction) error {
+	if err := r.s.step("Transactions.Create"); err != nil {
+		return err
+	}
+	if tx.ID == uuid.Nil {
+		tx.ID = uuid.New()
+	}
+	if tx.ReferenceType == domain.ReferenceTypeTransfer && len(r.s.transactionsByReference(tx.ReferenceID, tx.ReferenceType)) > 0 {
+		return errors.New("duplicate key value violates unique constraint")
+	}
+	r.s.transactions[tx.ID] = *tx
+	return nil
+}
+
+func (r *memTransactionRepo) GetByUserID(userID uuid.UUID) ([]domain.Transaction, error) {
+	if err := r.s.step("Transactions.GetByUserID"); err != nil {
+		return nil, err
+	}
+	var result []domain.Transaction
+	for _, tx := range r.s.transactions {
+		if tx.UserID == userID {
+			result = append(result, tx)
+		}
+	}
+	return result, nil
+}
+
+func (r *memTransactionRepo) GetByID(id uuid.UUID) (*domain.Transaction, error) {
+	if err := r.s.step("Transactions.GetByID"); err != nil {
+		return nil, err
+	}
+	tx, ok := r.s.transactions[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &tx, nil
+}
+
+func (r *memTransactionRepo) GetByIDForUpdate(id uuid.UUID) (*domain.Transaction, error) {
+	return r.GetByID(id)
+}
+
+func (r *memTransactionRepo) GetByReference(referenceID uuid.UUID, referenceType string) ([]domain.Transaction, error) {
+	if err := r.s.step("Transactions.GetByReference"); err != nil {
+		return nil, err
+	}
+	return r.s.transactionsByReference(referenceID, referenceType), nil
+}
+
+func (r *memTransactionRepo) GetByMerchantID(merchantID uuid.UUID) ([]domain.Transaction, error) {
+	if err := r.s.step("Transactions.GetByMerchantID"); err != nil {
+		return nil, err
+	}
+	var result []domain.Transaction
+	for _, tx := range r.s.transactions {
+		if tx.MerchantID != nil && *tx.MerchantID == merchantID {
+			result = append(result, tx)
+		}
+	}
+	return result, nil
+}
+
+func (r *memTransactionRepo) GetPendingTransfers(createdBefore time.Time, limit int) ([]domain.Transaction, error) {
+	if err := r.s.step("Transactions.GetPendingTransfers"); err != nil {
+		return nil, err
+	}
+	var result []domain.Transaction
+	for _, tx := range r.s.transactions {
+		if tx.Status == domain.TransactionStatusPending && tx.TargetUserID != nil && tx.CreatedAt.Before(createdBefore) && len(result) < limit {
+			result = append(result, tx)
+		}
+	}
+	return result, nil
+}
+
+func (r *memTransactionRepo) GetExpiredAuthorizations(expiredBefore time.Time, limit int) ([]domain.Transaction, error) {
+	if err := r.s.step("Transactions.GetExpiredAuthorizations"); err != nil {
+		return nil, err
+	}
+	var result []domain.Transaction
+	for _, tx := range r.s.transactions {
+		if tx.Status == domain.TransactionStatusAuthorized && tx.ExpiresAt.Before(expiredBefore) && len(result) < limit {
+			result = append(result, tx)
+		}
+	}
+	return result, nil
+}
+
+func (r *memTransactionRepo) SumOutgoing(userID uuid.UUID, currency string, since time.Time) (int64, error) {
+	if err := r.s.step("Transactions.SumOutgoing"); err != nil {
+		return 0, err
+	}
+	var sum int64
+	for _, tx := range r.s.transactions {
+		counted := tx.Status == domain.TransactionStatusSuccess || tx.Status == domain.TransactionStatusPending || tx.Status == domain.TransactionStatusAuthorized
+		outgoing := tx.ReferenceType == "" || tx.ReferenceType == domain.ReferenceTypeAuthorization
+		if tx.UserID == userID && tx.Type == domain.TransactionTypeDebit && tx.Amount.Currency == currency && !tx.CreatedAt.Before(since) && counted && outgoing {
+			sum += tx.Amount.Minor
+		}
+	}
+	return sum, nil
+}
+
+func (r *memTransactionRepo) SumIncoming(userID uuid.UUID, currency string, since time.Time) (int64, error) {
+	if err := r.s.step("Transactions.SumIncoming"); err != nil {
+		return 0, err
+	}
+	var sum int64
+	for _, tx := range r.s.transactions {
+		if tx.Amount.Currency != currency || tx.CreatedAt.Before(since) {
+			continue
+		}
+		received := tx.UserID == userID && tx.Type == domain.TransactionTypeCredit && tx.Status == domain.TransactionStatusSuccess &&
+			(tx.ReferenceType == "" || tx.ReferenceType == domain.ReferenceTypeTransfer)
+		pending := tx.TargetUserID != nil && *tx.TargetUserID == userID && tx.Type == domain.TransactionTypeDebit && tx.Status == domain.TransactionStatusPending
+		if received || pending {
+			sum += tx.Amount.Minor
+		}
+	}
+	return sum, nil
+}
+
+func (r *memTransactionRepo) Update(tx *domain.Transaction) error {
+	if err := r.s.step("Transactions.Update"); err != nil {
+		return err
+	}
+	r.s.transactions[tx.ID] = *tx
+	return nil
+}
+
+type memLedgerRepo struct{ s *memStore }
+
+func (r *memLedgerRepo) GetOrCreateAccount(account *domain.LedgerAccount) (*domain.LedgerAccount, error) {
+	if err := r.s.step("Ledger.GetOrCreateAccount"); err != nil {
+		return nil, err
+	}
+	key := account.Code + "/" + account.Currency
+	if existing, ok := r.s.accounts[key]; ok {
+		return &existing, nil
+	}
+	if account.ID == uuid.Nil {
+		account.ID = uuid.New()
+	}
+	r.s.accounts[key] = *account
+	return account, nil
+}
+
+func (r *memLedgerRepo) GetAccount(code, currency string) (*domain.LedgerAccount, error) {
+	if err := r.s.step("Ledger.GetAccount"); err != nil {
+		return nil, err
+	}
+	account, ok := r.s.accounts[code+"/"+currency]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &account, nil
+}
+
+func (r *memLedgerRepo) CreateEntry(entry *domain.JournalEntry) error {
+	if err := r.s.step("Ledger.CreateEntry"); err != nil {
+		return err
+	}
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	for i := range entry.Postings {
+		entry.Postings[i].EntryID = entry.ID
+	}
+	r.s.entries = append(r.s.entries, *entry)
+	r.s.postings = append(r.s.postings, entry.Postings...)
+	return nil
+}
+
+func (r *memLedgerRepo) GetEntriesByTransactionID(transactionID uuid.UUID) ([]domain.JournalEntry, error) {
+	if err := r.s.step("Ledger.GetEntriesByTransactionID"); err != nil {
+		return nil, err
+	}
+	var result []domain.JournalEntry
+	for _, entry := range r.s.entries {
+		if entry.TransactionID == transactionID {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+func (r *memLedgerRepo) SumPostings(accountID uuid.UUID) (int64, error) {
+	if err := r.s.step("Ledger.SumPostings"); err != nil {
+		return 0, err
+	}
+	var sum int64
+	for _, p := range r.s.postings {
+		if p.AccountID == accountID {
+			sum += p.Amount.Minor
+		}
+	}
+	return sum, nil
+}
+
+type memOutboxRepo struct{ s *memStore }
+
+func (r *memOutboxRepo) Create(msg *domain.OutboxMessage) error {
+	if err := r.s.step("Outbox.Create"); err != nil {
+		return err
+	}
+	if msg.ID == uuid.Nil {
+		msg.ID = uuid.New()
+	}
+	r.s.outbox = append(r.s.outbox, *msg)
+	return nil
+}
+
+func (r *memOutboxRepo) GetPendingForUpdate(limit int) ([]domain.OutboxMessage, error) {
+	if err := r.s.step("Outbox.GetPendingForUpdate"); err != nil {
+		return nil, err
+	}
+	var result []domain.OutboxMessage
+	for _, msg := range r.s.outbox {
+		if msg.Status == domain.OutboxStatusPending && len(result) < limit {
+			result = append(result, msg)
+		}
+	}
+	return result, nil
+}
+
+func (r *memOutboxRepo) Update(msg *domain.OutboxMessage) error {
+	if err := r.s.step("Outbox.Update"); err != nil {
+		return err
+	}
+	for i := range r.s.outbox {
+		if r.s.outbox[i].ID == msg.ID {
+			r.s.outbox[i] = *msg
+		}
+	}
+	return nil
+}
+
+func (r *memOutboxRepo) HasPending(taskType, taskID string) (bool, error) {
+	if err := r.s.step("Outbox.HasPending"); err != nil {
+		return false, err
+	}
+	for _, msg := range r.s.outbox {
+		if msg.TaskType == taskType && msg.TaskID == taskID && msg.Status == domain.OutboxStatusPending {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memOutboxRepo) CancelPending(taskType, taskID string) error {
+	if err := r.s.step("Outbox.CancelPending"); err != nil {
+		return err
+	}
+	for i, msg := range r.s.outbox {
+		if msg.TaskType == taskType && msg.TaskID == taskID && msg.Status == domain.OutboxStatusPending {
+			r.s.outbox[i].Status = domain.OutboxStatusCancelled
+		}
+	}
+	return nil
+}
+
+type memNotificationRepo struct{ s *memStore }
+
+func (r *memNotificationRepo) Create(notification *domain.Notification) error {
+	if err := r.s.step("Notifications.Create"); err != nil {
+		return err
+	}
+	r.s.notifications = append(r.s.notifications, *notification)
+	return nil
+}
+
+func (r *memNotificationRepo) GetByUserID(userID uuid.UUID) ([]domain.Notification, error) {
+	if err := r.s.step("Notifications.GetByUserID"); err != nil {
+		return nil, err
+	}
+	var result []domain.Notification
+	for _, n := range r.s.notifications {
+		if n.UserID == userID {
+			result = append(result, n)
+		}
+	}
+	return result, nil
+}
+
+func (r *memNotificationRepo) MarkRead(userID, id uuid.UUID) error {
+	return r.s.step("Notifications.MarkRead")
+}
+
+type memFXRepo struct{ s *memStore }
+
+func (r *memFXRepo) UpsertRate(rate *domain.FXRate) error {
+	if err := r.s.step("FX.UpsertRate"); err != nil {
+		return err
+	}
+	r.s.rates[rate.BaseCurrency+"/"+rate.QuoteCurrency] = *rate
+	return nil
+}
+
+func (r *memFXRepo) GetRate(baseCurrency, quoteCurrency string) (*domain.FXRate, error) {
+	if err := r.s.step("FX.GetRate"); err != nil {
+		return nil, err
+	}
+	rate, ok := r.s.rates[baseCurrency+"/"+quoteCurrency]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &rate, nil
+}
+
+func (r *memFXRepo) ListRates() ([]domain.FXRate, error) {
+	if err := r.s.step("FX.ListRates"); err != nil {
+		return nil, err
+	}
+	return slices.Collect(maps.Values(r.s.rates)), nil
+}
+
+func (r *memFXRepo) CreateQuote(quote *domain.FXQuote) error {
+	if err := r.s.step("FX.CreateQuote"); err != nil {
+		return err
+	}
+	r.s.quotes[quote.ID] = *quote
+	return nil
+}
+
+func (r *memFXRepo) GetQuoteForUpdate(id uuid.UUID) (*domain.FXQuote, error) {
+	if err := r.s.step("FX.GetQuoteForUpdate"); err != nil {
+		return nil, err
+	}
+	quote, ok := r.s.quotes[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &quote, nil
+}
+
+func (r *memFXRepo) UpdateQuote(quote *domain.FXQuote) error {
+	if err := r.s.step("FX.UpdateQuote"); err != nil {
+		return err
+	}
+	r.s.quotes[quote.ID] = *quote
+	return nil
+}
+
+type memScheduleRepo struct{ s *memStore }
+
+func (r *memScheduleRepo) Create(schedule *domain.TransferSchedule) error {
+	if err := r.s.step("Schedules.Create"); err != nil {
+		return err
+	}
+	r.s.schedules[schedule.ID] = *schedule
+	return nil
+}
+
+func (r *memScheduleRepo) GetByID(id uuid.UUID) (*domain.TransferSchedule, error) {
+	if err := r.s.step("Schedules.GetByID"); err != nil {
+		return nil, err
+	}
+	schedule, ok := r.s.schedules[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &schedule, nil
+}
+
+func (r *memScheduleRepo) GetByIDForUpdate(id uuid.UUID) (*domain.TransferSchedule, error) {
+	return r.GetByID(id)
+}
+
+func (r *memScheduleRepo) GetByUserID(userID uuid.UUID) ([]domain.TransferSchedule, error) {
+	if err := r.s.step("Schedules.GetByUserID"); err != nil {
+		return nil, err
+	}
+	var result []domain.TransferSchedule
+	for _, schedule := range r.s.schedules {
+		if schedule.UserID == userID {
+			result = append(result, schedule)
+		}
+	}
+	return result, nil
+}
+
+func (r *memScheduleRepo) GetDue(t time.Time, limit int) ([]domain.TransferSchedule, error) {
+	if err := r.s.step("Schedules.GetDue"); err != nil {
+		return nil, err
+	}
+	var result []domain.TransferSchedule
+	for _, schedule := range r.s.schedules {
+		if schedule.Status == domain.ScheduleStatusActive && schedule.NextRunAt != nil && !schedule.NextRunAt.After(t) && len(result) < limit {
+			result = append(result, schedule)
+		}
+	}
+	return result, nil
+}
+
+func (r *memScheduleRepo) Update(schedule *domain.TransferSchedule) error {
+	if err := r.s.step("Schedules.Update"); err != nil {
+		return err
+	}
+	r.s.schedules[schedule.ID] = *schedule
+	return nil
+}
+
+func (r *memScheduleRepo) Delete(id uuid.UUID) error {
+	if err := r.s.step("Schedules.Delete"); err != nil {
+		return err
+	}
+	delete(r.s.schedules, id)
+	return nil
+}
+
+func (r *memScheduleRepo) CreateRun(run *domain.ScheduleRun) error {
+	if err := r.s.step("Schedules.CreateRun"); err != nil {
+		return err
+	}
+	if run.ID == uuid.Nil {
+		run.ID = uuid.New()
+	}
+	r.s.runs = append(r.s.runs, *run)
+	return nil
+}
+
+func (r *memScheduleRepo) GetRuns(scheduleID uuid.UUID) ([]domain.ScheduleRun, error) {
+	if err := r.s.step("Schedules.GetRuns"); err != nil {
+		return nil, err
+	}
+	var result []domain.ScheduleRun
+	for _, run := range r.s.runs {
+		if run.ScheduleID == scheduleID {
+			result = append(result, run)
+		}
+	}
+	return result, nil
+}
+
+type memMerchantRepo struct{ s *memStore }
+
+func (r *memMerchantRepo) Create(merchant *domain.Merchant) error {
+	if err := r.s.step("Merchants.Create"); err != nil {
+		return err
+	}
+	r.s.merchants[merchant.ID] = *merchant
+	return nil
+}
+
+func (r *memMerchantRepo) GetByID(id uuid.UUID) (*domain.Merchant, error) {
+	if err := r.s.step("Merchants.GetByID"); err != nil {
+		return nil, err
+	}
+	merchant, ok := r.s.merchants[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &merchant, nil
+}
+
+func (r *memMerchantRepo) GetByIDForUpdate(id uuid.UUID) (*domain.Merchant, error) {
+	return r.GetByID(id)
+}
+
+func (r *memMerchantRepo) GetByOwnerID(ownerID uuid.UUID) ([]domain.Merchant, error) {
+	if err := r.s.step("Merchants.GetByOwnerID"); err != nil {
+		return nil, err
+	}
+	var result []domain.Merchant
+	for _, merchant := range r.s.merchants {
+		if merchant.OwnerID == ownerID {
+			result = append(result, merchant)
+		}
+	}
+	return result, nil
+}
+
+func (r *memMerchantRepo) UpdateBalance(merchantID uuid.UUID, balance money.Money) error {
+	if err := r.s.step("Merchants.UpdateBalance"); err != nil {
+		return err
+	}
+	merchant := r.s.merchants[merchantID]
+	merchant.Balance = balance
+	r.s.merchants[merchantID] = merchant
+	return nil
+}
+
+func (r *memMerchantRepo) List(afterID uuid.UUID, limit int) ([]domain.Merchant, error) {
+	if err := r.s.step("Merchants.List"); err != nil {
+		return nil, err
+	}
+	var result []domain.Merchant
+	for _, merchant := range r.s.merchants {
+		if bytes.Compare(merchant.ID[:], afterID[:]) > 0 {
+			result = append(result, merchant)
+		}
+	}
+	slices.SortFunc(result, func(a, b domain.Merchant) int { return bytes.Compare(a.ID[:], b.ID[:]) })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (r *memMerchantRepo) UpdatePayoutInTransit(merchantID uuid.UUID, inTransit money.Money) error {
+	if err := r.s.step("Merchants.UpdatePayoutInTransit"); err != nil {
+		return err
+	}
+	merchant := r.s.merchants[merchantID]
+	merchant.PayoutInTransit = inTransit
+	r.s.merchants[merchantID] = merchant
+	return nil
+}
+
+func (r *memMerchantRepo) UpdateStatus(merchantID uuid.UUID, status domain.MerchantStatus) error {
+	if err := r.s.step("Merchants.UpdateStatus"); err != nil {
+		return err
+	}
+	merchant := r.s.merchants[merchantID]
+	merchant.Status = status
+	r.s.merchants[merchantID] = merchant
+	return nil
+}
+
+type memSettlementRepo struct{ s *memStore }
+
+func (r *memSettlementRepo) CreateBatch(batch *domain.SettlementBatch) error {
+	if err := r.s.step("Settlements.CreateBatch"); err != nil {
+		return err
+	}
+	r.s.settlements[batch.ID] = *batch
+	return nil
+}
+
+func (r *memSettlementRepo) CreateItems(items []domain.SettlementItem) error {
+	if err := r.s.step("Settlements.CreateItems"); err != nil {
+		return err
+	}
+	r.s.items = append(r.s.items, items...)
+	return nil
+}
+
+func (r *memSettlementRepo) GetBatch(id uuid.UUID) (*domain.SettlementBatch, error) {
+	if err := r.s.step("Settlements.GetBatch"); err != nil {
+		return nil, err
+	}
+	batch, ok := r.s.settlements[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &batch, nil
+}
+
+func (r *memSettlementRepo) GetBatchForUpdate(id uuid.UUID) (*domain.SettlementBatch, error) {
+	return r.GetBatch(id)
+}
+
+func (r *memSettlementRepo) GetBatchesByMerchantID(merchantID uuid.UUID) ([]domain.SettlementBatch, error) {
+	if err := r.s.step("Settlements.GetBatchesByMerchantID"); err != nil {
+		return nil, err
+	}
+	var result []domain.SettlementBatch
+	for _, batch := range r.s.settlements {
+		if batch.MerchantID == merchantID {
+			result = append(result, batch)
+		}
+	}
+	return result, nil
+}
+
+func (r *memSettlementRepo) GetItems(batchID uuid.UUID) ([]domain.SettlementItem, error) {
+	if err := r.s.step("Settlements.GetItems"); err != nil {
+		return nil, err
+	}
+	var result []domain.SettlementItem
+	for _, item := range r.s.items {
+		if item.BatchID == batchID {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+func (r *memSettlementRepo) UpdateBatch(batch *domain.SettlementBatch) error {
+	if err := r.s.step("Settlements.UpdateBatch"); err != nil {
+		return err
+	}
+	r.s.settlements[batch.ID] = *batch
+	return nil
+}
+
+func (r *memSettlementRepo) GetUnsettled(merchantID uuid.UUID, cutoff time.Time) ([]domain.Transaction, error) {
+	if err := r.s.step("Settlements.GetUnsettled"); err != nil {
+		return nil, err
+	}
+	settled := make(map[uuid.UUID]bool)
+	for _, item := range r.s.items {
+		settled[item.TransactionID] = true
+	}
+	var result []domain.Transaction
+	for _, tx := range r.s.transactions {
+		if tx.MerchantID != nil && *tx.MerchantID == merchantID && tx.Status == domain.TransactionStatusSuccess &&
+			tx.SettledAt != nil && tx.SettledAt.Before(cutoff) && !settled[tx.ID] {
+			result = append(result, tx)
+		}
+	}
+	return result, nil
+}
+
+type memLimitRepo struct{ s *memStore }
+
+func (r *memLimitRepo) GetOverride(userID uuid.UUID, currency string) (*domain.LimitOverride, error) {
+	if err := r.s.step("Limits.GetOverride"); err != nil {
+		return nil, err
+	}
+	override, ok := r.s.overrides[walletKey(userID, currency)]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &override, nil
+}
+
+func (r *memLimitRepo) GetOverrides(userID uuid.UUID) ([]domain.LimitOverride, error) {
+	if err := r.s.step("Limits.GetOverrides"); err != nil {
+		return nil, err
+	}
+	var result []domain.LimitOverride
+	for _, override := range r.s.overrides {
+		if override.UserID == userID {
+			result = append(result, override)
+		}
+	}
+	return result, nil
+}
+
+func (r *memLimitRepo) UpsertOverride(override *domain.LimitOverride) error {
+	if err := r.s.step("Limits.UpsertOverride"); err != nil {
+		return err
+	}
+	r.s.overrides[walletKey(override.UserID, override.Currency)] = *override
+	return nil
+}
+
+func (r *memLimitRepo) DeleteOverride(userID uuid.UUID, currency string) error {
+	if err := r.s.step("Limits.DeleteOverride"); err != nil {
+		return err
+	}
+	delete(r.s.overrides, walletKey(userID, currency))
+	return nil
+}
+
+type memPaymentIntentRepo struct{ s *memStore }
+
+func (r *memPaymentIntentRepo) Create(intent *domain.PaymentIntent) error {
+	if err := r.s.step("Intents.Create"); err != nil {
+		return err
+	}
+	r.s.intents[intent.ID] = *intent
+	return nil
+}
+
+func (r *memPaymentIntentRepo) GetByID(id uuid.UUID) (*domain.PaymentIntent, error) {
+	intent, ok := r.s.intents[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &intent, nil
+}
+
+func (r *memPaymentIntentRepo) GetByIDForUpdate(id uuid.UUID) (*domain.PaymentIntent, error) {
+	return r.GetByID(id)
+}
+
+func (r *memPaymentIntentRepo) GetByMerchantID(merchantID uuid.UUID) ([]domain.PaymentIntent, error) {
+	var result []domain.PaymentIntent
+	for _, intent := range r.s.intents {
+		if intent.MerchantID == merchantID {
+			result = append(result, intent)
+		}
+	}
+	return result, nil
+}
+
+func (r *memPaymentIntentRepo) Update(intent *domain.PaymentIntent) error {
+	if err := r.s.step("Intents.Update"); err != nil {
+		return err
+	}
+	r.s.intents[intent.ID] = *intent
+	return nil
+}
+
+func (r *memPaymentIntentRepo) GetExpired(now time.Time, limit int) ([]domain.PaymentIntent, error) {
+	var result []domain.PaymentIntent
+	for _, intent := range r.s.intents {
+		if intent.Open() && intent.ExpiresAt.Before(now) && len(result) < limit {
+			result = append(result, intent)
+		}
+	}
+	return result, nil
+}
+
+type memMoneyRequestRepo struct{ s *memStore }
+
+func (r *memMoneyRequestRepo) Create(request *domain.MoneyRequest) error {
+	if err := r.s.step("Requests.Create"); err != nil {
+		return err
+	}
+	r.s.requests[request.ID] = *request
+	return nil
+}
+
+func (r *memMoneyRequestRepo) GetByID(id uuid.UUID) (*domain.MoneyRequest, error) {
+	request, ok := r.s.requests[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &request, nil
+}
+
+func (r *memMoneyRequestRepo) GetByIDForUpdate(id uuid.UUID) (*domain.MoneyRequest, error) {
+	return r.GetByID(id)
+}
+
+func (r *memMoneyRequestRepo) GetByTransferIDForUpdate(transferID uuid.UUID) (*domain.MoneyRequest, error) {
+	for _, request := range r.s.requests {
+		if request.TransferID != nil && *request.TransferID == transferID {
+			return &request, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memMoneyRequestRepo) GetIncoming(payerID uuid.UUID) ([]domain.MoneyRequest, error) {
+	var result []domain.MoneyRequest
+	for _, request := range r.s.requests {
+		if request.PayerID == payerID {
+			result = append(result, request)
+		}
+	}
+	return result, nil
+}
+
+func (r *memMoneyRequestRepo) GetOutgoing(requesterID uuid.UUID) ([]domain.MoneyRequest, error) {
+	var result []domain.MoneyRequest
+	for _, request := range r.s.requests {
+		if request.RequesterID == requesterID {
+			result = append(result, request)
+		}
+	}
+	return result, nil
+}
+
+func (r *memMoneyRequestRepo) Update(request *domain.MoneyRequest) error {
+	if err := r.s.step("Requests.Update"); err != nil {
+		return err
+	}
+	r.s.requests[request.ID] = *request
+	return nil
+}
+
+func (r *memMoneyRequestRepo) GetExpired(now time.Time, limit int) ([]domain.MoneyRequest, error) {
+	var result []domain.MoneyRequest
+	for _, request := range r.s.requests {
+		if request.Status == domain.MoneyRequestStatusPending && request.ExpiresAt.Before(now) && len(result) < limit {
+			result = append(result, request)
+		}
+	}
+	return result, nil
+}
+
+func (r *memMoneyRequestRepo) CreateEvent(event *domain.MoneyRequestEvent) error {
+	if err := r.s.step("Requests.CreateEvent"); err != nil {
+		return err
+	}
+	r.s.requestEvents = append(r.s.requestEvents, *event)
+	return nil
+}
+
+func (r *memMoneyRequestRepo) GetEvents(requestID uuid.UUID) ([]domain.MoneyRequestEvent, error) {
+	var result []domain.MoneyRequestEvent
+	for _, event := range r.s.requestEvents {
+		if event.RequestID == requestID {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+type memBillRepo struct{ s *memStore }
+
+func (r *memBillRepo) Create(bill *domain.Bill) error {
+	if err := r.s.step("Bills.Create"); err != nil {
+		return err
+	}
+	r.s.bills[bill.ID] = *bill
+	return nil
+}
+
+func (r *memBillRepo) CreateParticipants(participants []domain.BillParticipant) error {
+	if err := r.s.step("Bills.CreateParticipants"); err != nil {
+		return err
+	}
+	r.s.participants = append(r.s.participants, participants...)
+	return nil
+}
+
+func (r *memBillRepo) GetByID(id uuid.UUID) (*domain.Bill, error) {
+	bill, ok := r.s.bills[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &bill, nil
+}
+
+func (r *memBillRepo) GetParticipants(billID uuid.UUID) ([]domain.BillParticipant, error) {
+	var result []domain.BillParticipant
+	for _, p := range r.s.participants {
+		if p.BillID == billID {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+func (r *memBillRepo) GetByUserID(userID uuid.UUID) ([]domain.Bill, error) {
+	var result []domain.Bill
+	for _, bill := range r.s.bills {
+		participants, _ := r.GetParticipants(bill.ID)
+		if bill.CreatorID == userID || slices.ContainsFunc(participants, func(p domain.BillParticipant) bool { return p.UserID == userID }) {
+			result = append(result, bill)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,481 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidPromoCode = errors.New("promo code is not valid for this transaction")
+
+// FeeBandConfig is one band of a tiered fee. It applies to amounts up to
+// UpTo, or to any larger amount when UpTo is empty.
+type FeeBandConfig struct {
+	UpTo    string `mapstructure:"up_to"`
+	Flat    string `mapstructure:"flat"`
+	RateBps int64  `mapstructure:"rate_bps"`
+}
+
+// FeeRuleConfig charges Flat plus RateBps of the amount, or what the band the
+// amount falls in says, clamped to Min and Max. Amounts are decimal strings
+// in Currency. An empty Tier matches every limits tier.
+type FeeRuleConfig struct {
+	Type     string          `mapstructure:"type"`
+	Currency string          `mapstructure:"currency"`
+	Tier     string          `mapstructure:"tier"`
+	Flat     string          `mapstructure:"flat"`
+	RateBps  int64           `mapstructure:"rate_bps"`
+	Min      string          `mapstructure:"min"`
+	Max      string          `mapstructure:"max"`
+	Bands    []FeeBandConfig `mapstructure:"bands"`
+}
+
+// FeePromotionConfig waives the fees of Types for requests that carry Code
+// between StartsAt and EndsAt, given in RFC 3339. An empty bound is open.
+type FeePromotionConfig struct {
+	Code     string   `mapstructure:"code"`
+	Types    []string `mapstructure:"types"`
+	StartsAt string   `mapstructure:"starts_at"`
+	EndsAt   string   `mapstructure:"ends_at"`
+}
+
+// FeesConfig is the fee schedule. The first rule that matches the type,
+// currency and tier of a transaction sets its fee; without one it is free.
+type FeesConfig struct {
+	Rules      []FeeRuleConfig      `mapstructure:"rules"`
+	Promotions []FeePromotionConfig `mapstructure:"promotions"`
+}
+
+// Fee is what a top-up, payment or transfer of Amount costs on top of it.
+type Fee struct {
+	Type      domain.FeeType `json:"transaction_type"`
+	Amount    money.Money    `json:"amount"`
+	Fee       money.Money    `json:"fee"`
+	Waived    bool           `json:"waived"`
+	PromoCode string         `json:"promo_code,omitempty"`
+}
+
+type feeBand struct {
+	upTo    *money.Money
+	flat    money.Money
+	rateBps int64
+}
+
+type feeRule struct {
+	feeType  domain.FeeType
+	currency string
+	tier     string
+	bands    []feeBand
+	min, max *money.Money
+}
+
+type feePromotion struct {
+	types            []domain.FeeType
+	startsAt, endsAt time.Time
+}
+
+// FeeUsecase evaluates the fee schedule. Fees are charged by
+// TransactionUsecase as debits of their own, linked to the transaction they
+// are for and credited to the fee revenue account.
+type FeeUsecase struct {
+	userRepo   domain.UserRepository
+	limits     *LimitsUsecase
+	rules      []feeRule
+	promotions map[string]feePromotion
+}
+
+// NewFeeUsecase parses the fee schedule. Types, currencies, tiers and promo
+// codes are case insensitive.
+func NewFeeUsecase(userRepo domain.UserRepository, limits *LimitsUsecase, config FeesConfig) (*FeeUsecase, error) {
+	u := &FeeUsecase{
+		userRepo:   userRepo,
+		limits:     limits,
+		promotions: make(map[string]feePromotion),
+	}
+
+	for i, in := range config.Rules {
+		rule, err := parseFeeRule(in)
+		if err != nil {
+			return nil, fmt.Errorf("fee rule %d: %w", i+1, err)
+		}
+		u.rules = append(u.rules, rule)
+	}
+
+	for _, in := range config.Promotions {
+		code := strings.ToUpper(in.Code)
+		promotion, err := parseFeePromotion(in)
+		if err != nil {
+			return nil, fmt.Errorf("promotion %s: %w", code, err)
+		}
+		u.promotions[code] = promotion
+	}
+
+	return u, nil
+}
+
+func parseFeeType(feeType string) (domain.FeeType, error) {
+	t := domain.FeeType(strings.ToUpper(feeType))
+	switch t {
+	case domain.FeeTypeTopUp, domain.FeeTypePayment, domain.FeeTypeTransfer:
+		return t, nil
+	default:
+		return "", fmt.Errorf("unknown transaction type %q", feeType)
+	}
+}
+
+// parseFee parses a fee amount; an empty fee is zero.
+func parseFee(amount, currency string) (money.Money, error) {
+	if amount == "" {
+		return money.Zero(currency), nil
+	}
+	m, err := money.Parse(amount, currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if m.IsNegative() {
+		return money.Money{}, errors.New("fees cannot be negative")
+	}
+	return m, nil
+}
+
+func parseFeeBand(currency, upTo, flat string, rateBps int64) (feeBand, error) {
+	if rateBps < 0 || rateBps > 10000 {
+		return feeBand{}, errors.New("rate_bps must be between 0 and 10000")
+	}
+	band := feeBand{rateBps: rateBps}
+	var err error
+	if band.upTo, err = parseLimit(upTo, currency); err != nil {
+		return feeBand{}, err
+	}
+	if band.flat, err = parseFee(flat, currency); err != nil {
+		return feeBand{}, err
+	}
+	return band, nil
+}
+
+func parseFeeRule(in FeeRuleConfig) (feeRule, error) {
+	feeType, err := parseFeeType(in.Type)
+	if err != nil {
+		return feeRule{}, err
+	}
+
+	currency := strings.ToUpper(in.Currency)
+	if err := money.ValidateCurrency(currency); err != nil {
+		return feeRule{}, err
+	}
+
+	rule := feeRule{
+		feeType:  feeType,
+		currency: currency,
+		tier:     strings.ToUpper(in.Tier),
+	}
+
+	if len(in.Bands) == 0 {
+		band, err := parseFeeBand(currency, "", in.Flat, in.RateBps)
+		if err != nil {
+			return feeRule{}, err
+		}
+		rule.bands = []feeBand{band}
+	}
+	for _, b := range in.Bands {
+		band, err := parseFeeBand(currency, b.UpTo, b.Flat, b.RateBps)
+		if err != nil {
+			return feeRule{}, err
+		}
+		rule.bands = append(rule.bands, band)
+	}
+
+	if rule.min, err = parseLimit(in.Min, currency); err != nil {
+		return feeRule{}, err
+	}
+	if rule.max, err = parseLimit(in.Max, currency); err != nil {
+		return feeRule{}, err
+	}
+	if rule.min != nil && rule.max != nil && rule.max.LessThan(*rule.min) {
+		return feeRule{}, errors.New("max is less than min")
+	}
+
+	return rule, nil
+}
+
+func parseFeePromotion(in FeePromotionConfig) (feePromotion, error) {
+	var promotion feePromotion
+	for _, t := range in.Types {
+		feeType, err := parseFeeType(t)
+		if err != nil {
+			return feePromotion{}, err
+		}
+		promotion.types = append(promotion.types, feeType)
+	}
+
+	var err error
+	if in.StartsAt != "" {
+		if promotion.startsAt, err = time.Parse(time.RFC3339, in.StartsAt); err != nil {
+			return feePromotion{}, err
+		}
+	}
+	if in.EndsAt != "" {
+		if promotion.endsAt, err = time.Parse(time.RFC3339, in.EndsAt); err != nil {
+			return feePromotion{}, err
+		}
+	}
+
+	return promotion, nil
+}
+
+// Preview tells a user what a transaction would cost, without charging
+// anything.
+func (u *FeeUsecase) Preview(userID uuid.UUID, feeType string, amount money.Money, promoCode string) (*Fee, error) {
+	t, err := parseFeeType(feeType)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAmount(amount); err != nil {
+		return nil, err
+	}
+
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	fee, err := u.evaluate(user, t, amount, promoCode, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &fee, nil
+}
+
+// feeOf evaluates the fee of a transaction from inside a unit of work.
+func (u *FeeUsecase) feeOf(repos *domain.Repositories, userID uuid.UUID, feeType domain.FeeType, amount money.Money, promoCode string) (Fee, error) {
+	user, err := repos.Users.GetByID(userID)
+	if err != nil {
+		return Fee{}, err
+	}
+	return u.evaluate(user, feeType, amount, promoCode, time.Now())
+}
+
+func (u *FeeUsecase) evaluate(user *domain.User, feeType domain.FeeType, amount money.Money, promoCode string, now time.Time) (Fee, error) {
+	fee := Fee{
+		Type:   feeType,
+		Amount: amount,
+		Fee:    money.Zero(amount.Currency),
+	}
+
+	if promoCode != "" {
+		code := strings.ToUpper(promoCode)
+		promotion, ok := u.promotions[code]
+		if !ok || !promotion.applies(feeType, now) {
+			return Fee{}, ErrInvalidPromoCode
+		}
+		fee.Waived = true
+		fee.PromoCode = code
+		return fee, nil
+	}
+
+	tier := u.limits.tierOf(user)
+	for _, rule := range u.rules {
+		if rule.feeType == feeType && rule.currency == amount.Currency && (rule.tier == "" || rule.tier == tier) {
+			fee.Fee = rule.fee(amount)
+			break
+		}
+	}
+	return fee, nil
+}
+
+func (p feePromotion) applies(feeType domain.FeeType, now time.Time) bool {
+	if !slices.Contains(p.types, feeType) {
+		return false
+	}
+	if !p.startsAt.IsZero() && now.Before(p.startsAt) {
+		return false
+	}
+	return p.endsAt.IsZero() || now.Before(p.endsAt)
+}
+
+func (r feeRule) fee(amount money.Money) money.Money {
+	band := r.bands[len(r.bands)-1]
+	for _, b := range r.bands {
+		if b.upTo == nil || !b.upTo.LessThan(amount) {
+			band = b
+			break
+		}
+	}
+
+	fee := band.flat.Add(money.New(applyBps(amount.Minor, band.rateBps), amount.Currency))
+
+	if r.min != nil && fee.LessThan(*r.min) {
+		fee = *r.min
+	}
+	if r.max != nil && r.max.LessThan(fee) {
+		fee = *r.max
+	}
+	return fee
+}
+
+// chargeFee debits the fee of a settled top-up or payment from the user's
+// wallet.
+func chargeFee(repos *domain.Repositories, tx *domain.Transaction, fee money.Money) error {
+	if !fee.IsPositive() {
+		return nil
+	}
+
+	before, after, err := debitBalance(repos, tx.UserID, fee)
+	if err != nil {
+		return err
+	}
+
+	feeTx := feeTransaction(tx, fee)
+	feeTx.Status = domain.TransactionStatusSuccess
+	feeTx.BalanceBefore = before
+	feeTx.BalanceAfter = after
+	feeTx.SettledAt = &feeTx.CreatedAt
+	if err := repos.Transactions.Create(feeTx); err != nil {
+		return err
+	}
+
+	return postFee(repos, feeTx)
+}
+
+// holdFee reserves the fee of a pending transfer next to its amount.
+func holdFee(repos *domain.Repositories, tx *domain.Transaction, fee money.Money) error {
+	if !fee.IsPositive() {
+		return nil
+	}
+
+	wallet, err := placeHold(repos, tx.UserID, fee)
+	if err != nil {
+		return err
+	}
+
+	feeTx := feeTransaction(tx, fee)
+	feeTx.Status = domain.TransactionStatusPending
+	feeTx.BalanceBefore = wallet.Balance
+	feeTx.BalanceAfter = wallet.Balance.Sub(fee)
+	return repos.Transactions.Create(feeTx)
+}
+
+// settleFee captures the held fee of a transfer that is being settled.
+func settleFee(repos *domain.Repositories, tx *domain.Transaction) error {
+	fees, err := repos.Transactions.GetByReference(tx.ID, domain.ReferenceTypeFee)
+	if err != nil {
+		return err
+	}
+
+	for i := range fees {
+		feeTx := &fees[i]
+		if feeTx.Status != domain.TransactionStatusPending {
+			continue
+		}
+
+		before, after, err := captureHold(repos, feeTx.UserID, feeTx.Amount)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		feeTx.Status = domain.TransactionStatusSuccess
+		feeTx.BalanceBefore = before
+		feeTx.BalanceAfter = after
+		feeTx.SettledAt = &now
+		feeTx.UpdatedAt = now
+		if err := repos.Transactions.Update(feeTx); err != nil {
+			return err
+		}
+
+		if err := postFee(repos, feeTx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseFee gives back the held fee of a transfer that did not go through
+// and leaves the fee with the status of the transfer.
+func releaseFee(repos *domain.Repositories, tx *domain.Transaction) error {
+	fees, err := repos.Transactions.GetByReference(tx.ID, domain.ReferenceTypeFee)
+	if err != nil {
+		return err
+	}
+
+	for i := range fees {
+		feeTx := &fees[i]
+		if feeTx.Status != domain.TransactionStatusPending {
+			continue
+		}
+
+		if err := releaseHold(repos, feeTx.UserID, feeTx.Amount); err != nil {
+			return err
+		}
+
+		feeTx.Status = tx.Status
+		feeTx.UpdatedAt = time.Now()
+		if err := repos.Transactions.Update(feeTx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// captureFee charges up to fee from the fee held for an authorization and
+// releases the rest of the hold. A fee that drops to zero is voided.
+func captureFee(repos *domain.Repositories, auth *domain.Transaction, fee money.Money) error {
+	fees, err := repos.Transactions.GetByReference(auth.ID, domain.ReferenceTypeFee)
+	if err != nil {
+		return err
+	}
+
+	for i := range fees {
+		feeTx := &fees[i]
+		if feeTx.Status != domain.TransactionStatusPending {
+			continue
+		}
+
+		over := feeTx.Amount.Sub(fee)
+		if !over.IsPositive() {
+			continue
+		}
+		if err := releaseHold(repos, feeTx.UserID, over); err != nil {
+			return err
+		}
+
+		feeTx.Amount = feeTx.Amount.Sub(over)
+		if feeTx.Amount.IsZero() {
+			feeTx.Status = domain.TransactionStatusVoided
+		}
+		feeTx.UpdatedAt = time.Now()
+		if err := repos.Transactions.Update(feeTx); err != nil {
+			return err
+		}
+	}
+
+	return settleFee(repos, auth)
+}
+
+func feeTransaction(tx *domain.Transaction, fee money.Money) *domain.Transaction {
+	now := time.Now()
+	return &domain.Transaction{
+		ID:            uuid.New(),
+		UserID:        tx.UserID,
+		Type:          domain.TransactionTypeDebit,
+		Amount:        fee,
+		Remarks:       "fee",
+		ReferenceID:   tx.ID,
+		ReferenceType: domain.ReferenceTypeFee,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+func postFee(repos *domain.Repositories, feeTx *domain.Transaction) error {
+	return postJournal(repos, feeTx.ID, "fee",
+		debitLine(userAccount(feeTx.UserID), feeTx.Amount),
+		creditLine(systemAccount(domain.AccountFeeRevenue, domain.AccountTypeRevenue), feeTx.Amount),
+	)
+}
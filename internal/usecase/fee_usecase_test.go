@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFeesConfig = FeesConfig{
+	Rules: []FeeRuleConfig{
+		{Type: "transfer", Currency: "idr", Flat: "25"},
+		{Type: "payment", Currency: "idr", RateBps: 100, Min: "5", Max: "20"},
+		{Type: "top_up", Currency: "idr", Bands: []FeeBandConfig{
+			{UpTo: "100", Flat: "1"},
+			{RateBps: 50},
+		}},
+	},
+	Promotions: []FeePromotionConfig{
+		{Code: "freetransfer", Types: []string{"TRANSFER"}},
+		{Code: "over", Types: []string{"TRANSFER"}, EndsAt: "2020-01-01T00:00:00Z"},
+	},
+}
+
+func newTestFeeUsecase(t *testing.T, store *memStore) *FeeUsecase {
+	t.Helper()
+
+	fees, err := NewFeeUsecase(store.repos().Users, noLimits(store), testFeesConfig)
+	require.NoError(t, err)
+	return fees
+}
+
+func TestFeeUsecase_Preview(t *testing.T) {
+	store := newMemStore()
+	userID := store.addUser(idr(0))
+	fees := newTestFeeUsecase(t, store)
+
+	tests := []struct {
+		name      string
+		feeType   string
+		amount    int64
+		promoCode string
+		want      int64
+	}{
+		{"flat", "TRANSFER", 100000, "", 2500},
+		{"percentage clamped to min", "PAYMENT", 10000, "", 500},
+		{"percentage", "PAYMENT", 150000, "", 1500},
+		{"percentage clamped to max", "PAYMENT", 1000000, "", 2000},
+		{"first band", "TOP_UP", 10000, "", 100},
+		{"open band", "TOP_UP", 20001, "", 100},
+		{"waived", "TRANSFER", 100000, "FREETRANSFER", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, err := fees.Preview(userID, tt.feeType, idr(tt.amount), tt.promoCode)
+			require.NoError(t, err)
+			assert.Equal(t, idr(tt.want), fee.Fee)
+			assert.Equal(t, tt.promoCode != "", fee.Waived)
+		})
+	}
+
+	_, err := fees.Preview(userID, "PAYMENT", idr(10000), "FREETRANSFER")
+	assert.ErrorIs(t, err, ErrInvalidPromoCode)
+	_, err = fees.Preview(userID, "TRANSFER", idr(10000), "OVER")
+	assert.ErrorIs(t, err, ErrInvalidPromoCode)
+}
+
+func TestTransactionUsecase_Fees(t *testing.T) {
+	t.Run("charges the fee of a payment as a linked debit", func(t *testing.T) {
+		store := newMemStore()
+		userID := store.addUser(idr(100000))
+		uc := newTransactionUsecaseWith(store, noLimits(store), newTestFeeUsecase(t, store))
+
+		tx, err := uc.Payment(userID, idr(50000), "groceries", PaymentOptions{})
+		require.NoError(t, err)
+
+		feeTxs := store.transactionsByReference(tx.ID, domain.ReferenceTypeFee)
+		require.Len(t, feeTxs, 1)
+		assert.Equal(t, idr(500), feeTxs[0].Amount)
+		assert.Equal(t, domain.TransactionStatusSuccess, feeTxs[0].Status)
+		assert.Equal(t, idr(100000-50000-500), store.wallet(userID).Balance)
+	})
+
+	t.Run("holds the fee of a transfer and settles or releases it with the transfer", func(t *testing.T) {
+		store := newMemStore()
+		fromID := store.addUser(idr(10000))
+		toID := store.addUser(idr(0))
+		uc := newTransactionUsecaseWith(store, noLimits(store), newTestFeeUsecase(t, store))
+
+		settled, err := uc.Transfer(fromID, toID, idr(3000), "rent", TransferOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, idr(5500), store.wallet(fromID).HeldBalance)
+		require.NoError(t, uc.ProcessTransfer(settled.ID))
+
+		cancelled, err := uc.Transfer(fromID, toID, idr(1000), "rent", TransferOptions{})
+		require.NoError(t, err)
+		_, err = uc.CancelTransfer(fromID, cancelled.ID)
+		require.NoError(t, err)
+
+		_, err = uc.Transfer(fromID, toID, idr(1000), "rent", TransferOptions{PromoCode: "freetransfer"})
+		require.NoError(t, err)
+
+		assert.Equal(t, idr(10000-3000-2500), store.wallet(fromID).Balance)
+		assert.Equal(t, idr(1000), store.wallet(fromID).HeldBalance)
+		assert.Equal(t, domain.TransactionStatusSuccess, store.transactionsByReference(settled.ID, domain.ReferenceTypeFee)[0].Status)
+		assert.Equal(t, domain.TransactionStatusCancelled, store.transactionsByReference(cancelled.ID, domain.ReferenceTypeFee)[0].Status)
+	})
+}
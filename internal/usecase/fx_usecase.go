@@ -0,0 +1,313 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRateUnavailable = errors.New("no exchange rate for this currency pair")
+	ErrQuoteNotFound   = errors.New("quote not found")
+	ErrQuoteExpired    = errors.New("quote has expired")
+	ErrQuoteUsed       = errors.New("quote has already been used")
+	ErrQuoteRequired   = errors.New("a quote is required to convert")
+	ErrQuoteMismatch   = errors.New("quote does not match the request")
+)
+
+type FXConfig struct {
+	// QuoteTTL is how long a quoted rate can be used.
+	QuoteTTL time.Duration
+}
+
+// FXRateInput is one rate as accepted from the admin endpoint or a rates file.
+type FXRateInput struct {
+	BaseCurrency  string `json:"base_currency"`
+	QuoteCurrency string `json:"quote_currency"`
+	Rate          string `json:"rate"`
+	SpreadBps     int    `json:"spread_bps"`
+}
+
+// Conversion is the result of converting at a quote.
+type Conversion struct {
+	Quote  *domain.FXQuote     `json:"quote"`
+	Debit  *domain.Transaction `json:"debit"`
+	Credit *domain.Transaction `json:"credit"`
+}
+
+type FXUsecase struct {
+	uow    domain.UnitOfWork
+	fxRepo domain.FXRepository
+	config FXConfig
+}
+
+func NewFXUsecase(uow domain.UnitOfWork, fxRepo domain.FXRepository, config FXConfig) *FXUsecase {
+	return &FXUsecase{
+		uow:    uow,
+		fxRepo: fxRepo,
+		config: config,
+	}
+}
+
+func (u *FXUsecase) ListRates() ([]domain.FXRate, error) {
+	return u.fxRepo.ListRates()
+}
+
+// SetRates validates all rates and stores them together, replacing existing
+// rates for the same pairs.
+func (u *FXUsecase) SetRates(inputs []FXRateInput) ([]domain.FXRate, error) {
+	now := time.Now()
+	rates := make([]domain.FXRate, 0, len(inputs))
+	for _, in := range inputs {
+		if err := money.ValidateCurrency(in.BaseCurrency); err != nil {
+			return nil, err
+		}
+		if err := money.ValidateCurrency(in.QuoteCurrency); err != nil {
+			return nil, err
+		}
+		if in.BaseCurrency == in.QuoteCurrency {
+			return nil, fmt.Errorf("rate %s/%s converts a currency into itself", in.BaseCurrency, in.QuoteCurrency)
+		}
+		rate, err := money.ParseRate(in.Rate)
+		if err != nil {
+			return nil, fmt.Errorf("rate %s/%s: %w", in.BaseCurrency, in.QuoteCurrency, err)
+		}
+		if in.SpreadBps < 0 || in.SpreadBps >= 10000 {
+			return nil, fmt.Errorf("rate %s/%s: spread must be between 0 and 9999 bps", in.BaseCurrency, in.QuoteCurrency)
+		}
+
+		rates = append(rates, domain.FXRate{
+			BaseCurrency:  in.BaseCurrency,
+			QuoteCurrency: in.QuoteCurrency,
+			Rate:          money.FormatRate(rate),
+			SpreadBps:     in.SpreadBps,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		for i := range rates {
+			if err := repos.FX.UpsertRate(&rates[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rates, nil
+}
+
+// LoadRatesFile loads rates from a JSON file holding a list of FXRateInput.
+func (u *FXUsecase) LoadRatesFile(path string) ([]domain.FXRate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs []FXRateInput
+	if err := json.Unmarshal(data, &inputs); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return u.SetRates(inputs)
+}
+
+// Quote prices the conversion of amount into targetCurrency and locks the
+// rate for the user until the quote expires.
+func (u *FXUsecase) Quote(userID uuid.UUID, amount money.Money, targetCurrency string) (*domain.FXQuote, error) {
+	if err := checkAmount(amount); err != nil {
+		return nil, err
+	}
+	if err := money.ValidateCurrency(targetCurrency); err != nil {
+		return nil, err
+	}
+	if targetCurrency == amount.Currency {
+		return nil, fmt.Errorf("amount is already in %s", targetCurrency)
+	}
+
+	rate, spreadBps, err := lookupRate(u.fxRepo, amount.Currency, targetCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := amount.Convert(applySpread(rate, spreadBps), targetCurrency)
+	if err != nil {
+		return nil, err
+	}
+	if !target.IsPositive() {
+		return nil, errors.New("amount is too small to convert")
+	}
+
+	now := time.Now()
+	quote := &domain.FXQuote{
+		ID:           uuid.New(),
+		UserID:       userID,
+		SourceAmount: amount,
+		TargetAmount: target,
+		Rate:         money.FormatRate(rate),
+		SpreadBps:    spreadBps,
+		ExpiresAt:    now.Add(u.config.QuoteTTL),
+		CreatedAt:    now,
+	}
+	if err := u.fxRepo.CreateQuote(quote); err != nil {
+		return nil, err
+	}
+
+	return quote, nil
+}
+
+// Convert moves the quoted amount from the user's source wallet into their
+// target wallet.
+func (u *FXUsecase) Convert(userID, quoteID uuid.UUID) (*Conversion, error) {
+	var conversion *Conversion
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		var err error
+		conversion, err = convertAtQuote(repos, userID, quoteID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return conversion, nil
+}
+
+// lookupRate returns the mid rate from one currency into another, using the
+// inverse of the reverse pair when only that one is stored.
+func lookupRate(repo domain.FXRepository, from, to string) (*big.Rat, int, error) {
+	stored, err := repo.GetRate(from, to)
+	if err == nil {
+		rate, err := money.ParseRate(stored.Rate)
+		return rate, stored.SpreadBps, err
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, 0, err
+	}
+
+	stored, err = repo.GetRate(to, from)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, 0, ErrRateUnavailable
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rate, err := money.ParseRate(stored.Rate)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rate.Inv(rate), stored.SpreadBps, nil
+}
+
+// applySpread returns the rate the user gets, the mid rate less the spread.
+func applySpread(rate *big.Rat, spreadBps int) *big.Rat {
+	factor := big.NewRat(int64(10000-spreadBps), 10000)
+	return new(big.Rat).Mul(rate, factor)
+}
+
+// convertAtQuote debits the source wallet and credits the target wallet at a
+// quote and marks the quote used. Each leg is journaled against the FX
+// position account of its currency. The user row is locked before the quote,
+// the same order a cross-currency transfer takes them in, so a conversion and
+// a transfer using the same quote cannot deadlock.
+func convertAtQuote(repos *domain.Repositories, userID, quoteID uuid.UUID) (*Conversion, error) {
+	if _, err := repos.Users.GetByIDForUpdate(userID); err != nil {
+		return nil, err
+	}
+
+	quote, err := repos.FX.GetQuoteForUpdate(quoteID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrQuoteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	switch {
+	case quote.UserID != userID:
+		return nil, ErrQuoteNotFound
+	case quote.UsedAt != nil:
+		return nil, ErrQuoteUsed
+	case now.After(quote.ExpiresAt):
+		return nil, ErrQuoteExpired
+	}
+
+	debitBefore, debitAfter, err := debitBalance(repos, userID, quote.SourceAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	creditBefore, creditAfter, err := creditBalance(repos, userID, quote.TargetAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	remarks := fmt.Sprintf("convert %s to %s", quote.SourceAmount.Currency, quote.TargetAmount.Currency)
+	leg := func(txType domain.TransactionType, amount, before, after money.Money) *domain.Transaction {
+		return &domain.Transaction{
+			ID:            uuid.New(),
+			UserID:        userID,
+			Type:          txType,
+			Status:        domain.TransactionStatusSuccess,
+			Amount:        amount,
+			Remarks:       remarks,
+			BalanceBefore: before,
+			BalanceAfter:  after,
+			ReferenceID:   quote.ID,
+			ReferenceType: domain.ReferenceTypeConversion,
+			ExchangeRate:  quote.Rate,
+			SpreadBps:     quote.SpreadBps,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+			SettledAt:     &now,
+		}
+	}
+	conversion := &Conversion{
+		Quote:  quote,
+		Debit:  leg(domain.TransactionTypeDebit, quote.SourceAmount, debitBefore, debitAfter),
+		Credit: leg(domain.TransactionTypeCredit, quote.TargetAmount, creditBefore, creditAfter),
+	}
+
+	position := systemAccount(domain.AccountFXPosition, domain.AccountTypeEquity)
+	for _, tx := range []*domain.Transaction{conversion.Debit, conversion.Credit} {
+		if err := repos.Transactions.Create(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	err = postJournal(repos, conversion.Debit.ID, "conversion",
+		debitLine(userAccount(userID), quote.SourceAmount),
+		creditLine(position, quote.SourceAmount),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = postJournal(repos, conversion.Credit.ID, "conversion",
+		debitLine(position, quote.TargetAmount),
+		creditLine(userAccount(userID), quote.TargetAmount),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	quote.UsedAt = &now
+	if err := repos.FX.UpdateQuote(quote); err != nil {
+		return nil, err
+	}
+
+	return conversion, nil
+}
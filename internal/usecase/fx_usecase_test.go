@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFXUsecase(t *testing.T, store *memStore) *FXUsecase {
+	t.Helper()
+
+	uc := NewFXUsecase(store, store.repos().FX, FXConfig{QuoteTTL: 30 * time.Second})
+	_, err := uc.SetRates([]FXRateInput{
+		{BaseCurrency: "USD", QuoteCurrency: "IDR", Rate: "16000", SpreadBps: 50},
+	})
+	require.NoError(t, err)
+	store.calls = 0
+
+	return uc
+}
+
+func TestFXUsecase_Convert(t *testing.T) {
+	t.Run("converts at the quoted rate less the spread", func(t *testing.T) {
+		store := newMemStore()
+		userID := store.addUser(idr(2000000))
+		uc := newTestFXUsecase(t, store)
+
+		quote, err := uc.Quote(userID, idr(1600000), "USD")
+		require.NoError(t, err)
+		assert.Equal(t, money.New(99, "USD"), quote.TargetAmount)
+
+		conversion, err := uc.Convert(userID, quote.ID)
+		require.NoError(t, err)
+
+		assert.Equal(t, idr(400000), store.wallet(userID).Balance)
+		assert.Equal(t, money.New(99, "USD"), store.wallets[walletKey(userID, "USD")].Balance)
+		assert.Equal(t, domain.ReferenceTypeConversion, conversion.Debit.ReferenceType)
+		assert.Equal(t, quote.ID, conversion.Credit.ReferenceID)
+		assert.Equal(t, "0.0000625", conversion.Credit.ExchangeRate)
+		assert.Equal(t, 50, conversion.Credit.SpreadBps)
+		assert.Len(t, store.entries, 2)
+
+		_, err = uc.Convert(userID, quote.ID)
+		assert.ErrorIs(t, err, ErrQuoteUsed)
+	})
+
+	t.Run("rejects an expired quote", func(t *testing.T) {
+		store := newMemStore()
+		userID := store.addUser(money.New(1000, "USD"))
+		uc := newTestFXUsecase(t, store)
+
+		quote, err := uc.Quote(userID, money.New(500, "USD"), "IDR")
+		require.NoError(t, err)
+		assert.Equal(t, idr(7960000), quote.TargetAmount)
+
+		expired := store.quotes[quote.ID]
+		expired.ExpiresAt = time.Now().Add(-time.Second)
+		store.quotes[quote.ID] = expired
+
+		_, err = uc.Convert(userID, quote.ID)
+		assert.ErrorIs(t, err, ErrQuoteExpired)
+		assert.Equal(t, money.New(1000, "USD"), store.wallets[walletKey(userID, "USD")].Balance)
+		assert.Empty(t, store.transactions)
+	})
+
+	t.Run("sends a converted transfer", func(t *testing.T) {
+		store := newMemStore()
+		fromID := store.addUser(money.New(1000, "USD"))
+		toID := store.addUser(idr(0))
+		uc := newTestFXUsecase(t, store)
+
+		quote, err := uc.Quote(fromID, money.New(500, "USD"), "IDR")
+		require.NoError(t, err)
+
+		tx, err := newTestTransactionUsecase(store).Transfer(fromID, toID, money.New(500, "USD"), "rent", TransferOptions{
+			TargetCurrency: "IDR",
+			Convert:        true,
+			QuoteID:        quote.ID,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, quote.TargetAmount, tx.Amount)
+		assert.Equal(t, quote.TargetAmount, store.wallet(fromID).HeldBalance)
+		assert.Equal(t, money.New(500, "USD"), store.wallets[walletKey(fromID, "USD")].Balance)
+	})
+}
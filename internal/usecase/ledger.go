@@ -0,0 +1,173 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ledgerAccount struct {
+	code        string
+	accountType domain.AccountType
+	userID      *uuid.UUID
+}
+
+// userAccount is the wallet liability the platform owes to a user.
+func userAccount(userID uuid.UUID) ledgerAccount {
+	return ledgerAccount{
+		code:        domain.UserAccountCode(userID),
+		accountType: domain.AccountTypeLiability,
+		userID:      &userID,
+	}
+}
+
+// pocketAccount is the liability for money a user has set aside in a pocket.
+func pocketAccount(pocket *domain.Pocket) ledgerAccount {
+	return ledgerAccount{
+		code:        domain.PocketAccountCode(pocket.ID),
+		accountType: domain.AccountTypeLiability,
+		userID:      &pocket.UserID,
+	}
+}
+
+func merchantAccount(merchantID uuid.UUID) ledgerAccount {
+	return ledgerAccount{
+		code:        domain.MerchantAccountCode(merchantID),
+		accountType: domain.AccountTypeLiability,
+	}
+}
+
+func systemAccount(code string, accountType domain.AccountType) ledgerAccount {
+	return ledgerAccount{code: code, accountType: accountType}
+}
+
+// ledgerLine is one side of a journal entry. amount is always positive;
+// credit decides the sign of the posting.
+type ledgerLine struct {
+	account ledgerAccount
+	amount  money.Money
+	credit  bool
+}
+
+func debitLine(account ledgerAccount, amount money.Money) ledgerLine {
+	return ledgerLine{account: account, amount: amount}
+}
+
+func creditLine(account ledgerAccount, amount money.Money) ledgerLine {
+	return ledgerLine{account: account, amount: amount, credit: true}
+}
+
+// postJournal records a balanced journal entry for a wallet transaction. It
+// must run in the same UnitOfWork as the balance change it describes. The
+// entry is validated before any account is created, so a rejected entry
+// writes nothing.
+func postJournal(repos *domain.Repositories, transactionID uuid.UUID, description string, lines ...ledgerLine) error {
+	now := time.Now()
+	entry := &domain.JournalEntry{
+		TransactionID: transactionID,
+		Description:   description,
+		CreatedAt:     now,
+	}
+
+	for _, line := range lines {
+		if !line.amount.IsPositive() {
+			return fmt.Errorf("%w: %s line of %s is not positive", domain.ErrUnbalancedEntry, line.account.code, line.amount)
+		}
+
+		amount := line.amount
+		if line.credit {
+			amount = amount.Neg()
+		}
+		entry.Postings = append(entry.Postings, domain.Posting{
+			Amount:    amount,
+			CreatedAt: now,
+		})
+	}
+
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	for i, line := range lines {
+		account, err := repos.Ledger.GetOrCreateAccount(&domain.LedgerAccount{
+			Code:      line.account.code,
+			Currency:  line.amount.Currency,
+			Type:      line.account.accountType,
+			UserID:    line.account.userID,
+			CreatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+		entry.Postings[i].AccountID = account.ID
+	}
+
+	return repos.Ledger.CreateEntry(entry)
+}
+
+// LedgerBalance derives a user's balance from the postings on their wallet
+// account. Wallet accounts are liabilities, so the balance is the credit sum.
+func (u *TransactionUsecase) LedgerBalance(userID uuid.UUID, currency string) (money.Money, error) {
+	return ledgerBalance(u.ledgerRepo, userID, currency)
+}
+
+func ledgerBalance(ledgerRepo domain.LedgerRepository, userID uuid.UUID, currency string) (money.Money, error) {
+	account, err := ledgerRepo.GetAccount(domain.UserAccountCode(userID), currency)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return money.Zero(currency), nil
+	}
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	sum, err := ledgerRepo.SumPostings(account.ID)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	return money.New(-sum, currency), nil
+}
+
+// WalletBalance is a wallet together with the balance derived from the
+// ledger.
+type WalletBalance struct {
+	Currency         string      `json:"currency"`
+	Balance          money.Money `json:"balance"`
+	AvailableBalance money.Money `json:"available_balance"`
+	HeldBalance      money.Money `json:"held_balance"`
+	LedgerBalance    money.Money `json:"ledger_balance"`
+	Verified         bool        `json:"verified"`
+}
+
+// GetBalances returns every wallet of a user and checks each stored balance
+// against the ledger.
+func (u *TransactionUsecase) GetBalances(userID uuid.UUID) ([]WalletBalance, error) {
+	wallets, err := u.walletRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]WalletBalance, 0, len(wallets))
+	for _, wallet := range wallets {
+		ledger, err := u.LedgerBalance(userID, wallet.Currency)
+		if err != nil {
+			return nil, err
+		}
+
+		balances = append(balances, WalletBalance{
+			Currency:         wallet.Currency,
+			Balance:          wallet.Balance,
+			AvailableBalance: wallet.AvailableBalance(),
+			HeldBalance:      wallet.HeldBalance,
+			LedgerBalance:    ledger,
+			Verified:         ledger.Cmp(wallet.Balance) == 0,
+		})
+	}
+
+	return balances, nil
+}
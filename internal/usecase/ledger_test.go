@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalEntryValidate(t *testing.T) {
+	posting := func(amount money.Money) domain.Posting {
+		return domain.Posting{AccountID: uuid.New(), Amount: amount}
+	}
+
+	tests := []struct {
+		name     string
+		postings []domain.Posting
+		valid    bool
+	}{
+		{"balanced", []domain.Posting{posting(idr(1000)), posting(idr(-1000))}, true},
+		{"balanced over three lines", []domain.Posting{posting(idr(1000)), posting(idr(-900)), posting(idr(-100))}, true},
+		{"unbalanced", []domain.Posting{posting(idr(1000)), posting(idr(-999))}, false},
+		{"single posting", []domain.Posting{posting(idr(1000))}, false},
+		{"zero posting", []domain.Posting{posting(idr(1000)), posting(idr(-1000)), posting(idr(0))}, false},
+		{"mixed currencies", []domain.Posting{
+			posting(idr(1000)), posting(idr(-1000)),
+			posting(money.New(100, "USD")), posting(money.New(-100, "USD")),
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &domain.JournalEntry{Postings: tt.postings}
+			err := entry.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, domain.ErrUnbalancedEntry)
+			}
+		})
+	}
+}
+
+func TestPostJournal(t *testing.T) {
+	userID := uuid.New()
+	user := userAccount(userID)
+	payable := systemAccount(domain.AccountMerchantPayable, domain.AccountTypeLiability)
+
+	tests := []struct {
+		name  string
+		lines []ledgerLine
+		valid bool
+	}{
+		{"balanced", []ledgerLine{debitLine(user, idr(1000)), creditLine(payable, idr(1000))}, true},
+		{"unbalanced", []ledgerLine{debitLine(user, idr(1000)), creditLine(payable, idr(500))}, false},
+		{"mixed currencies", []ledgerLine{debitLine(user, idr(1000)), creditLine(payable, money.New(1000, "USD"))}, false},
+		{"zero line", []ledgerLine{debitLine(user, idr(0)), creditLine(payable, idr(0))}, false},
+		{"negative line", []ledgerLine{debitLine(user, idr(-1000)), creditLine(payable, idr(-1000))}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemStore()
+			txID := uuid.New()
+
+			// Outside a unit of work nothing is rolled back, so a rejected
+			// entry must not have written anything.
+			err := postJournal(store.repos(), txID, "test", tt.lines...)
+			if !tt.valid {
+				assert.ErrorIs(t, err, domain.ErrUnbalancedEntry)
+				assert.Empty(t, store.entries)
+				assert.Empty(t, store.postings)
+				assert.Empty(t, store.accounts, "no account is created for a rejected entry")
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, store.entries, 1)
+			assert.Equal(t, txID, store.entries[0].TransactionID)
+			require.Len(t, store.postings, 2)
+			assert.Equal(t, idr(1000), store.postings[0].Amount)
+			assert.Equal(t, idr(-1000), store.postings[1].Amount)
+		})
+	}
+}
@@ -0,0 +1,528 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrLimitExceeded = errors.New("transaction limit exceeded")
+	// ErrRecipientBalanceCap fails a transfer whose recipient would end up
+	// holding more than their KYC level allows. It does not say how much the
+	// recipient holds.
+	ErrRecipientBalanceCap = errors.New("recipient cannot hold this amount")
+)
+
+// LimitExceededError tells the client which limit a request hit, how much of
+// it is left and when the period of the limit starts over. ResetsAt is nil
+// for the per-transaction limit.
+type LimitExceededError struct {
+	Limit     domain.LimitKind `json:"limit"`
+	Max       money.Money      `json:"max"`
+	Remaining money.Money      `json:"remaining"`
+	ResetsAt  *time.Time       `json:"resets_at,omitempty"`
+}
+
+func (e *LimitExceededError) Error() string {
+	msg := fmt.Sprintf("%s limit of %s %s exceeded", strings.ToLower(strings.ReplaceAll(string(e.Limit), "_", " ")), e.Max, e.Max.Currency)
+	switch {
+	case e.ResetsAt != nil:
+		msg += fmt.Sprintf(", %s %s left until %s", e.Remaining, e.Remaining.Currency, e.ResetsAt.Format(time.RFC3339))
+	case e.Limit == domain.LimitBalanceCap:
+		msg += fmt.Sprintf(", %s %s left", e.Remaining, e.Remaining.Currency)
+	}
+	return msg
+}
+
+func (e *LimitExceededError) Unwrap() error { return ErrLimitExceeded }
+
+// TierLimits are the limits of a tier in one currency as decimal strings. An
+// empty limit is unlimited.
+type TierLimits struct {
+	PerTransaction  string `mapstructure:"per_transaction" json:"per_transaction,omitempty"`
+	DailyOutgoing   string `mapstructure:"daily_outgoing" json:"daily_outgoing,omitempty"`
+	MonthlyIncoming string `mapstructure:"monthly_incoming" json:"monthly_incoming,omitempty"`
+}
+
+// LimitsConfig holds the limits of every tier by currency. Users without a
+// tier get DefaultTier; currencies a tier does not list are unlimited.
+// BalanceCaps are decimal strings by KYC level and currency; currencies a
+// level does not list are uncapped.
+type LimitsConfig struct {
+	DefaultTier string                           `mapstructure:"default_tier"`
+	Tiers       map[string]map[string]TierLimits `mapstructure:"tiers"`
+	BalanceCaps map[string]map[string]string     `mapstructure:"balance_caps"`
+}
+
+// Limits are the limits that apply to a user in one currency. A nil limit is
+// unlimited.
+type Limits struct {
+	Currency        string       `json:"currency"`
+	Tier            string       `json:"tier"`
+	PerTransaction  *money.Money `json:"per_transaction,omitempty"`
+	DailyOutgoing   *money.Money `json:"daily_outgoing,omitempty"`
+	MonthlyIncoming *money.Money `json:"monthly_incoming,omitempty"`
+	Overridden      bool         `json:"overridden"`
+	// BalanceCap is the most the user may hold given KYCLevel.
+	KYCLevel   domain.KYCLevel `json:"kyc_level"`
+	BalanceCap *money.Money    `json:"balance_cap,omitempty"`
+}
+
+// LimitInput sets a user's limits in a currency as decimal strings; an empty
+// limit keeps the tier limit.
+type LimitInput struct {
+	Currency        string `json:"currency" binding:"required"`
+	PerTransaction  string `json:"per_transaction"`
+	DailyOutgoing   string `json:"daily_outgoing"`
+	MonthlyIncoming string `json:"monthly_incoming"`
+}
+
+type tierLimits struct {
+	perTransaction, dailyOutgoing, monthlyIncoming *money.Money
+}
+
+// LimitsUsecase is the limits engine consulted by every money movement that
+// leaves or enters a wallet from outside, and the admin side of managing
+// tiers and per-user overrides.
+type LimitsUsecase struct {
+	uow         domain.UnitOfWork
+	userRepo    domain.UserRepository
+	limitRepo   domain.LimitRepository
+	defaultTier string
+	tiers       map[string]map[string]tierLimits
+	balanceCaps map[domain.KYCLevel]map[string]money.Money
+}
+
+// NewLimitsUsecase parses the configured tiers. Tier names and currencies are
+// case insensitive.
+func NewLimitsUsecase(uow domain.UnitOfWork, userRepo domain.UserRepository, limitRepo domain.LimitRepository, config LimitsConfig) (*LimitsUsecase, error) {
+	u := &LimitsUsecase{
+		uow:         uow,
+		userRepo:    userRepo,
+		limitRepo:   limitRepo,
+		defaultTier: strings.ToUpper(config.DefaultTier),
+		tiers:       make(map[string]map[string]tierLimits),
+		balanceCaps: make(map[domain.KYCLevel]map[string]money.Money),
+	}
+
+	for tier, currencies := range config.Tiers {
+		tier = strings.ToUpper(tier)
+		u.tiers[tier] = make(map[string]tierLimits)
+		for currency, limits := range currencies {
+			currency = strings.ToUpper(currency)
+			parsed, err := parseTierLimits(currency, limits)
+			if err != nil {
+				return nil, fmt.Errorf("limits of tier %s in %s: %w", tier, currency, err)
+			}
+			u.tiers[tier][currency] = parsed
+		}
+	}
+
+	if _, ok := u.tiers[u.defaultTier]; len(u.tiers) > 0 && !ok {
+		return nil, fmt.Errorf("default tier %q is not configured", config.DefaultTier)
+	}
+
+	for level, currencies := range config.BalanceCaps {
+		kycLevel := domain.KYCLevel(strings.ToUpper(level))
+		if !validKYCLevel(kycLevel) {
+			return nil, fmt.Errorf("balance caps of unknown KYC level %q", level)
+		}
+		u.balanceCaps[kycLevel] = make(map[string]money.Money)
+		for currency, amount := range currencies {
+			currency = strings.ToUpper(currency)
+			limit, err := parseLimit(amount, currency)
+			if err != nil {
+				return nil, fmt.Errorf("balance cap of KYC level %s in %s: %w", kycLevel, currency, err)
+			}
+			if limit != nil {
+				u.balanceCaps[kycLevel][currency] = *limit
+			}
+		}
+	}
+
+	return u, nil
+}
+
+// parseLimit parses a limit given as a decimal string; an empty limit is nil.
+func parseLimit(amount, currency string) (*money.Money, error) {
+	if amount == "" {
+		return nil, nil
+	}
+	m, err := money.Parse(amount, currency)
+	if err != nil {
+		return nil, err
+	}
+	if !m.IsPositive() {
+		return nil, errors.New("limits must be greater than zero")
+	}
+	return &m, nil
+}
+
+func parseTierLimits(currency string, in TierLimits) (tierLimits, error) {
+	var out tierLimits
+	var err error
+	if out.perTransaction, err = parseLimit(in.PerTransaction, currency); err != nil {
+		return out, err
+	}
+	if out.dailyOutgoing, err = parseLimit(in.DailyOutgoing, currency); err != nil {
+		return out, err
+	}
+	if out.monthlyIncoming, err = parseLimit(in.MonthlyIncoming, currency); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+// GetLimits returns the limits of a user in every currency that has a tier
+// limit or an override.
+func (u *LimitsUsecase) GetLimits(userID uuid.UUID) ([]Limits, error) {
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides, err := u.limitRepo.GetOverrides(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byCurrency := make(map[string]*domain.LimitOverride)
+	for i := range overrides {
+		byCurrency[overrides[i].Currency] = &overrides[i]
+	}
+
+	tier, level := u.tierOf(user), kycLevelOf(user)
+	seen := make(map[string]bool)
+	var currencies []string
+	add := func(currency string) {
+		if !seen[currency] {
+			seen[currency] = true
+			currencies = append(currencies, currency)
+		}
+	}
+	for currency := range u.tiers[tier] {
+		add(currency)
+	}
+	for currency := range u.balanceCaps[level] {
+		add(currency)
+	}
+	for currency := range byCurrency {
+		add(currency)
+	}
+
+	limits := make([]Limits, 0, len(currencies))
+	for _, currency := range currencies {
+		limits = append(limits, u.effectiveLimits(tier, level, currency, byCurrency[currency]))
+	}
+	return limits, nil
+}
+
+// SetTier moves a user to another configured tier.
+func (u *LimitsUsecase) SetTier(userID uuid.UUID, tier string) (*domain.User, error) {
+	tier = strings.ToUpper(tier)
+	if _, ok := u.tiers[tier]; !ok {
+		return nil, fmt.Errorf("unknown tier %q", tier)
+	}
+
+	var user *domain.User
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		var err error
+		user, err = repos.Users.GetByIDForUpdate(userID)
+		if err != nil {
+			return err
+		}
+
+		user.Tier = tier
+		user.UpdatedAt = time.Now()
+		return repos.Users.Update(user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SetKYCLevel records the outcome of a user's verification.
+func (u *LimitsUsecase) SetKYCLevel(userID uuid.UUID, level domain.KYCLevel) (*domain.User, error) {
+	level = domain.KYCLevel(strings.ToUpper(string(level)))
+	if !validKYCLevel(level) {
+		return nil, fmt.Errorf("unknown KYC level %q", level)
+	}
+
+	var user *domain.User
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		var err error
+		user, err = repos.Users.GetByIDForUpdate(userID)
+		if err != nil {
+			return err
+		}
+
+		user.KYCLevel = level
+		user.UpdatedAt = time.Now()
+		return repos.Users.Update(user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SetOverride replaces a user's limit override in a currency.
+func (u *LimitsUsecase) SetOverride(adminID, userID uuid.UUID, in LimitInput) (*domain.LimitOverride, error) {
+	currency := strings.ToUpper(in.Currency)
+	if err := money.ValidateCurrency(currency); err != nil {
+		return nil, err
+	}
+
+	parsed, err := parseTierLimits(currency, TierLimits{
+		PerTransaction:  in.PerTransaction,
+		DailyOutgoing:   in.DailyOutgoing,
+		MonthlyIncoming: in.MonthlyIncoming,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	minor := func(m *money.Money) *int64 {
+		if m == nil {
+			return nil
+		}
+		return &m.Minor
+	}
+
+	now := time.Now()
+	override := &domain.LimitOverride{
+		UserID:               userID,
+		Currency:             currency,
+		PerTransactionMinor:  minor(parsed.perTransaction),
+		DailyOutgoingMinor:   minor(parsed.dailyOutgoing),
+		MonthlyIncomingMinor: minor(parsed.monthlyIncoming),
+		UpdatedBy:            adminID,
+		CreatedAt:            now,
+		UpdatedAt:            now,
+	}
+
+	if _, err := u.userRepo.GetByID(userID); err != nil {
+		return nil, err
+	}
+	if err := u.limitRepo.UpsertOverride(override); err != nil {
+		return nil, err
+	}
+
+	return override, nil
+}
+
+func (u *LimitsUsecase) DeleteOverride(userID uuid.UUID, currency string) error {
+	return u.limitRepo.DeleteOverride(userID, strings.ToUpper(currency))
+}
+
+// CheckOutgoing checks a payment or transfer of amount by the user against
+// the per-transaction and daily outgoing limits.
+func (u *LimitsUsecase) CheckOutgoing(repos *domain.Repositories, userID uuid.UUID, amount money.Money) error {
+	limits, err := u.lockLimits(repos, userID, amount.Currency)
+	if err != nil {
+		return err
+	}
+
+	if err := checkPerTransaction(limits, amount); err != nil {
+		return err
+	}
+
+	if limits.DailyOutgoing == nil {
+		return nil
+	}
+	start, reset := dayBounds(time.Now())
+	used, err := repos.Transactions.SumOutgoing(userID, amount.Currency, start)
+	if err != nil {
+		return err
+	}
+	return checkPeriod(domain.LimitDailyOutgoing, *limits.DailyOutgoing, used, amount, reset)
+}
+
+// CheckIncoming checks a top-up or incoming transfer of amount against the
+// monthly incoming limit of the user, and top-ups against the
+// per-transaction limit.
+func (u *LimitsUsecase) CheckIncoming(repos *domain.Repositories, userID uuid.UUID, amount money.Money, perTransaction bool) error {
+	limits, err := u.lockLimits(repos, userID, amount.Currency)
+	if err != nil {
+		return err
+	}
+
+	if perTransaction {
+		if err := checkPerTransaction(limits, amount); err != nil {
+			return err
+		}
+	}
+
+	if limits.MonthlyIncoming == nil {
+		return nil
+	}
+	start, reset := monthBounds(time.Now())
+	used, err := repos.Transactions.SumIncoming(userID, amount.Currency, start)
+	if err != nil {
+		return err
+	}
+	return checkPeriod(domain.LimitMonthlyIncoming, *limits.MonthlyIncoming, used, amount, reset)
+}
+
+// CheckBalanceCap checks that the user may receive amount without holding
+// more than their KYC level allows, counting the wallet and the pockets in
+// the currency of amount. Money already held above the cap is not taken
+// away; the user just cannot receive more.
+func (u *LimitsUsecase) CheckBalanceCap(repos *domain.Repositories, userID uuid.UUID, amount money.Money) error {
+	user, err := repos.Users.GetByIDForUpdate(userID)
+	if err != nil {
+		return err
+	}
+
+	limit, ok := u.balanceCaps[kycLevelOf(user)][amount.Currency]
+	if !ok {
+		return nil
+	}
+
+	held := money.Zero(amount.Currency)
+	wallet, err := repos.Wallets.GetForUpdate(userID, amount.Currency)
+	if err == nil {
+		held = wallet.Balance
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	pockets, err := repos.Pockets.GetByUserID(userID)
+	if err != nil {
+		return err
+	}
+	for _, pocket := range pockets {
+		if pocket.Balance.Currency == amount.Currency {
+			held = held.Add(pocket.Balance)
+		}
+	}
+
+	remaining := limit.Sub(held)
+	if !remaining.LessThan(amount) {
+		return nil
+	}
+	if remaining.IsNegative() {
+		remaining = money.Zero(amount.Currency)
+	}
+	return &LimitExceededError{
+		Limit:     domain.LimitBalanceCap,
+		Max:       limit,
+		Remaining: remaining,
+	}
+}
+
+// lockLimits locks the user row, so that concurrent requests of the same user
+// are counted one after the other, and returns the user's limits.
+func (u *LimitsUsecase) lockLimits(repos *domain.Repositories, userID uuid.UUID, currency string) (Limits, error) {
+	user, err := repos.Users.GetByIDForUpdate(userID)
+	if err != nil {
+		return Limits{}, err
+	}
+
+	override, err := repos.Limits.GetOverride(userID, currency)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		override = nil
+	} else if err != nil {
+		return Limits{}, err
+	}
+
+	return u.effectiveLimits(u.tierOf(user), kycLevelOf(user), currency, override), nil
+}
+
+func (u *LimitsUsecase) tierOf(user *domain.User) string {
+	if user.Tier != "" {
+		return user.Tier
+	}
+	return u.defaultTier
+}
+
+func kycLevelOf(user *domain.User) domain.KYCLevel {
+	if user.KYCLevel != "" {
+		return user.KYCLevel
+	}
+	return domain.KYCLevelUnverified
+}
+
+func validKYCLevel(level domain.KYCLevel) bool {
+	return level == domain.KYCLevelUnverified || level == domain.KYCLevelVerified
+}
+
+func (u *LimitsUsecase) effectiveLimits(tier string, level domain.KYCLevel, currency string, override *domain.LimitOverride) Limits {
+	base := u.tiers[tier][currency]
+	limits := Limits{
+		Currency:        currency,
+		Tier:            tier,
+		PerTransaction:  base.perTransaction,
+		DailyOutgoing:   base.dailyOutgoing,
+		MonthlyIncoming: base.monthlyIncoming,
+		KYCLevel:        level,
+	}
+	if limit, ok := u.balanceCaps[level][currency]; ok {
+		limits.BalanceCap = &limit
+	}
+	if override == nil {
+		return limits
+	}
+
+	apply := func(limit **money.Money, minor *int64) {
+		if minor != nil {
+			m := money.New(*minor, currency)
+			*limit = &m
+			limits.Overridden = true
+		}
+	}
+	apply(&limits.PerTransaction, override.PerTransactionMinor)
+	apply(&limits.DailyOutgoing, override.DailyOutgoingMinor)
+	apply(&limits.MonthlyIncoming, override.MonthlyIncomingMinor)
+	return limits
+}
+
+func checkPerTransaction(limits Limits, amount money.Money) error {
+	if limits.PerTransaction == nil || !limits.PerTransaction.LessThan(amount) {
+		return nil
+	}
+	return &LimitExceededError{
+		Limit:     domain.LimitPerTransaction,
+		Max:       *limits.PerTransaction,
+		Remaining: *limits.PerTransaction,
+	}
+}
+
+func checkPeriod(kind domain.LimitKind, max money.Money, usedMinor int64, amount money.Money, reset time.Time) error {
+	remaining := max.Sub(money.New(usedMinor, max.Currency))
+	if !remaining.LessThan(amount) {
+		return nil
+	}
+	if remaining.IsNegative() {
+		remaining = money.Zero(max.Currency)
+	}
+	return &LimitExceededError{
+		Limit:     kind,
+		Max:       max,
+		Remaining: remaining,
+		ResetsAt:  &reset,
+	}
+}
+
+// dayBounds returns the start of the day of t and the start of the next day.
+func dayBounds(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+// monthBounds returns the start of the month of t and the start of the next
+// month.
+func monthBounds(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 1, 0)
+}
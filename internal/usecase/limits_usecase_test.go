@@ -0,0 +1,158 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLimitsConfig = LimitsConfig{
+	DefaultTier: "basic",
+	Tiers: map[string]map[string]TierLimits{
+		"basic": {
+			"idr": {PerTransaction: "50", DailyOutgoing: "80", MonthlyIncoming: "150"},
+		},
+		"premium": {
+			"idr": {PerTransaction: "500"},
+		},
+	},
+}
+
+// newTestLimitsUsecase builds a limits engine; without tiers nothing is
+// limited.
+func newTestLimitsUsecase(t *testing.T, store *memStore, config LimitsConfig) *LimitsUsecase {
+	t.Helper()
+
+	repos := store.repos()
+	limits, err := NewLimitsUsecase(store, repos.Users, repos.Limits, config)
+	require.NoError(t, err)
+	return limits
+}
+
+func TestLimitsUsecase(t *testing.T) {
+	t.Run("rejects amounts over the per-transaction limit", func(t *testing.T) {
+		store := newMemStore()
+		userID := store.addUser(idr(100000))
+		uc := newTransactionUsecaseWith(store, newTestLimitsUsecase(t, store, testLimitsConfig), noFees(store))
+
+		_, err := uc.Payment(userID, idr(5001), "tv", PaymentOptions{})
+
+		var limitErr *LimitExceededError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, domain.LimitPerTransaction, limitErr.Limit)
+		assert.Equal(t, idr(5000), limitErr.Max)
+		assert.Nil(t, limitErr.ResetsAt)
+		assert.Empty(t, store.transactions)
+	})
+
+	t.Run("counts payments and transfers towards the daily outgoing limit", func(t *testing.T) {
+		store := newMemStore()
+		userID := store.addUser(idr(100000))
+		toID := store.addUser(idr(0))
+		uc := newTransactionUsecaseWith(store, newTestLimitsUsecase(t, store, testLimitsConfig), noFees(store))
+
+		_, err := uc.Payment(userID, idr(4000), "groceries", PaymentOptions{})
+		require.NoError(t, err)
+		_, err = uc.Transfer(userID, toID, idr(3000), "rent", TransferOptions{})
+		require.NoError(t, err)
+
+		_, err = uc.Payment(userID, idr(1500), "dinner", PaymentOptions{})
+
+		var limitErr *LimitExceededError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, domain.LimitDailyOutgoing, limitErr.Limit)
+		assert.Equal(t, idr(1000), limitErr.Remaining)
+		_, tomorrow := dayBounds(time.Now())
+		assert.Equal(t, tomorrow, *limitErr.ResetsAt)
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+	})
+
+	t.Run("checks the recipient's monthly incoming limit", func(t *testing.T) {
+		store := newMemStore()
+		fromID := store.addUser(idr(100000))
+		toID := store.addUser(idr(0))
+		uc := newTransactionUsecaseWith(store, newTestLimitsUsecase(t, store, testLimitsConfig), noFees(store))
+
+		_, err := uc.TopUp(toID, idr(5000), TopUpOptions{})
+		require.NoError(t, err)
+		_, err = uc.TopUp(toID, idr(5000), TopUpOptions{})
+		require.NoError(t, err)
+		_, err = uc.TopUp(toID, idr(4000), TopUpOptions{})
+		require.NoError(t, err)
+
+		_, err = uc.Transfer(fromID, toID, idr(1001), "rent", TransferOptions{})
+
+		var limitErr *LimitExceededError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, domain.LimitMonthlyIncoming, limitErr.Limit)
+		assert.Equal(t, idr(1000), limitErr.Remaining)
+		assert.Equal(t, idr(0), store.wallet(fromID).HeldBalance)
+	})
+
+	t.Run("applies the user's tier and admin overrides", func(t *testing.T) {
+		store := newMemStore()
+		userID := store.addUser(idr(100000))
+		adminID := store.addUser(idr(0))
+		limits := newTestLimitsUsecase(t, store, testLimitsConfig)
+		uc := newTransactionUsecaseWith(store, limits, noFees(store))
+
+		_, err := limits.SetTier(userID, "premium")
+		require.NoError(t, err)
+		_, err = uc.Payment(userID, idr(40000), "laptop", PaymentOptions{})
+		require.NoError(t, err)
+
+		_, err = limits.SetOverride(adminID, userID, LimitInput{Currency: "IDR", PerTransaction: "100"})
+		require.NoError(t, err)
+		_, err = uc.Payment(userID, idr(20000), "phone", PaymentOptions{})
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+
+		effective, err := limits.GetLimits(userID)
+		require.NoError(t, err)
+		require.Len(t, effective, 1)
+		assert.Equal(t, "PREMIUM", effective[0].Tier)
+		assert.True(t, effective[0].Overridden)
+		assert.Equal(t, idr(10000), *effective[0].PerTransaction)
+		assert.Nil(t, effective[0].DailyOutgoing)
+
+		require.NoError(t, limits.DeleteOverride(userID, "idr"))
+		_, err = uc.Payment(userID, idr(20000), "phone", PaymentOptions{})
+		assert.NoError(t, err)
+	})
+	t.Run("caps balances by KYC level and bounces transfers over the cap", func(t *testing.T) {
+		store := newMemStore()
+		fromID := store.addUser(idr(100000))
+		toID := store.addUser(idr(10000))
+		limits := newTestLimitsUsecase(t, store, LimitsConfig{
+			BalanceCaps: map[string]map[string]string{
+				"unverified": {"idr": "150"},
+				"verified":   {"idr": "1000"},
+			},
+		})
+		uc := newTransactionUsecaseWith(store, limits, noFees(store))
+
+		_, err := uc.TopUp(toID, idr(6000), TopUpOptions{})
+
+		var limitErr *LimitExceededError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, domain.LimitBalanceCap, limitErr.Limit)
+		assert.Equal(t, idr(5000), limitErr.Remaining)
+
+		tx, err := uc.Transfer(fromID, toID, idr(6000), "rent", TransferOptions{})
+		require.NoError(t, err)
+		require.NoError(t, uc.ProcessTransfer(tx.ID))
+
+		assert.Equal(t, domain.TransactionStatusFailed, store.transactions[tx.ID].Status)
+		assert.Equal(t, ErrRecipientBalanceCap.Error(), store.transactions[tx.ID].FailureReason)
+		assert.Equal(t, idr(100000), store.wallet(fromID).Balance)
+		assert.Equal(t, idr(0), store.wallet(fromID).HeldBalance)
+		assert.Equal(t, idr(10000), store.wallet(toID).Balance)
+
+		_, err = limits.SetKYCLevel(toID, domain.KYCLevelVerified)
+		require.NoError(t, err)
+		_, err = uc.TopUp(toID, idr(6000), TopUpOptions{})
+		assert.NoError(t, err)
+	})
+}
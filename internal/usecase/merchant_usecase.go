@@ -0,0 +1,178 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMerchantNotFound  = errors.New("merchant not found")
+	ErrMerchantSuspended = errors.New("merchant is suspended")
+)
+
+// MerchantInput describes a new merchant. Currency is the currency the
+// merchant is paid and settled in.
+type MerchantInput struct {
+	OwnerID                 uuid.UUID
+	Name                    string
+	Currency                string
+	SettlementBankCode      string
+	SettlementAccountNumber string
+	SettlementAccountName   string
+}
+
+type MerchantUsecase struct {
+	uow             domain.UnitOfWork
+	merchantRepo    domain.MerchantRepository
+	transactionRepo domain.TransactionRepository
+}
+
+func NewMerchantUsecase(uow domain.UnitOfWork, merchantRepo domain.MerchantRepository, transactionRepo domain.TransactionRepository) *MerchantUsecase {
+	return &MerchantUsecase{
+		uow:             uow,
+		merchantRepo:    merchantRepo,
+		transactionRepo: transactionRepo,
+	}
+}
+
+// Create onboards a merchant owned by an existing user.
+func (u *MerchantUsecase) Create(in MerchantInput) (*domain.Merchant, error) {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return nil, errors.New("merchant name is required")
+	}
+	if err := money.ValidateCurrency(in.Currency); err != nil {
+		return nil, err
+	}
+	if in.SettlementBankCode == "" || in.SettlementAccountNumber == "" || in.SettlementAccountName == "" {
+		return nil, errors.New("settlement bank code, account number and account name are required")
+	}
+
+	now := time.Now()
+	merchant := &domain.Merchant{
+		ID:                      uuid.New(),
+		OwnerID:                 in.OwnerID,
+		Name:                    name,
+		Status:                  domain.MerchantStatusActive,
+		Balance:                 money.Zero(in.Currency),
+		PayoutInTransit:         money.Zero(in.Currency),
+		SettlementBankCode:      in.SettlementBankCode,
+		SettlementAccountNumber: in.SettlementAccountNumber,
+		SettlementAccountName:   in.SettlementAccountName,
+		CreatedAt:               now,
+		UpdatedAt:               now,
+	}
+
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		if _, err := repos.Users.GetByID(in.OwnerID); err != nil {
+			return err
+		}
+		return repos.Merchants.Create(merchant)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return merchant, nil
+}
+
+func (u *MerchantUsecase) GetMerchants(ownerID uuid.UUID) ([]domain.Merchant, error) {
+	return u.merchantRepo.GetByOwnerID(ownerID)
+}
+
+// GetMerchant returns one of the user's merchants. Merchants of other users
+// are reported as not found.
+func (u *MerchantUsecase) GetMerchant(ownerID, merchantID uuid.UUID) (*domain.Merchant, error) {
+	return ownedMerchant(u.merchantRepo, ownerID, merchantID)
+}
+
+// GetPayments lists the payments one of the user's merchants has received and
+// the refunds of them.
+func (u *MerchantUsecase) GetPayments(ownerID, merchantID uuid.UUID) ([]domain.Transaction, error) {
+	if _, err := u.GetMerchant(ownerID, merchantID); err != nil {
+		return nil, err
+	}
+	return u.transactionRepo.GetByMerchantID(merchantID)
+}
+
+func (u *MerchantUsecase) UpdateStatus(merchantID uuid.UUID, status domain.MerchantStatus) error {
+	if status != domain.MerchantStatusActive && status != domain.MerchantStatusSuspended {
+		return errors.New("invalid merchant status")
+	}
+
+	if _, err := u.merchantRepo.GetByID(merchantID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMerchantNotFound
+		}
+		return err
+	}
+
+	return u.merchantRepo.UpdateStatus(merchantID, status)
+}
+
+// creditMerchant adds a payment to the balance of an active merchant. Like
+// the wallet helpers it must run inside UnitOfWork.Do, after the payer's
+// rows are locked.
+func creditMerchant(repos *domain.Repositories, merchantID uuid.UUID, amount money.Money) error {
+	merchant, err := lockMerchant(repos, merchantID)
+	if err != nil {
+		return err
+	}
+
+	if merchant.Status != domain.MerchantStatusActive {
+		return ErrMerchantSuspended
+	}
+	if !merchant.Balance.SameCurrency(amount) {
+		return money.ErrCurrencyMismatch
+	}
+
+	return repos.Merchants.UpdateBalance(merchant.ID, merchant.Balance.Add(amount))
+}
+
+// debitMerchant takes a refund back out of a merchant's balance. Suspended
+// merchants can still be debited.
+func debitMerchant(repos *domain.Repositories, merchantID uuid.UUID, amount money.Money) error {
+	merchant, err := lockMerchant(repos, merchantID)
+	if err != nil {
+		return err
+	}
+
+	if !merchant.Balance.SameCurrency(amount) {
+		return money.ErrCurrencyMismatch
+	}
+	if merchant.Balance.LessThan(amount) {
+		return ErrInsufficientBalance
+	}
+
+	return repos.Merchants.UpdateBalance(merchant.ID, merchant.Balance.Sub(amount))
+}
+
+func ownedMerchant(merchantRepo domain.MerchantRepository, ownerID, merchantID uuid.UUID) (*domain.Merchant, error) {
+	merchant, err := merchantRepo.GetByID(merchantID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMerchantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if merchant.OwnerID != ownerID {
+		return nil, ErrMerchantNotFound
+	}
+
+	return merchant, nil
+}
+
+func lockMerchant(repos *domain.Repositories, merchantID uuid.UUID) (*domain.Merchant, error) {
+	merchant, err := repos.Merchants.GetByIDForUpdate(merchantID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMerchantNotFound
+	}
+	return merchant, err
+}
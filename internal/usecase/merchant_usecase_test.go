@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMerchant onboards an IDR merchant owned by a new user.
+func newTestMerchant(t *testing.T, store *memStore) (*MerchantUsecase, *domain.Merchant) {
+	t.Helper()
+
+	ownerID := store.addUser(idr(0))
+	uc := NewMerchantUsecase(store, store.repos().Merchants, store.repos().Transactions)
+	merchant, err := uc.Create(MerchantInput{
+		OwnerID:                 ownerID,
+		Name:                    "Kopi Kenangan",
+		Currency:                "IDR",
+		SettlementBankCode:      "BCA",
+		SettlementAccountNumber: "1234567890",
+		SettlementAccountName:   "PT Kopi",
+	})
+	require.NoError(t, err)
+	return uc, merchant
+}
+
+func TestMerchantUsecase(t *testing.T) {
+	t.Run("credits the merchant with the payment", func(t *testing.T) {
+		store := newMemStore()
+		merchants, merchant := newTestMerchant(t, store)
+		userID := store.addUser(idr(10000))
+
+		tx, err := newTestTransactionUsecase(store).Payment(userID, idr(2500), "coffee", PaymentOptions{
+			MerchantID:     merchant.ID,
+			OrderReference: "ORDER-1",
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, idr(2500), store.merchants[merchant.ID].Balance)
+		assert.Equal(t, idr(7500), store.wallet(userID).Balance)
+
+		payments, err := merchants.GetPayments(merchant.OwnerID, merchant.ID)
+		require.NoError(t, err)
+		require.Len(t, payments, 1)
+		assert.Equal(t, tx.ID, payments[0].ID)
+		assert.Equal(t, "ORDER-1", payments[0].OrderReference)
+
+		_, err = merchants.GetPayments(userID, merchant.ID)
+		assert.ErrorIs(t, err, ErrMerchantNotFound)
+	})
+
+	t.Run("lets the owner refund a payment from the merchant's balance", func(t *testing.T) {
+		store := newMemStore()
+		_, merchant := newTestMerchant(t, store)
+		userID := store.addUser(idr(10000))
+
+		tx, err := newTestTransactionUsecase(store).Payment(userID, idr(2500), "coffee", PaymentOptions{MerchantID: merchant.ID})
+		require.NoError(t, err)
+
+		_, err = NewRefundUsecase(store).Refund(merchant.OwnerID, tx.ID, idr(1000), "cold")
+		require.NoError(t, err)
+
+		assert.Equal(t, idr(1500), store.merchants[merchant.ID].Balance)
+		assert.Equal(t, idr(8500), store.wallet(userID).Balance)
+	})
+
+	t.Run("rejects payments to a suspended merchant", func(t *testing.T) {
+		store := newMemStore()
+		merchants, merchant := newTestMerchant(t, store)
+		userID := store.addUser(idr(10000))
+		require.NoError(t, merchants.UpdateStatus(merchant.ID, domain.MerchantStatusSuspended))
+
+		_, err := newTestTransactionUsecase(store).Payment(userID, idr(2500), "coffee", PaymentOptions{MerchantID: merchant.ID})
+
+		assert.ErrorIs(t, err, ErrMerchantSuspended)
+		assert.Equal(t, idr(10000), store.wallet(userID).Balance)
+		assert.Empty(t, store.transactions)
+	})
+}
@@ -0,0 +1,325 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMoneyRequestNotFound = errors.New("money request not found")
+	ErrMoneyRequestClosed   = errors.New("money request is no longer pending")
+	ErrMoneyRequestExpired  = errors.New("money request has expired")
+	ErrPayerNotFound        = errors.New("user to request money from not found")
+	ErrSelfRequest          = errors.New("cannot request money from yourself")
+)
+
+type MoneyRequestConfig struct {
+	// DefaultTTL is used when a request does not ask for one; no request may
+	// stay pending longer than MaxTTL.
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+	BatchSize  int
+}
+
+type MoneyRequestUsecase struct {
+	uow         domain.UnitOfWork
+	requestRepo domain.MoneyRequestRepository
+	limits      *LimitsUsecase
+	fees        *FeeUsecase
+	config      MoneyRequestConfig
+}
+
+func NewMoneyRequestUsecase(uow domain.UnitOfWork, requestRepo domain.MoneyRequestRepository, limits *LimitsUsecase, fees *FeeUsecase, config MoneyRequestConfig) *MoneyRequestUsecase {
+	return &MoneyRequestUsecase{
+		uow:         uow,
+		requestRepo: requestRepo,
+		limits:      limits,
+		fees:        fees,
+		config:      config,
+	}
+}
+
+// Create asks payerID to send amount to requesterID and tells the payer
+// about it. A zero ttl means the configured default.
+func (u *MoneyRequestUsecase) Create(requesterID, payerID uuid.UUID, amount money.Money, note string, ttl time.Duration) (*domain.MoneyRequest, error) {
+	if requesterID == payerID {
+		return nil, ErrSelfRequest
+	}
+	if !amount.IsPositive() {
+		return nil, errors.New("amount must be positive")
+	}
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return nil, errors.New("note is required")
+	}
+
+	if ttl <= 0 {
+		ttl = u.config.DefaultTTL
+	}
+	if ttl > u.config.MaxTTL {
+		ttl = u.config.MaxTTL
+	}
+
+	now := time.Now()
+	request := &domain.MoneyRequest{
+		ID:          uuid.New(),
+		RequesterID: requesterID,
+		PayerID:     payerID,
+		Amount:      amount,
+		Note:        note,
+		Status:      domain.MoneyRequestStatusPending,
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		return createMoneyRequest(repos, request)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// createMoneyRequest stores a new pending request with its first history
+// entry and notifies the payer.
+func createMoneyRequest(repos *domain.Repositories, request *domain.MoneyRequest) error {
+	if _, err := repos.Users.GetByID(request.PayerID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPayerNotFound
+		}
+		return err
+	}
+
+	if err := repos.Requests.Create(request); err != nil {
+		return err
+	}
+	if err := recordMoneyRequestEvent(repos, request, &request.RequesterID, ""); err != nil {
+		return err
+	}
+
+	return notify(repos, request.PayerID, "Money requested",
+		fmt.Sprintf("You were asked to pay %s %s: %s", request.Amount, request.Amount.Currency, request.Note))
+}
+
+// Get returns a request with its status history to its requester or payer.
+func (u *MoneyRequestUsecase) Get(userID, requestID uuid.UUID) (*domain.MoneyRequest, error) {
+	request, err := u.requestRepo.GetByID(requestID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMoneyRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if request.RequesterID != userID && request.PayerID != userID {
+		return nil, ErrMoneyRequestNotFound
+	}
+
+	request.History, err = u.requestRepo.GetEvents(requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func (u *MoneyRequestUsecase) GetIncoming(userID uuid.UUID) ([]domain.MoneyRequest, error) {
+	return u.requestRepo.GetIncoming(userID)
+}
+
+func (u *MoneyRequestUsecase) GetOutgoing(userID uuid.UUID) ([]domain.MoneyRequest, error) {
+	return u.requestRepo.GetOutgoing(userID)
+}
+
+// Accept sends the requested amount with a regular transfer from the payer
+// to the requester. The request only becomes ACCEPTED when the transfer is
+// created, so a transfer that is rejected up front leaves it pending, and one
+// that fails or is cancelled later reopens it. opts may pick a pocket and a
+// promo code.
+func (u *MoneyRequestUsecase) Accept(payerID, requestID uuid.UUID, opts TransferOptions) (*domain.MoneyRequest, *domain.Transaction, error) {
+	var request *domain.MoneyRequest
+	var tx *domain.Transaction
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		var err error
+		request, err = lockPendingMoneyRequest(repos, requestID, func(r *domain.MoneyRequest) bool { return r.PayerID == payerID })
+		if err != nil {
+			return err
+		}
+
+		tx, err = createTransfer(repos, u.limits, u.fees, payerID, request.RequesterID, request.Amount, request.Note, TransferOptions{
+			PocketID:  opts.PocketID,
+			PromoCode: opts.PromoCode,
+		}, false)
+		if err != nil {
+			return err
+		}
+
+		request.TransferID = &tx.ID
+		if err := setMoneyRequestStatus(repos, request, domain.MoneyRequestStatusAccepted, &payerID, ""); err != nil {
+			return err
+		}
+
+		return notify(repos, request.RequesterID, "Money request accepted",
+			fmt.Sprintf("Your request for %s %s is being paid: %s", request.Amount, request.Amount.Currency, request.Note))
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return request, tx, nil
+}
+
+// Decline turns a request down on behalf of its payer.
+func (u *MoneyRequestUsecase) Decline(payerID, requestID uuid.UUID, reason string) (*domain.MoneyRequest, error) {
+	var request *domain.MoneyRequest
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		var err error
+		request, err = lockPendingMoneyRequest(repos, requestID, func(r *domain.MoneyRequest) bool { return r.PayerID == payerID })
+		if err != nil {
+			return err
+		}
+
+		if err := setMoneyRequestStatus(repos, request, domain.MoneyRequestStatusDeclined, &payerID, reason); err != nil {
+			return err
+		}
+
+		return notify(repos, request.RequesterID, "Money request declined",
+			fmt.Sprintf("Your request for %s %s was declined: %s", request.Amount, request.Amount.Currency, request.Note))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// Cancel withdraws a request on behalf of its requester.
+func (u *MoneyRequestUsecase) Cancel(requesterID, requestID uuid.UUID) (*domain.MoneyRequest, error) {
+	var request *domain.MoneyRequest
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		var err error
+		request, err = lockPendingMoneyRequest(repos, requestID, func(r *domain.MoneyRequest) bool { return r.RequesterID == requesterID })
+		if err != nil {
+			return err
+		}
+
+		return setMoneyRequestStatus(repos, request, domain.MoneyRequestStatusCancelled, &requesterID, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// ExpireRequests closes pending requests past their expiry and returns how
+// many it closed.
+func (u *MoneyRequestUsecase) ExpireRequests() (int, error) {
+	now := time.Now()
+	requests, err := u.requestRepo.GetExpired(now, u.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, request := range requests {
+		err := u.uow.Do(func(repos *domain.Repositories) error {
+			locked, err := repos.Requests.GetByIDForUpdate(request.ID)
+			if err != nil {
+				return err
+			}
+			if locked.Status != domain.MoneyRequestStatusPending || !now.After(locked.ExpiresAt) {
+				return nil
+			}
+
+			if err := setMoneyRequestStatus(repos, locked, domain.MoneyRequestStatusExpired, nil, ""); err != nil {
+				return err
+			}
+			expired++
+			return nil
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+
+	return expired, nil
+}
+
+// reopenMoneyRequest puts the request a transfer was paying back to PENDING
+// when the transfer fails or is cancelled, so that it can be paid again. It
+// runs in the transfer's unit of work, before any user row is locked.
+func reopenMoneyRequest(repos *domain.Repositories, transfer *domain.Transaction, actorID *uuid.UUID, reason string) error {
+	request, err := repos.Requests.GetByTransferIDForUpdate(transfer.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if request.Status != domain.MoneyRequestStatusAccepted {
+		return nil
+	}
+
+	request.TransferID = nil
+	if err := setMoneyRequestStatus(repos, request, domain.MoneyRequestStatusPending, actorID, reason); err != nil {
+		return err
+	}
+
+	return notify(repos, request.RequesterID, "Money request reopened",
+		fmt.Sprintf("The payment of your request for %s %s did not go through: %s", request.Amount, request.Amount.Currency, request.Note))
+}
+
+// lockPendingMoneyRequest locks a request that allowed reports the user may
+// act on. Requests of other users are reported as not found.
+func lockPendingMoneyRequest(repos *domain.Repositories, requestID uuid.UUID, allowed func(*domain.MoneyRequest) bool) (*domain.MoneyRequest, error) {
+	request, err := repos.Requests.GetByIDForUpdate(requestID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMoneyRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !allowed(request) {
+		return nil, ErrMoneyRequestNotFound
+	}
+	if request.Status != domain.MoneyRequestStatusPending {
+		return nil, ErrMoneyRequestClosed
+	}
+	if !time.Now().Before(request.ExpiresAt) {
+		return nil, ErrMoneyRequestExpired
+	}
+
+	return request, nil
+}
+
+func setMoneyRequestStatus(repos *domain.Repositories, request *domain.MoneyRequest, status domain.MoneyRequestStatus, actorID *uuid.UUID, reason string) error {
+	request.Status = status
+	request.UpdatedAt = time.Now()
+	if err := repos.Requests.Update(request); err != nil {
+		return err
+	}
+	return recordMoneyRequestEvent(repos, request, actorID, reason)
+}
+
+func recordMoneyRequestEvent(repos *domain.Repositories, request *domain.MoneyRequest, actorID *uuid.UUID, reason string) error {
+	return repos.Requests.CreateEvent(&domain.MoneyRequestEvent{
+		ID:        uuid.New(),
+		RequestID: request.ID,
+		Status:    request.Status,
+		ActorID:   actorID,
+		Reason:    reason,
+		CreatedAt: request.UpdatedAt,
+	})
+}
@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMoneyRequestUsecase(store *memStore) *MoneyRequestUsecase {
+	return NewMoneyRequestUsecase(store, store.repos().Requests, noLimits(store), noFees(store), MoneyRequestConfig{
+		DefaultTTL: 24 * time.Hour,
+		MaxTTL:     7 * 24 * time.Hour,
+		BatchSize:  10,
+	})
+}
+
+func TestMoneyRequestUsecase(t *testing.T) {
+	t.Run("accepting creates a transfer to the requester", func(t *testing.T) {
+		store := newMemStore()
+		requesterID := store.addUser(idr(0))
+		payerID := store.addUser(idr(50000))
+		uc := newTestMoneyRequestUsecase(store)
+
+		request, err := uc.Create(requesterID, payerID, idr(20000), "dinner", 0)
+		require.NoError(t, err)
+		assert.Equal(t, domain.MoneyRequestStatusPending, request.Status)
+
+		incoming, err := uc.GetIncoming(payerID)
+		require.NoError(t, err)
+		assert.Len(t, incoming, 1)
+
+		_, _, err = uc.Accept(requesterID, request.ID, TransferOptions{})
+		assert.ErrorIs(t, err, ErrMoneyRequestNotFound)
+
+		request, tx, err := uc.Accept(payerID, request.ID, TransferOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, domain.MoneyRequestStatusAccepted, request.Status)
+		assert.Equal(t, tx.ID, *request.TransferID)
+		assert.Equal(t, domain.TransactionStatusPending, tx.Status)
+		assert.Equal(t, requesterID, *tx.TargetUserID)
+		assert.Equal(t, idr(20000), store.wallet(payerID).HeldBalance)
+
+		_, err = uc.Decline(payerID, request.ID, "")
+		assert.ErrorIs(t, err, ErrMoneyRequestClosed)
+
+		request, err = uc.Get(requesterID, request.ID)
+		require.NoError(t, err)
+		require.Len(t, request.History, 2)
+		assert.Equal(t, domain.MoneyRequestStatusPending, request.History[0].Status)
+		assert.Equal(t, domain.MoneyRequestStatusAccepted, request.History[1].Status)
+	})
+
+	t.Run("a rejected transfer leaves the request pending", func(t *testing.T) {
+		store := newMemStore()
+		requesterID := store.addUser(idr(0))
+		payerID := store.addUser(idr(5000))
+		uc := newTestMoneyRequestUsecase(store)
+
+		request, err := uc.Create(requesterID, payerID, idr(20000), "dinner", 0)
+		require.NoError(t, err)
+
+		_, _, err = uc.Accept(payerID, request.ID, TransferOptions{})
+		assert.ErrorIs(t, err, ErrInsufficientBalance)
+		assert.Equal(t, domain.MoneyRequestStatusPending, store.requests[request.ID].Status)
+		assert.Len(t, store.requestEvents, 1)
+	})
+
+	t.Run("declined, cancelled and expired requests are closed", func(t *testing.T) {
+		store := newMemStore()
+		requesterID := store.addUser(idr(0))
+		payerID := store.addUser(idr(50000))
+		uc := newTestMoneyRequestUsecase(store)
+
+		declined, err := uc.Create(requesterID, payerID, idr(1000), "coffee", 0)
+		require.NoError(t, err)
+		declined, err = uc.Decline(payerID, declined.ID, "already paid")
+		require.NoError(t, err)
+		assert.Equal(t, domain.MoneyRequestStatusDeclined, declined.Status)
+
+		cancelled, err := uc.Create(requesterID, payerID, idr(1000), "coffee", 0)
+		require.NoError(t, err)
+		_, err = uc.Cancel(payerID, cancelled.ID)
+		assert.ErrorIs(t, err, ErrMoneyRequestNotFound)
+		cancelled, err = uc.Cancel(requesterID, cancelled.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.MoneyRequestStatusCancelled, cancelled.Status)
+
+		stale, err := uc.Create(requesterID, payerID, idr(1000), "coffee", 0)
+		require.NoError(t, err)
+		request := store.requests[stale.ID]
+		request.ExpiresAt = time.Now().Add(-time.Minute)
+		store.requests[stale.ID] = request
+
+		_, _, err = uc.Accept(payerID, stale.ID, TransferOptions{})
+		assert.ErrorIs(t, err, ErrMoneyRequestExpired)
+
+		expired, err := uc.ExpireRequests()
+		require.NoError(t, err)
+		assert.Equal(t, 1, expired)
+		assert.Equal(t, domain.MoneyRequestStatusExpired, store.requests[stale.ID].Status)
+
+		_, err = uc.Create(requesterID, requesterID, idr(1000), "coffee", 0)
+		assert.ErrorIs(t, err, ErrSelfRequest)
+	})
+
+	t.Run("a failed or cancelled transfer reopens the request", func(t *testing.T) {
+		store := newMemStore()
+		requesterID := store.addUser(idr(0))
+		payerID := store.addUser(idr(50000))
+		uc := newTestMoneyRequestUsecase(store)
+		transactions := newTestTransactionUsecase(store)
+
+		request, err := uc.Create(requesterID, payerID, idr(20000), "dinner", 0)
+		require.NoError(t, err)
+
+		_, failed, err := uc.Accept(payerID, request.ID, TransferOptions{})
+		require.NoError(t, err)
+		require.NoError(t, transactions.FailTransfer(failed.ID, "task archived"))
+
+		reopened := store.requests[request.ID]
+		assert.Equal(t, domain.MoneyRequestStatusPending, reopened.Status)
+		assert.Nil(t, reopened.TransferID)
+		assert.Equal(t, idr(0), store.wallet(payerID).HeldBalance)
+
+		_, cancelled, err := uc.Accept(payerID, request.ID, TransferOptions{})
+		require.NoError(t, err)
+		_, err = transactions.CancelTransfer(payerID, cancelled.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.MoneyRequestStatusPending, store.requests[request.ID].Status)
+
+		request, paid, err := uc.Accept(payerID, request.ID, TransferOptions{})
+		require.NoError(t, err)
+		require.NoError(t, transactions.ProcessTransfer(paid.ID))
+		require.NoError(t, transactions.FailTransfer(paid.ID, "too late"))
+		assert.Equal(t, domain.MoneyRequestStatusAccepted, store.requests[request.ID].Status)
+		assert.Equal(t, paid.ID, *store.requests[request.ID].TransferID)
+		assert.Equal(t, idr(20000), store.wallet(requesterID).Balance)
+
+		request, err = uc.Get(requesterID, request.ID)
+		require.NoError(t, err)
+		var statuses []domain.MoneyRequestStatus
+		for _, event := range request.History {
+			statuses = append(statuses, event.Status)
+		}
+		assert.Equal(t, []domain.MoneyRequestStatus{
+			domain.MoneyRequestStatusPending,
+			domain.MoneyRequestStatusAccepted,
+			domain.MoneyRequestStatusPending,
+			domain.MoneyRequestStatusAccepted,
+			domain.MoneyRequestStatusPending,
+			domain.MoneyRequestStatusAccepted,
+		}, statuses)
+		assert.Equal(t, "transfer failed: task archived", request.History[2].Reason)
+		assert.Nil(t, request.History[2].ActorID)
+		assert.Equal(t, "transfer cancelled", request.History[4].Reason)
+		assert.Equal(t, payerID, *request.History[4].ActorID)
+	})
+}
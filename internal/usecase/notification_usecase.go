@@ -0,0 +1,35 @@
+package usecase
+
+import (
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/google/uuid"
+)
+
+type NotificationUsecase struct {
+	notificationRepo domain.NotificationRepository
+}
+
+func NewNotificationUsecase(notificationRepo domain.NotificationRepository) *NotificationUsecase {
+	return &NotificationUsecase{notificationRepo: notificationRepo}
+}
+
+func (u *NotificationUsecase) GetNotifications(userID uuid.UUID) ([]domain.Notification, error) {
+	return u.notificationRepo.GetByUserID(userID)
+}
+
+func (u *NotificationUsecase) MarkRead(userID, notificationID uuid.UUID) error {
+	return u.notificationRepo.MarkRead(userID, notificationID)
+}
+
+// notify stores a notification in the same UnitOfWork as the event it
+// describes, so users are never told about changes that were rolled back.
+func notify(repos *domain.Repositories, userID uuid.UUID, title, message string) error {
+	return repos.Notifications.Create(&domain.Notification{
+		UserID:    userID,
+		Title:     title,
+		Message:   message,
+		CreatedAt: time.Now(),
+	})
+}
@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+)
+
+type TaskPublisher interface {
+	Publish(taskType, taskID string, payload []byte) error
+}
+
+// OutboxRelay publishes outbox rows to the task queue and marks them sent.
+// Rows are locked with SKIP LOCKED, so several relays can run side by side.
+type OutboxRelay struct {
+	uow       domain.UnitOfWork
+	publisher TaskPublisher
+	batchSize int
+}
+
+func NewOutboxRelay(uow domain.UnitOfWork, publisher TaskPublisher, batchSize int) *OutboxRelay {
+	return &OutboxRelay{
+		uow:       uow,
+		publisher: publisher,
+		batchSize: batchSize,
+	}
+}
+
+// RelayPending publishes one batch of pending rows and returns how many were
+// sent. A row that fails to publish keeps its PENDING status and is retried
+// on the next run.
+func (r *OutboxRelay) RelayPending() (int, error) {
+	sent := 0
+	err := r.uow.Do(func(repos *domain.Repositories) error {
+		messages, err := repos.Outbox.GetPendingForUpdate(r.batchSize)
+		if err != nil {
+			return err
+		}
+
+		for i := range messages {
+			msg := &messages[i]
+			msg.Attempts++
+
+			if err := r.publisher.Publish(msg.TaskType, msg.TaskID, msg.Payload); err != nil {
+				msg.LastError = err.Error()
+			} else {
+				now := time.Now()
+				msg.Status = domain.OutboxStatusSent
+				msg.SentAt = &now
+				msg.LastError = ""
+				sent++
+			}
+
+			if err := repos.Outbox.Update(msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return sent, err
+}
+
+// Run relays pending rows every interval until ctx is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.RelayPending(); err != nil {
+				log.Printf("Failed to relay outbox: %s", err)
+			}
+		}
+	}
+}
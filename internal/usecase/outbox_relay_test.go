@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/queue"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePublisher records published task IDs and fails those in fail.
+type fakePublisher struct {
+	published []string
+	fail      map[string]bool
+}
+
+func (p *fakePublisher) Publish(taskType, taskID string, payload []byte) error {
+	if p.fail[taskID] {
+		return errors.New("redis unavailable")
+	}
+	p.published = append(p.published, taskID)
+	return nil
+}
+
+func TestOutboxRelay(t *testing.T) {
+	addPending := func(store *memStore, n int) {
+		for range n {
+			store.outbox = append(store.outbox, domain.OutboxMessage{
+				ID:        uuid.New(),
+				TaskType:  queue.TaskTransfer,
+				TaskID:    uuid.NewString(),
+				Payload:   []byte(`{}`),
+				Status:    domain.OutboxStatusPending,
+				CreatedAt: time.Now(),
+			})
+		}
+	}
+
+	t.Run("marks published rows sent", func(t *testing.T) {
+		store := newMemStore()
+		addPending(store, 2)
+		publisher := &fakePublisher{}
+
+		sent, err := NewOutboxRelay(store, publisher, 10).RelayPending()
+		require.NoError(t, err)
+		assert.Equal(t, 2, sent)
+		assert.Len(t, publisher.published, 2)
+		for _, msg := range store.outbox {
+			assert.Equal(t, domain.OutboxStatusSent, msg.Status)
+			assert.NotNil(t, msg.SentAt)
+			assert.Equal(t, 1, msg.Attempts)
+		}
+	})
+
+	t.Run("keeps rows that fail to publish pending", func(t *testing.T) {
+		store := newMemStore()
+		addPending(store, 2)
+		failing := store.outbox[0].TaskID
+		publisher := &fakePublisher{fail: map[string]bool{failing: true}}
+		relay := NewOutboxRelay(store, publisher, 10)
+
+		sent, err := relay.RelayPending()
+		require.NoError(t, err)
+		assert.Equal(t, 1, sent)
+
+		msg := store.outbox[0]
+		assert.Equal(t, domain.OutboxStatusPending, msg.Status)
+		assert.Equal(t, 1, msg.Attempts)
+		assert.Equal(t, "redis unavailable", msg.LastError)
+		assert.Nil(t, msg.SentAt)
+		assert.Equal(t, domain.OutboxStatusSent, store.outbox[1].Status)
+
+		_, err = relay.RelayPending()
+		require.NoError(t, err)
+		assert.Equal(t, 2, store.outbox[0].Attempts)
+
+		delete(publisher.fail, failing)
+		sent, err = relay.RelayPending()
+		require.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		assert.Equal(t, domain.OutboxStatusSent, store.outbox[0].Status)
+		assert.Empty(t, store.outbox[0].LastError)
+	})
+
+	t.Run("publishes at most one batch per run", func(t *testing.T) {
+		store := newMemStore()
+		addPending(store, 5)
+		publisher := &fakePublisher{}
+		relay := NewOutboxRelay(store, publisher, 2)
+
+		sent, err := relay.RelayPending()
+		require.NoError(t, err)
+		assert.Equal(t, 2, sent)
+		assert.Len(t, publisher.published, 2)
+
+		pending := 0
+		for _, msg := range store.outbox {
+			if msg.Status == domain.OutboxStatusPending {
+				pending++
+			}
+		}
+		assert.Equal(t, 3, pending)
+	})
+}
@@ -0,0 +1,579 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/bangadam/wallet-api/pkg/queue"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPaymentIntentNotFound  = errors.New("payment intent not found")
+	ErrPaymentIntentClosed    = errors.New("payment intent is no longer open")
+	ErrPaymentIntentExpired   = errors.New("payment intent has expired")
+	ErrPaymentIntentTaken     = errors.New("payment intent is being paid by another user")
+	ErrPaymentIntentNotOpened = errors.New("payment intent must be opened before it is confirmed")
+	ErrInvalidPIN             = errors.New("invalid PIN")
+	ErrTooManyPINAttempts     = errors.New("too many wrong PINs; try again later")
+	ErrCallbackNotAllowed     = errors.New("callback URL must not point to a private, loopback or link-local address")
+	ErrCallbackRedirected     = errors.New("callback redirected to another scheme or host")
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of a callback body, keyed with
+// the configured callback secret.
+const SignatureHeader = "X-Wallet-Signature"
+
+type PaymentIntentConfig struct {
+	// DefaultTTL is used when an intent does not ask for one; no intent may
+	// stay open longer than MaxTTL.
+	DefaultTTL      time.Duration
+	MaxTTL          time.Duration
+	BatchSize       int
+	CallbackTimeout time.Duration
+	// CallbackSecret signs callback bodies; callbacks are unsigned without it.
+	CallbackSecret string
+	// MaxPINAttempts wrong PINs in a row cancel the intent being confirmed
+	// and lock the user's PIN confirmations for PINLockout.
+	MaxPINAttempts int
+	PINLockout     time.Duration
+}
+
+// PaymentIntentInput describes a checkout. TTL is how long the user has to
+// pay it; zero means the configured default.
+type PaymentIntentInput struct {
+	Amount         money.Money
+	OrderReference string
+	Description    string
+	CallbackURL    string
+	TTL            time.Duration
+}
+
+type PaymentIntentUsecase struct {
+	uow          domain.UnitOfWork
+	intentRepo   domain.PaymentIntentRepository
+	merchantRepo domain.MerchantRepository
+	payments     *TransactionUsecase
+	config       PaymentIntentConfig
+	client       *http.Client
+	// allowIP decides which addresses callbacks may be sent to.
+	allowIP func(netip.Addr) bool
+}
+
+func NewPaymentIntentUsecase(uow domain.UnitOfWork, intentRepo domain.PaymentIntentRepository, merchantRepo domain.MerchantRepository, payments *TransactionUsecase, config PaymentIntentConfig) *PaymentIntentUsecase {
+	u := &PaymentIntentUsecase{
+		uow:          uow,
+		intentRepo:   intentRepo,
+		merchantRepo: merchantRepo,
+		payments:     payments,
+		config:       config,
+		allowIP:      publicIP,
+	}
+
+	// Callback hosts are checked again on every connection, since the
+	// address a name resolves to can change after the intent was created.
+	dialer := &net.Dialer{Timeout: config.CallbackTimeout, Control: u.controlCallbackDial}
+	u.client = &http.Client{
+		Timeout: config.CallbackTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: config.CallbackTimeout,
+			ForceAttemptHTTP2:   true,
+		},
+		CheckRedirect: checkCallbackRedirect,
+	}
+	return u
+}
+
+// Create starts a checkout for one of the user's merchants.
+func (u *PaymentIntentUsecase) Create(ownerID, merchantID uuid.UUID, in PaymentIntentInput) (*domain.PaymentIntent, error) {
+	merchant, err := ownedMerchant(u.merchantRepo, ownerID, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if merchant.Status != domain.MerchantStatusActive {
+		return nil, ErrMerchantSuspended
+	}
+
+	if !in.Amount.IsPositive() {
+		return nil, errors.New("amount must be positive")
+	}
+	if !merchant.Balance.SameCurrency(in.Amount) {
+		return nil, money.ErrCurrencyMismatch
+	}
+	if strings.TrimSpace(in.OrderReference) == "" {
+		return nil, errors.New("order reference is required")
+	}
+	if in.CallbackURL != "" {
+		if err := u.validateCallbackURL(in.CallbackURL); err != nil {
+			return nil, err
+		}
+	}
+
+	ttl := in.TTL
+	if ttl <= 0 {
+		ttl = u.config.DefaultTTL
+	}
+	if ttl > u.config.MaxTTL {
+		ttl = u.config.MaxTTL
+	}
+
+	now := time.Now()
+	intent := &domain.PaymentIntent{
+		ID:             uuid.New(),
+		MerchantID:     merchantID,
+		Status:         domain.PaymentIntentStatusCreated,
+		Amount:         in.Amount,
+		OrderReference: in.OrderReference,
+		Description:    in.Description,
+		CallbackURL:    in.CallbackURL,
+		ExpiresAt:      now.Add(ttl),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := u.intentRepo.Create(intent); err != nil {
+		return nil, err
+	}
+
+	return intent, nil
+}
+
+// validateCallbackURL accepts absolute https URLs whose host only resolves to
+// public addresses.
+func (u *PaymentIntentUsecase) validateCallbackURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme != "https" || parsed.Hostname() == "" {
+		return errors.New("callback URL must be an absolute https URL")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), u.config.CallbackTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("callback host cannot be resolved: %w", err)
+	}
+	for _, addr := range addrs {
+		if !u.allowIP(addr) {
+			return ErrCallbackNotAllowed
+		}
+	}
+	return nil
+}
+
+// controlCallbackDial refuses callback connections to addresses allowIP
+// rejects, after the host name has been resolved.
+func (u *PaymentIntentUsecase) controlCallbackDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !u.allowIP(addr) {
+		return ErrCallbackNotAllowed
+	}
+	return nil
+}
+
+// checkCallbackRedirect only follows redirects that keep the scheme and host
+// of the callback URL.
+func checkCallbackRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("callback stopped after 10 redirects")
+	}
+	if req.URL.Scheme != via[0].URL.Scheme || req.URL.Host != via[0].URL.Host {
+		return ErrCallbackRedirected
+	}
+	return nil
+}
+
+// specialPurposePrefixes are the ranges IsGlobalUnicast still lets through
+// that must not receive callbacks: shared, reserved, benchmarking and
+// documentation space and the IPv6 prefixes that embed IPv4 addresses.
+var specialPurposePrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("3fff::/20"),
+}
+
+// publicIP reports whether addr may receive callbacks: a global unicast
+// address outside the private and special-purpose ranges. IPv4-mapped IPv6
+// addresses are judged by their IPv4 address.
+func publicIP(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range specialPurposePrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetIntents lists the payment intents of one of the user's merchants.
+func (u *PaymentIntentUsecase) GetIntents(ownerID, merchantID uuid.UUID) ([]domain.PaymentIntent, error) {
+	if _, err := ownedMerchant(u.merchantRepo, ownerID, merchantID); err != nil {
+		return nil, err
+	}
+	return u.intentRepo.GetByMerchantID(merchantID)
+}
+
+// Get returns an intent to a user following its link. Once a user has
+// opened it, only that user and the merchant's owner can see it.
+func (u *PaymentIntentUsecase) Get(userID, intentID uuid.UUID) (*domain.PaymentIntent, error) {
+	intent, err := u.intentRepo.GetByID(intentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPaymentIntentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if intent.PayerID == nil && intent.Status == domain.PaymentIntentStatusCreated {
+		return intent, nil
+	}
+	if intent.PayerID != nil && *intent.PayerID == userID {
+		return intent, nil
+	}
+	if _, err := ownedMerchant(u.merchantRepo, userID, intent.MerchantID); err == nil {
+		return intent, nil
+	}
+
+	return nil, ErrPaymentIntentNotFound
+}
+
+// Open binds an intent to the user who followed its link, after which it
+// requires their confirmation. Opening it again is a no-op.
+func (u *PaymentIntentUsecase) Open(userID, intentID uuid.UUID) (*domain.PaymentIntent, error) {
+	var intent *domain.PaymentIntent
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		var err error
+		intent, err = lockPaymentIntent(repos, intentID)
+		if err != nil {
+			return err
+		}
+
+		if !intent.Open() {
+			return ErrPaymentIntentClosed
+		}
+		if !time.Now().Before(intent.ExpiresAt) {
+			return ErrPaymentIntentExpired
+		}
+		if intent.PayerID != nil {
+			if *intent.PayerID != userID {
+				return ErrPaymentIntentTaken
+			}
+			return nil
+		}
+
+		intent.PayerID = &userID
+		intent.Status = domain.PaymentIntentStatusRequiresConfirmation
+		intent.UpdatedAt = time.Now()
+		return repos.Intents.Update(intent)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return intent, nil
+}
+
+// Confirm pays an opened intent after checking the user's PIN. opts may pick
+// a pocket and a promo code; the merchant and order come from the intent.
+func (u *PaymentIntentUsecase) Confirm(userID, intentID uuid.UUID, pin string, opts PaymentOptions) (*domain.PaymentIntent, *domain.Transaction, error) {
+	if err := u.verifyPIN(userID, intentID, pin); err != nil {
+		return nil, nil, err
+	}
+
+	intent, err := u.Get(userID, intentID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts.MerchantID = intent.MerchantID
+	opts.OrderReference = intent.OrderReference
+	opts.PaymentIntentID = intent.ID
+	tx, err := u.payments.Payment(userID, intent.Amount, intent.Description, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	intent, err = u.intentRepo.GetByID(intentID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return intent, tx, nil
+}
+
+// verifyPIN checks the PIN of the user paying an intent. The PIN is only
+// compared once the intent is known to be open and opened by the user, so
+// intents that cannot be paid reveal nothing about it. Wrong PINs are counted
+// on the user across intents: MaxPINAttempts of them in a row cancel the
+// intent and lock PIN confirmation for PINLockout.
+func (u *PaymentIntentUsecase) verifyPIN(userID, intentID uuid.UUID, pin string) error {
+	var result error
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		intent, err := lockPaymentIntent(repos, intentID)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case !intent.Open():
+			return ErrPaymentIntentClosed
+		case !time.Now().Before(intent.ExpiresAt):
+			return ErrPaymentIntentExpired
+		case intent.PayerID == nil:
+			return ErrPaymentIntentNotOpened
+		case *intent.PayerID != userID:
+			return ErrPaymentIntentTaken
+		}
+
+		user, err := repos.Users.GetByIDForUpdate(userID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if user.PINLockedUntil != nil && now.Before(*user.PINLockedUntil) {
+			return ErrTooManyPINAttempts
+		}
+
+		if bcrypt.CompareHashAndPassword([]byte(user.Pin), []byte(pin)) == nil {
+			if user.FailedPINAttempts == 0 && user.PINLockedUntil == nil {
+				return nil
+			}
+			user.FailedPINAttempts = 0
+			user.PINLockedUntil = nil
+			return repos.Users.Update(user)
+		}
+
+		// The attempt is recorded, so the unit of work has to commit.
+		result = ErrInvalidPIN
+		user.FailedPINAttempts++
+		if u.config.MaxPINAttempts > 0 && user.FailedPINAttempts >= u.config.MaxPINAttempts {
+			result = ErrTooManyPINAttempts
+			lockedUntil := now.Add(u.config.PINLockout)
+			user.FailedPINAttempts = 0
+			user.PINLockedUntil = &lockedUntil
+			if err := closePaymentIntent(repos, intent, domain.PaymentIntentStatusCancelled); err != nil {
+				return err
+			}
+		}
+		return repos.Users.Update(user)
+	})
+	if err != nil {
+		return err
+	}
+
+	return result
+}
+
+// Cancel closes an open intent. Both the user paying it and the merchant's
+// owner may cancel.
+func (u *PaymentIntentUsecase) Cancel(userID, intentID uuid.UUID) (*domain.PaymentIntent, error) {
+	var intent *domain.PaymentIntent
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		var err error
+		intent, err = lockPaymentIntent(repos, intentID)
+		if err != nil {
+			return err
+		}
+
+		payer := intent.PayerID != nil && *intent.PayerID == userID
+		if !payer {
+			if _, err := ownedMerchant(repos.Merchants, userID, intent.MerchantID); err != nil {
+				return ErrPaymentIntentNotFound
+			}
+		}
+
+		if !intent.Open() {
+			return ErrPaymentIntentClosed
+		}
+
+		return closePaymentIntent(repos, intent, domain.PaymentIntentStatusCancelled)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return intent, nil
+}
+
+// ExpireIntents closes open intents past their expiry and returns how many
+// it closed.
+func (u *PaymentIntentUsecase) ExpireIntents() (int, error) {
+	now := time.Now()
+	intents, err := u.intentRepo.GetExpired(now, u.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, intent := range intents {
+		err := u.uow.Do(func(repos *domain.Repositories) error {
+			locked, err := repos.Intents.GetByIDForUpdate(intent.ID)
+			if err != nil {
+				return err
+			}
+			if !locked.Open() || !now.After(locked.ExpiresAt) {
+				return nil
+			}
+
+			if err := closePaymentIntent(repos, locked, domain.PaymentIntentStatusExpired); err != nil {
+				return err
+			}
+			expired++
+			return nil
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+
+	return expired, nil
+}
+
+// DeliverCallback posts the intent to the merchant's callback URL. An error
+// makes the worker retry the delivery.
+func (u *PaymentIntentUsecase) DeliverCallback(intentID uuid.UUID) error {
+	intent, err := u.intentRepo.GetByID(intentID)
+	if err != nil {
+		return err
+	}
+	if intent.CallbackURL == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(intent)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, intent.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if u.config.CallbackSecret != "" {
+		mac := hmac.New(sha256.New, []byte(u.config.CallbackSecret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("callback to %s returned %s", intent.CallbackURL, resp.Status)
+	}
+	return nil
+}
+
+func lockPaymentIntent(repos *domain.Repositories, intentID uuid.UUID) (*domain.PaymentIntent, error) {
+	intent, err := repos.Intents.GetByIDForUpdate(intentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPaymentIntentNotFound
+	}
+	return intent, err
+}
+
+// lockIntentForPayment locks the intent a payment completes and checks that
+// userID may pay amount to merchantID with it. It runs before the payer's
+// rows are locked.
+func lockIntentForPayment(repos *domain.Repositories, intentID, userID, merchantID uuid.UUID, amount money.Money) (*domain.PaymentIntent, error) {
+	intent, err := lockPaymentIntent(repos, intentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !intent.Open() {
+		return nil, ErrPaymentIntentClosed
+	}
+	if !time.Now().Before(intent.ExpiresAt) {
+		return nil, ErrPaymentIntentExpired
+	}
+	if intent.PayerID == nil {
+		return nil, ErrPaymentIntentNotOpened
+	}
+	if *intent.PayerID != userID {
+		return nil, ErrPaymentIntentTaken
+	}
+	if intent.MerchantID != merchantID || intent.Amount != amount {
+		return nil, errors.New("payment does not match the payment intent")
+	}
+
+	return intent, nil
+}
+
+// completePaymentIntent marks an intent paid by tx in the payment's unit of
+// work.
+func completePaymentIntent(repos *domain.Repositories, intent *domain.PaymentIntent, tx *domain.Transaction) error {
+	intent.TransactionID = &tx.ID
+	return closePaymentIntent(repos, intent, domain.PaymentIntentStatusSucceeded)
+}
+
+// closePaymentIntent moves an intent to a final status and writes the
+// merchant's callback to the outbox.
+func closePaymentIntent(repos *domain.Repositories, intent *domain.PaymentIntent, status domain.PaymentIntentStatus) error {
+	intent.Status = status
+	intent.UpdatedAt = time.Now()
+	if err := repos.Intents.Update(intent); err != nil {
+		return err
+	}
+
+	if intent.CallbackURL == "" {
+		return nil
+	}
+
+	payload, err := json.Marshal(&queue.PaymentIntentCallbackPayload{
+		PaymentIntentID: intent.ID.String(),
+		Status:          string(status),
+	})
+	if err != nil {
+		return err
+	}
+
+	return repos.Outbox.Create(&domain.OutboxMessage{
+		TaskType:  queue.TaskPaymentIntentCallback,
+		TaskID:    intent.ID.String() + ":" + string(status),
+		Payload:   payload,
+		Status:    domain.OutboxStatusPending,
+		CreatedAt: time.Now(),
+	})
+}
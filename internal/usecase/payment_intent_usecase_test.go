@@ -0,0 +1,326 @@
+package usecase
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/queue"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPaymentIntentUsecase(t *testing.T) {
+	var callbacks []domain.PaymentIntent
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "http://"+r.Host+"/", http.StatusTemporaryRedirect)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), r.Header.Get(SignatureHeader))
+
+		var intent domain.PaymentIntent
+		require.NoError(t, json.Unmarshal(body, &intent))
+		callbacks = append(callbacks, intent)
+	}))
+	defer server.Close()
+
+	setup := func(t *testing.T) (*memStore, *PaymentIntentUsecase, *domain.Merchant, uuid.UUID) {
+		store := newMemStore()
+		_, merchant := newTestMerchant(t, store)
+
+		payerID := store.addUser(idr(100000))
+		pin, err := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.MinCost)
+		require.NoError(t, err)
+		payer := store.users[payerID]
+		payer.Pin = string(pin)
+		store.users[payerID] = payer
+
+		uc := NewPaymentIntentUsecase(store, store.repos().Intents, store.repos().Merchants, newTestTransactionUsecase(store), PaymentIntentConfig{
+			DefaultTTL:      15 * time.Minute,
+			MaxTTL:          time.Hour,
+			BatchSize:       10,
+			CallbackTimeout: time.Second,
+			CallbackSecret:  "secret",
+			MaxPINAttempts:  3,
+			PINLockout:      15 * time.Minute,
+		})
+		// The test server listens on loopback with its own certificate.
+		uc.allowIP = func(netip.Addr) bool { return true }
+		uc.client.Transport.(*http.Transport).TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+		return store, uc, merchant, payerID
+	}
+
+	create := func(t *testing.T, uc *PaymentIntentUsecase, merchant *domain.Merchant) *domain.PaymentIntent {
+		intent, err := uc.Create(merchant.OwnerID, merchant.ID, PaymentIntentInput{
+			Amount:         idr(25000),
+			OrderReference: "ORDER-1",
+			Description:    "Kopi susu",
+			CallbackURL:    server.URL,
+		})
+		require.NoError(t, err)
+		return intent
+	}
+
+	t.Run("confirming with the PIN pays the merchant and calls back", func(t *testing.T) {
+		store, uc, merchant, payerID := setup(t)
+		intent := create(t, uc, merchant)
+		assert.Equal(t, domain.PaymentIntentStatusCreated, intent.Status)
+
+		_, _, err := uc.Confirm(payerID, intent.ID, "123456", PaymentOptions{})
+		assert.ErrorIs(t, err, ErrPaymentIntentNotOpened)
+
+		intent, err = uc.Open(payerID, intent.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.PaymentIntentStatusRequiresConfirmation, intent.Status)
+
+		_, err = uc.Open(store.addUser(idr(0)), intent.ID)
+		assert.ErrorIs(t, err, ErrPaymentIntentTaken)
+
+		_, _, err = uc.Confirm(payerID, intent.ID, "000000", PaymentOptions{})
+		assert.ErrorIs(t, err, ErrInvalidPIN)
+
+		intent, tx, err := uc.Confirm(payerID, intent.ID, "123456", PaymentOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, domain.PaymentIntentStatusSucceeded, intent.Status)
+		assert.Equal(t, tx.ID, *intent.TransactionID)
+		assert.Equal(t, "ORDER-1", tx.OrderReference)
+		assert.Equal(t, idr(75000), store.wallet(payerID).Balance)
+		assert.Equal(t, idr(25000), store.merchants[merchant.ID].Balance)
+
+		_, _, err = uc.Confirm(payerID, intent.ID, "123456", PaymentOptions{})
+		assert.ErrorIs(t, err, ErrPaymentIntentClosed)
+		assert.Equal(t, idr(75000), store.wallet(payerID).Balance)
+
+		require.Len(t, store.outbox, 1)
+		assert.Equal(t, queue.TaskPaymentIntentCallback, store.outbox[0].TaskType)
+
+		callbacks = nil
+		require.NoError(t, uc.DeliverCallback(intent.ID))
+		require.Len(t, callbacks, 1)
+		assert.Equal(t, domain.PaymentIntentStatusSucceeded, callbacks[0].Status)
+	})
+
+	t.Run("open intents expire", func(t *testing.T) {
+		store, uc, merchant, payerID := setup(t)
+		intent := create(t, uc, merchant)
+
+		stale := store.intents[intent.ID]
+		stale.ExpiresAt = time.Now().Add(-time.Minute)
+		store.intents[intent.ID] = stale
+
+		_, err := uc.Open(payerID, intent.ID)
+		assert.ErrorIs(t, err, ErrPaymentIntentExpired)
+
+		expired, err := uc.ExpireIntents()
+		require.NoError(t, err)
+		assert.Equal(t, 1, expired)
+		assert.Equal(t, domain.PaymentIntentStatusExpired, store.intents[intent.ID].Status)
+		assert.Len(t, store.outbox, 1)
+	})
+
+	t.Run("only the payer and the merchant's owner can cancel", func(t *testing.T) {
+		store, uc, merchant, payerID := setup(t)
+		intent := create(t, uc, merchant)
+
+		_, err := uc.Cancel(payerID, intent.ID)
+		assert.ErrorIs(t, err, ErrPaymentIntentNotFound)
+
+		intent, err = uc.Cancel(merchant.OwnerID, intent.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.PaymentIntentStatusCancelled, intent.Status)
+
+		_, err = uc.Open(payerID, intent.ID)
+		assert.ErrorIs(t, err, ErrPaymentIntentClosed)
+		assert.Equal(t, idr(100000), store.wallet(payerID).Balance)
+	})
+
+	t.Run("wrong PINs cancel the intent and lock confirmation", func(t *testing.T) {
+		store, uc, merchant, payerID := setup(t)
+		first := create(t, uc, merchant)
+		_, err := uc.Open(payerID, first.ID)
+		require.NoError(t, err)
+
+		for i := 1; i < 3; i++ {
+			_, _, err = uc.Confirm(payerID, first.ID, "000000", PaymentOptions{})
+			assert.ErrorIs(t, err, ErrInvalidPIN)
+			assert.Equal(t, i, store.users[payerID].FailedPINAttempts)
+		}
+
+		// A fresh intent does not reset the count.
+		_, err = uc.Cancel(merchant.OwnerID, first.ID)
+		require.NoError(t, err)
+		second := create(t, uc, merchant)
+		_, err = uc.Open(payerID, second.ID)
+		require.NoError(t, err)
+
+		_, _, err = uc.Confirm(payerID, second.ID, "000000", PaymentOptions{})
+		assert.ErrorIs(t, err, ErrTooManyPINAttempts)
+		assert.Equal(t, domain.PaymentIntentStatusCancelled, store.intents[second.ID].Status)
+		require.NotNil(t, store.users[payerID].PINLockedUntil)
+
+		third := create(t, uc, merchant)
+		_, err = uc.Open(payerID, third.ID)
+		require.NoError(t, err)
+		_, _, err = uc.Confirm(payerID, third.ID, "123456", PaymentOptions{})
+		assert.ErrorIs(t, err, ErrTooManyPINAttempts, "even the right PIN is refused while locked")
+		assert.Equal(t, idr(100000), store.wallet(payerID).Balance)
+
+		user := store.users[payerID]
+		past := time.Now().Add(-time.Minute)
+		user.PINLockedUntil = &past
+		store.users[payerID] = user
+
+		_, _, err = uc.Confirm(payerID, third.ID, "123456", PaymentOptions{})
+		require.NoError(t, err)
+		assert.Zero(t, store.users[payerID].FailedPINAttempts)
+		assert.Nil(t, store.users[payerID].PINLockedUntil)
+	})
+
+	t.Run("PINs are not checked against intents the user cannot pay", func(t *testing.T) {
+		store, uc, merchant, payerID := setup(t)
+
+		_, _, err := uc.Confirm(payerID, uuid.New(), "000000", PaymentOptions{})
+		assert.ErrorIs(t, err, ErrPaymentIntentNotFound)
+
+		closed := create(t, uc, merchant)
+		_, err = uc.Open(payerID, closed.ID)
+		require.NoError(t, err)
+		_, err = uc.Cancel(payerID, closed.ID)
+		require.NoError(t, err)
+		_, _, err = uc.Confirm(payerID, closed.ID, "000000", PaymentOptions{})
+		assert.ErrorIs(t, err, ErrPaymentIntentClosed)
+
+		unopened := create(t, uc, merchant)
+		_, _, err = uc.Confirm(payerID, unopened.ID, "000000", PaymentOptions{})
+		assert.ErrorIs(t, err, ErrPaymentIntentNotOpened)
+
+		taken := create(t, uc, merchant)
+		_, err = uc.Open(store.addUser(idr(0)), taken.ID)
+		require.NoError(t, err)
+		_, _, err = uc.Confirm(payerID, taken.ID, "000000", PaymentOptions{})
+		assert.ErrorIs(t, err, ErrPaymentIntentTaken)
+
+		assert.Zero(t, store.users[payerID].FailedPINAttempts)
+	})
+
+	t.Run("callback URLs must be public https URLs", func(t *testing.T) {
+		_, uc, merchant, _ := setup(t)
+		uc.allowIP = publicIP
+
+		for _, callbackURL := range []string{
+			"http://93.184.216.34/callback",
+			"ftp://93.184.216.34/callback",
+			"https:///callback",
+		} {
+			_, err := uc.Create(merchant.OwnerID, merchant.ID, PaymentIntentInput{
+				Amount:         idr(25000),
+				OrderReference: "ORDER-1",
+				CallbackURL:    callbackURL,
+			})
+			assert.Error(t, err, callbackURL)
+		}
+
+		for _, callbackURL := range []string{
+			"https://127.0.0.1/callback",
+			"https://localhost:8443/callback",
+			"https://10.1.2.3/callback",
+			"https://192.168.0.10/callback",
+			"https://169.254.169.254/latest/meta-data",
+			"https://[::1]/callback",
+			"https://[fe80::1]/callback",
+			"https://0.0.0.0/callback",
+			"https://100.64.0.1/callback",
+			"https://[::ffff:127.0.0.1]/callback",
+		} {
+			_, err := uc.Create(merchant.OwnerID, merchant.ID, PaymentIntentInput{
+				Amount:         idr(25000),
+				OrderReference: "ORDER-1",
+				CallbackURL:    callbackURL,
+			})
+			assert.ErrorIs(t, err, ErrCallbackNotAllowed, callbackURL)
+		}
+
+		_, err := uc.Create(merchant.OwnerID, merchant.ID, PaymentIntentInput{
+			Amount:         idr(25000),
+			OrderReference: "ORDER-1",
+			CallbackURL:    "https://93.184.216.34/callback",
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("callbacks are not delivered to private addresses", func(t *testing.T) {
+		_, uc, merchant, _ := setup(t)
+		intent := create(t, uc, merchant)
+
+		// The host passed validation but now resolves to a loopback address.
+		uc.allowIP = publicIP
+		callbacks = nil
+		err := uc.DeliverCallback(intent.ID)
+		assert.ErrorIs(t, err, ErrCallbackNotAllowed)
+		assert.Empty(t, callbacks)
+	})
+
+	t.Run("callbacks do not follow redirects to another scheme or host", func(t *testing.T) {
+		store, uc, merchant, _ := setup(t)
+		intent := create(t, uc, merchant)
+		moved := store.intents[intent.ID]
+		moved.CallbackURL = server.URL + "/moved"
+		store.intents[intent.ID] = moved
+
+		callbacks = nil
+		err := uc.DeliverCallback(intent.ID)
+		assert.ErrorIs(t, err, ErrCallbackRedirected)
+		assert.Empty(t, callbacks)
+	})
+}
+
+func TestPublicIP(t *testing.T) {
+	for _, tt := range []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:4700::1111", true},
+		{"::ffff:93.184.216.34", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.0.10", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"100.64.1.1", false},
+		{"192.0.0.8", false},
+		{"192.0.2.1", false},
+		{"198.18.0.1", false},
+		{"198.51.100.7", false},
+		{"203.0.113.5", false},
+		{"255.255.255.255", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"::", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"2001:db8::1", false},
+		{"64:ff9b::a00:1", false},
+		{"2002:a00:1::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	} {
+		assert.Equal(t, tt.want, publicIP(netip.MustParseAddr(tt.addr)), tt.addr)
+	}
+}
@@ -0,0 +1,280 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPocketNotFound = errors.New("pocket not found")
+	ErrPocketExists   = errors.New("a pocket with this name already exists")
+	ErrPocketNotEmpty = errors.New("pocket still holds money")
+)
+
+type PocketUsecase struct {
+	uow        domain.UnitOfWork
+	pocketRepo domain.PocketRepository
+}
+
+func NewPocketUsecase(uow domain.UnitOfWork, pocketRepo domain.PocketRepository) *PocketUsecase {
+	return &PocketUsecase{
+		uow:        uow,
+		pocketRepo: pocketRepo,
+	}
+}
+
+func (u *PocketUsecase) Create(userID uuid.UUID, name, currency string) (*domain.Pocket, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("pocket name is required")
+	}
+	if err := money.ValidateCurrency(currency); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	pocket := &domain.Pocket{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Balance:   money.Zero(currency),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err := u.pocketRepo.Create(pocket)
+	if errors.Is(err, domain.ErrPocketNameTaken) {
+		return nil, ErrPocketExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return pocket, nil
+}
+
+func (u *PocketUsecase) GetPockets(userID uuid.UUID) ([]domain.Pocket, error) {
+	return u.pocketRepo.GetByUserID(userID)
+}
+
+func (u *PocketUsecase) GetPocket(userID, pocketID uuid.UUID) (*domain.Pocket, error) {
+	pocket, err := u.pocketRepo.GetByID(pocketID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && pocket.UserID != userID) {
+		return nil, ErrPocketNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pocket, nil
+}
+
+// Deposit moves money from the wallet into the pocket.
+func (u *PocketUsecase) Deposit(userID, pocketID uuid.UUID, amount money.Money) (*domain.Transaction, error) {
+	var tx *domain.Transaction
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		var err error
+		tx, err = fundPocket(repos, userID, pocketID, amount)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// Withdraw moves money from the pocket back into the wallet.
+func (u *PocketUsecase) Withdraw(userID, pocketID uuid.UUID, amount money.Money) (*domain.Transaction, error) {
+	var tx *domain.Transaction
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		var err error
+		tx, err = drawPocket(repos, userID, pocketID, amount)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// Delete removes an empty pocket.
+func (u *PocketUsecase) Delete(userID, pocketID uuid.UUID) error {
+	return u.uow.Do(func(repos *domain.Repositories) error {
+		pocket, err := lockPocket(repos, userID, pocketID)
+		if err != nil {
+			return err
+		}
+
+		if !pocket.Balance.IsZero() {
+			return ErrPocketNotEmpty
+		}
+
+		return repos.Pockets.Delete(pocket.ID)
+	})
+}
+
+// lockPocket locks the user and then one of their pockets, in the same order
+// as the balance helpers so that pocket moves cannot deadlock with them.
+func lockPocket(repos *domain.Repositories, userID, pocketID uuid.UUID) (*domain.Pocket, error) {
+	if _, err := repos.Users.GetByIDForUpdate(userID); err != nil {
+		return nil, err
+	}
+
+	pocket, err := repos.Pockets.GetByIDForUpdate(pocketID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPocketNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if pocket.UserID != userID {
+		return nil, ErrPocketNotFound
+	}
+
+	return pocket, nil
+}
+
+// fundPocket debits the wallet and credits the pocket.
+func fundPocket(repos *domain.Repositories, userID, pocketID uuid.UUID, amount money.Money) (*domain.Transaction, error) {
+	pocket, err := lockPocket(repos, userID, pocketID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !pocket.Balance.SameCurrency(amount) {
+		return nil, money.ErrCurrencyMismatch
+	}
+
+	before, after, err := debitBalance(repos, userID, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repos.Pockets.UpdateBalance(pocket.ID, pocket.Balance.Add(amount)); err != nil {
+		return nil, err
+	}
+
+	tx := pocketTransaction(pocket, domain.TransactionTypeDebit, amount, before, after, "to pocket "+pocket.Name)
+	if err := repos.Transactions.Create(tx); err != nil {
+		return nil, err
+	}
+
+	err = postJournal(repos, tx.ID, "pocket deposit",
+		debitLine(userAccount(userID), amount),
+		creditLine(pocketAccount(pocket), amount),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// drawPocket debits the pocket and credits the wallet.
+func drawPocket(repos *domain.Repositories, userID, pocketID uuid.UUID, amount money.Money) (*domain.Transaction, error) {
+	pocket, err := lockPocket(repos, userID, pocketID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !pocket.Balance.SameCurrency(amount) {
+		return nil, money.ErrCurrencyMismatch
+	}
+	if pocket.Balance.LessThan(amount) {
+		return nil, ErrInsufficientBalance
+	}
+
+	if err := repos.Pockets.UpdateBalance(pocket.ID, pocket.Balance.Sub(amount)); err != nil {
+		return nil, err
+	}
+
+	before, after, err := creditBalance(repos, userID, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := pocketTransaction(pocket, domain.TransactionTypeCredit, amount, before, after, "from pocket "+pocket.Name)
+	if err := repos.Transactions.Create(tx); err != nil {
+		return nil, err
+	}
+
+	err = postJournal(repos, tx.ID, "pocket withdrawal",
+		debitLine(pocketAccount(pocket), amount),
+		creditLine(userAccount(userID), amount),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// returnToPocket moves what a failed or cancelled transfer drew from its
+// pocket back there, once the transfer's hold and fee have been released:
+// the amount and the fee, as far as they are in the pocket's currency. The
+// money stays in the wallet if the pocket is gone or the wallet is frozen.
+func returnToPocket(repos *domain.Repositories, tx *domain.Transaction) error {
+	if tx.PocketID == nil {
+		return nil
+	}
+
+	pocket, err := lockPocket(repos, tx.UserID, *tx.PocketID)
+	if errors.Is(err, ErrPocketNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	amount := money.Zero(pocket.Balance.Currency)
+	if tx.Amount.SameCurrency(amount) {
+		amount = amount.Add(tx.Amount)
+	}
+
+	fees, err := repos.Transactions.GetByReference(tx.ID, domain.ReferenceTypeFee)
+	if err != nil {
+		return err
+	}
+	for _, feeTx := range fees {
+		if feeTx.Status == tx.Status && feeTx.Amount.SameCurrency(amount) {
+			amount = amount.Add(feeTx.Amount)
+		}
+	}
+
+	if !amount.IsPositive() {
+		return nil
+	}
+
+	_, err = fundPocket(repos, tx.UserID, pocket.ID, amount)
+	if errors.Is(err, ErrWalletFrozen) {
+		return nil
+	}
+	return err
+}
+
+func pocketTransaction(pocket *domain.Pocket, txType domain.TransactionType, amount, before, after money.Money, remarks string) *domain.Transaction {
+	now := time.Now()
+	return &domain.Transaction{
+		ID:            uuid.New(),
+		UserID:        pocket.UserID,
+		Type:          txType,
+		Status:        domain.TransactionStatusSuccess,
+		Amount:        amount,
+		Remarks:       remarks,
+		BalanceBefore: before,
+		BalanceAfter:  after,
+		ReferenceID:   pocket.ID,
+		ReferenceType: domain.ReferenceTypePocket,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		SettledAt:     &now,
+	}
+}
@@ -0,0 +1,126 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPocketUsecase(t *testing.T) {
+	t.Run("moves money in and out of a pocket", func(t *testing.T) {
+		store := newMemStore()
+		userID := store.addUser(idr(10000))
+		uc := NewPocketUsecase(store, store.repos().Pockets)
+
+		pocket, err := uc.Create(userID, "Rent", "IDR")
+		require.NoError(t, err)
+
+		_, err = uc.Create(userID, "rent", "IDR")
+		assert.ErrorIs(t, err, ErrPocketExists)
+
+		tx, err := uc.Deposit(userID, pocket.ID, idr(6000))
+		require.NoError(t, err)
+		assert.Equal(t, domain.ReferenceTypePocket, tx.ReferenceType)
+		assert.Equal(t, pocket.ID, tx.ReferenceID)
+		assert.Equal(t, idr(4000), store.wallet(userID).Balance)
+		assert.Equal(t, idr(6000), store.pockets[pocket.ID].Balance)
+
+		_, err = uc.Withdraw(userID, pocket.ID, idr(7000))
+		assert.ErrorIs(t, err, ErrInsufficientBalance)
+
+		_, err = uc.Withdraw(userID, pocket.ID, idr(1000))
+		require.NoError(t, err)
+		assert.Equal(t, idr(5000), store.wallet(userID).Balance)
+		assert.Equal(t, idr(5000), store.pockets[pocket.ID].Balance)
+
+		assert.ErrorIs(t, uc.Delete(userID, pocket.ID), ErrPocketNotEmpty)
+	})
+
+	t.Run("pays from a pocket", func(t *testing.T) {
+		store := newMemStore()
+		userID := store.addUser(idr(10000))
+		uc := NewPocketUsecase(store, store.repos().Pockets)
+
+		pocket, err := uc.Create(userID, "Holiday", "IDR")
+		require.NoError(t, err)
+		_, err = uc.Deposit(userID, pocket.ID, idr(10000))
+		require.NoError(t, err)
+
+		// The wallet itself is empty, so only the pocket can cover this.
+		_, err = newTestTransactionUsecase(store).Payment(userID, idr(2500), "hotel", PaymentOptions{})
+		assert.ErrorIs(t, err, ErrInsufficientBalance)
+
+		_, err = newTestTransactionUsecase(store).Payment(userID, idr(2500), "hotel", PaymentOptions{PocketID: pocket.ID})
+		require.NoError(t, err)
+		assert.Equal(t, idr(0), store.wallet(userID).Balance)
+		assert.Equal(t, idr(7500), store.pockets[pocket.ID].Balance)
+	})
+
+	t.Run("returns a failed or cancelled transfer to its pocket", func(t *testing.T) {
+		store := newMemStore()
+		userID := store.addUser(idr(10000))
+		toID := store.addUser(idr(0))
+		uc := NewPocketUsecase(store, store.repos().Pockets)
+		transfers := newTransactionUsecaseWith(store, noLimits(store), newTestFeeUsecase(t, store))
+
+		pocket, err := uc.Create(userID, "Holiday", "IDR")
+		require.NoError(t, err)
+		_, err = uc.Deposit(userID, pocket.ID, idr(10000))
+		require.NoError(t, err)
+
+		failed, err := transfers.Transfer(userID, toID, idr(3000), "rent", TransferOptions{PocketID: pocket.ID})
+		require.NoError(t, err)
+		assert.Equal(t, pocket.ID, *failed.PocketID)
+		assert.Equal(t, idr(4500), store.pockets[pocket.ID].Balance, "the amount and its fee came from the pocket")
+		require.NoError(t, transfers.FailTransfer(failed.ID, "bank offline"))
+
+		assert.Equal(t, idr(10000), store.pockets[pocket.ID].Balance)
+		assert.Equal(t, idr(0), store.wallet(userID).Balance)
+		assert.Equal(t, idr(0), store.wallet(userID).HeldBalance)
+
+		cancelled, err := transfers.Transfer(userID, toID, idr(3000), "rent", TransferOptions{PocketID: pocket.ID})
+		require.NoError(t, err)
+		_, err = transfers.CancelTransfer(userID, cancelled.ID)
+		require.NoError(t, err)
+
+		assert.Equal(t, idr(10000), store.pockets[pocket.ID].Balance)
+		assert.Equal(t, idr(0), store.wallet(userID).Balance)
+		assert.Equal(t, idr(0), store.wallet(userID).HeldBalance)
+	})
+
+	t.Run("leaves a failed transfer in the wallet once its pocket is gone", func(t *testing.T) {
+		store := newMemStore()
+		userID := store.addUser(idr(3000))
+		toID := store.addUser(idr(0))
+		uc := NewPocketUsecase(store, store.repos().Pockets)
+		transfers := newTestTransactionUsecase(store)
+
+		pocket, err := uc.Create(userID, "Holiday", "IDR")
+		require.NoError(t, err)
+		_, err = uc.Deposit(userID, pocket.ID, idr(3000))
+		require.NoError(t, err)
+
+		tx, err := transfers.Transfer(userID, toID, idr(3000), "rent", TransferOptions{PocketID: pocket.ID})
+		require.NoError(t, err)
+		require.NoError(t, uc.Delete(userID, pocket.ID))
+		require.NoError(t, transfers.FailTransfer(tx.ID, "bank offline"))
+
+		assert.Equal(t, idr(3000), store.wallet(userID).Balance)
+		assert.Equal(t, idr(0), store.wallet(userID).HeldBalance)
+	})
+
+	t.Run("hides other users' pockets", func(t *testing.T) {
+		store := newMemStore()
+		ownerID := store.addUser(idr(10000))
+		otherID := store.addUser(idr(10000))
+		uc := NewPocketUsecase(store, store.repos().Pockets)
+
+		pocket, err := uc.Create(ownerID, "Rent", "IDR")
+		require.NoError(t, err)
+
+		_, err = uc.Deposit(otherID, pocket.ID, idr(100))
+		assert.ErrorIs(t, err, ErrPocketNotFound)
+	})
+}
@@ -0,0 +1,231 @@
+package usecase
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+)
+
+type ReconciliationConfig struct {
+	// AutoFreeze freezes every wallet a run finds a discrepancy on.
+	AutoFreeze bool
+	BatchSize  int
+}
+
+// ReconciliationUsecase recomputes every wallet balance from the user's
+// transactions in that currency and compares it with wallets.balance and the
+// ledger.
+type ReconciliationUsecase struct {
+	uow                domain.UnitOfWork
+	userRepo           domain.UserRepository
+	reconciliationRepo domain.ReconciliationRepository
+	config             ReconciliationConfig
+}
+
+func NewReconciliationUsecase(
+	uow domain.UnitOfWork,
+	userRepo domain.UserRepository,
+	reconciliationRepo domain.ReconciliationRepository,
+	config ReconciliationConfig,
+) *ReconciliationUsecase {
+	return &ReconciliationUsecase{
+		uow:                uow,
+		userRepo:           userRepo,
+		reconciliationRepo: reconciliationRepo,
+		config:             config,
+	}
+}
+
+func (u *ReconciliationUsecase) Run() (*domain.ReconciliationRun, error) {
+	run := &domain.ReconciliationRun{
+		Status:    domain.ReconciliationStatusRunning,
+		StartedAt: time.Now(),
+	}
+	if err := u.reconciliationRepo.CreateRun(run); err != nil {
+		return nil, err
+	}
+
+	runErr := u.reconcileAll(run)
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Status = domain.ReconciliationStatusCompleted
+	if runErr != nil {
+		run.Status = domain.ReconciliationStatusFailed
+		run.Error = runErr.Error()
+	}
+
+	if err := u.reconciliationRepo.UpdateRun(run); err != nil {
+		return nil, err
+	}
+
+	return run, runErr
+}
+
+func (u *ReconciliationUsecase) ListRuns(limit int) ([]domain.ReconciliationRun, error) {
+	return u.reconciliationRepo.ListRuns(limit)
+}
+
+func (u *ReconciliationUsecase) GetRun(runID uuid.UUID) (*domain.ReconciliationRun, error) {
+	return u.reconciliationRepo.GetRun(runID)
+}
+
+func (u *ReconciliationUsecase) reconcileAll(run *domain.ReconciliationRun) error {
+	afterID := uuid.Nil
+	for {
+		users, err := u.userRepo.List(afterID, u.config.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			return nil
+		}
+
+		for _, user := range users {
+			discrepancies, frozen, err := u.reconcileUser(user.ID)
+			if err != nil {
+				return fmt.Errorf("failed to reconcile user %s: %w", user.ID, err)
+			}
+
+			for i := range discrepancies {
+				discrepancies[i].RunID = run.ID
+				discrepancies[i].WalletFrozen = frozen
+				if err := u.reconciliationRepo.CreateDiscrepancy(&discrepancies[i]); err != nil {
+					return err
+				}
+			}
+
+			run.UsersChecked++
+			run.DiscrepancyCount += len(discrepancies)
+		}
+
+		afterID = users[len(users)-1].ID
+	}
+}
+
+// reconcileUser checks a user's wallets while holding their user row lock.
+// Every balance change locks that row first, so the wallets, transactions and
+// ledger postings read here cannot be caught halfway through a transfer, and
+// a user is only frozen for a discrepancy that is still there when the lock
+// is held.
+func (u *ReconciliationUsecase) reconcileUser(userID uuid.UUID) (discrepancies []domain.ReconciliationDiscrepancy, frozen bool, err error) {
+	err = u.uow.Do(func(repos *domain.Repositories) error {
+		user, err := repos.Users.GetByIDForUpdate(userID)
+		if err != nil {
+			return err
+		}
+
+		discrepancies, err = findDiscrepancies(repos, user)
+		if err != nil {
+			return err
+		}
+
+		if len(discrepancies) == 0 || !u.config.AutoFreeze || user.Status == domain.UserStatusFrozen {
+			return nil
+		}
+
+		if err := repos.Users.UpdateStatus(user.ID, domain.UserStatusFrozen); err != nil {
+			return err
+		}
+		frozen = true
+
+		return notify(repos, user.ID, "Wallet frozen",
+			"Your wallet has been frozen while we review a balance discrepancy. Please contact support.")
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return discrepancies, frozen, nil
+}
+
+func findDiscrepancies(repos *domain.Repositories, user *domain.User) ([]domain.ReconciliationDiscrepancy, error) {
+	wallets, err := repos.Wallets.GetByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := repos.Transactions.GetByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]money.Money)
+	for _, wallet := range wallets {
+		balances[wallet.Currency] = wallet.Balance
+	}
+
+	settled := make(map[string][]domain.Transaction)
+	for _, tx := range transactions {
+		if tx.Status == domain.TransactionStatusSuccess && tx.SettledAt != nil {
+			currency := tx.Amount.Currency
+			settled[currency] = append(settled[currency], tx)
+			if _, ok := balances[currency]; !ok {
+				// Money moved in a currency the user has no wallet for.
+				balances[currency] = money.Zero(currency)
+			}
+		}
+	}
+
+	var discrepancies []domain.ReconciliationDiscrepancy
+	report := func(kind domain.DiscrepancyKind, expected, actual money.Money, txID *uuid.UUID, detail string) {
+		discrepancies = append(discrepancies, domain.ReconciliationDiscrepancy{
+			UserID:        user.ID,
+			Kind:          kind,
+			Expected:      expected,
+			Actual:        actual,
+			TransactionID: txID,
+			Detail:        detail,
+			CreatedAt:     time.Now(),
+		})
+	}
+
+	for _, currency := range slices.Sorted(maps.Keys(balances)) {
+		balance := balances[currency]
+		history := settled[currency]
+		slices.SortStableFunc(history, func(a, b domain.Transaction) int {
+			return a.SettledAt.Compare(*b.SettledAt)
+		})
+
+		expected := money.Zero(currency)
+		for _, tx := range history {
+			txID := tx.ID
+			if tx.BalanceBefore.Cmp(expected) != 0 {
+				report(domain.DiscrepancyChainBreak, expected, tx.BalanceBefore, &txID, "balance_before does not follow the previous balance_after")
+			}
+
+			var after money.Money
+			switch tx.Type {
+			case domain.TransactionTypeCredit:
+				after = tx.BalanceBefore.Add(tx.Amount)
+				expected = expected.Add(tx.Amount)
+			case domain.TransactionTypeDebit:
+				after = tx.BalanceBefore.Sub(tx.Amount)
+				expected = expected.Sub(tx.Amount)
+			}
+
+			if tx.BalanceAfter.Cmp(after) != 0 {
+				report(domain.DiscrepancyChainBreak, after, tx.BalanceAfter, &txID, "balance_after does not equal balance_before plus the amount")
+			}
+		}
+
+		if expected.Cmp(balance) != 0 {
+			report(domain.DiscrepancyBalanceMismatch, expected, balance, nil, "stored balance differs from successful credits minus debits")
+		}
+
+		ledger, err := ledgerBalance(repos.Ledger, user.ID, currency)
+		if err != nil {
+			return nil, err
+		}
+		if ledger.Cmp(balance) != 0 {
+			report(domain.DiscrepancyLedgerMismatch, ledger, balance, nil, "stored balance differs from ledger postings")
+		}
+	}
+
+	return discrepancies, nil
+}
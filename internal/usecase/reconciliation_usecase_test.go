@@ -0,0 +1,183 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type memReconciliationRepo struct {
+	runs          []domain.ReconciliationRun
+	discrepancies []domain.ReconciliationDiscrepancy
+}
+
+func (r *memReconciliationRepo) CreateRun(run *domain.ReconciliationRun) error {
+	run.ID = uuid.New()
+	r.runs = append(r.runs, *run)
+	return nil
+}
+
+func (r *memReconciliationRepo) UpdateRun(run *domain.ReconciliationRun) error {
+	for i := range r.runs {
+		if r.runs[i].ID == run.ID {
+			r.runs[i] = *run
+		}
+	}
+	return nil
+}
+
+func (r *memReconciliationRepo) CreateDiscrepancy(discrepancy *domain.ReconciliationDiscrepancy) error {
+	discrepancy.ID = uuid.New()
+	r.discrepancies = append(r.discrepancies, *discrepancy)
+	return nil
+}
+
+func (r *memReconciliationRepo) ListRuns(limit int) ([]domain.ReconciliationRun, error) {
+	return r.runs, nil
+}
+
+func (r *memReconciliationRepo) GetRun(id uuid.UUID) (*domain.ReconciliationRun, error) {
+	for _, run := range r.runs {
+		if run.ID == id {
+			run.Discrepancies = r.discrepancies
+			return &run, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func TestReconciliationUsecase_Run(t *testing.T) {
+	// setup gives two users a history built by the regular flows: top ups
+	// and a settled transfer between them.
+	setup := func(t *testing.T) (store *memStore, fromID, toID uuid.UUID) {
+		store = newMemStore()
+		fromID = store.addUser(idr(0))
+		toID = store.addUser(idr(0))
+		transactions := newTestTransactionUsecase(store)
+
+		_, err := transactions.TopUp(fromID, idr(10000), TopUpOptions{})
+		require.NoError(t, err)
+		_, err = transactions.TopUp(toID, idr(2000), TopUpOptions{})
+		require.NoError(t, err)
+		tx, err := transactions.Transfer(fromID, toID, idr(3000), "rent", TransferOptions{})
+		require.NoError(t, err)
+		require.NoError(t, transactions.ProcessTransfer(tx.ID))
+
+		return store, fromID, toID
+	}
+
+	run := func(t *testing.T, store *memStore, autoFreeze bool) (*domain.ReconciliationRun, *memReconciliationRepo) {
+		repo := &memReconciliationRepo{}
+		uc := NewReconciliationUsecase(store, store.repos().Users, repo, ReconciliationConfig{
+			AutoFreeze: autoFreeze,
+			BatchSize:  1,
+		})
+
+		result, err := uc.Run()
+		require.NoError(t, err)
+		assert.Equal(t, domain.ReconciliationStatusCompleted, result.Status)
+		return result, repo
+	}
+
+	kinds := func(discrepancies []domain.ReconciliationDiscrepancy) []domain.DiscrepancyKind {
+		var result []domain.DiscrepancyKind
+		for _, d := range discrepancies {
+			result = append(result, d.Kind)
+		}
+		return result
+	}
+
+	t.Run("finds nothing on healthy wallets", func(t *testing.T) {
+		store, fromID, toID := setup(t)
+		_, err := newTestTransactionUsecase(store).Transfer(toID, fromID, idr(1000), "in flight", TransferOptions{})
+		require.NoError(t, err)
+
+		result, repo := run(t, store, true)
+		assert.Equal(t, 2, result.UsersChecked)
+		assert.Zero(t, result.DiscrepancyCount)
+		assert.Empty(t, repo.discrepancies)
+		assert.NotEqual(t, domain.UserStatusFrozen, store.users[fromID].Status)
+		assert.NotEqual(t, domain.UserStatusFrozen, store.users[toID].Status)
+	})
+
+	t.Run("reports a broken balance chain", func(t *testing.T) {
+		store, fromID, _ := setup(t)
+		var broken uuid.UUID
+		for id, tx := range store.transactions {
+			if tx.UserID == fromID && tx.Type == domain.TransactionTypeDebit {
+				tx.BalanceAfter = tx.BalanceAfter.Add(idr(1))
+				store.transactions[id] = tx
+				broken = id
+			}
+		}
+
+		result, repo := run(t, store, false)
+		assert.Equal(t, 1, result.DiscrepancyCount)
+		require.Len(t, repo.discrepancies, 1)
+		d := repo.discrepancies[0]
+		assert.Equal(t, domain.DiscrepancyChainBreak, d.Kind)
+		assert.Equal(t, fromID, d.UserID)
+		require.NotNil(t, d.TransactionID)
+		assert.Equal(t, broken, *d.TransactionID)
+		assert.Equal(t, idr(7000), d.Expected)
+		assert.Equal(t, idr(7001), d.Actual)
+	})
+
+	t.Run("reports a wallet that disagrees with its transactions", func(t *testing.T) {
+		store, _, toID := setup(t)
+		store.setBalance(toID, idr(5500))
+
+		_, repo := run(t, store, false)
+		assert.Equal(t, []domain.DiscrepancyKind{domain.DiscrepancyBalanceMismatch, domain.DiscrepancyLedgerMismatch}, kinds(repo.discrepancies))
+		assert.Equal(t, idr(5000), repo.discrepancies[0].Expected)
+		assert.Equal(t, idr(5500), repo.discrepancies[0].Actual)
+	})
+
+	t.Run("reports a wallet that disagrees with the ledger", func(t *testing.T) {
+		store, _, toID := setup(t)
+		account := store.accounts[domain.UserAccountCode(toID)+"/IDR"]
+		store.postings = append(store.postings, domain.Posting{
+			ID:        uuid.New(),
+			AccountID: account.ID,
+			Amount:    money.New(-250, "IDR"),
+		})
+
+		_, repo := run(t, store, false)
+		assert.Equal(t, []domain.DiscrepancyKind{domain.DiscrepancyLedgerMismatch}, kinds(repo.discrepancies))
+		assert.Equal(t, idr(5250), repo.discrepancies[0].Expected)
+		assert.Equal(t, idr(5000), repo.discrepancies[0].Actual)
+	})
+
+	t.Run("freezes wallets with discrepancies when auto freeze is on", func(t *testing.T) {
+		store, fromID, toID := setup(t)
+		store.setBalance(toID, idr(5500))
+
+		_, repo := run(t, store, true)
+		assert.Equal(t, domain.UserStatusFrozen, store.users[toID].Status)
+		assert.NotEqual(t, domain.UserStatusFrozen, store.users[fromID].Status)
+		require.NotEmpty(t, repo.discrepancies)
+		for _, d := range repo.discrepancies {
+			assert.True(t, d.WalletFrozen)
+		}
+		require.Len(t, store.notifications, 1)
+		assert.Equal(t, toID, store.notifications[0].UserID)
+	})
+
+	t.Run("leaves wallets open when auto freeze is off", func(t *testing.T) {
+		store, _, toID := setup(t)
+		store.setBalance(toID, idr(5500))
+
+		_, repo := run(t, store, false)
+		assert.NotEqual(t, domain.UserStatusFrozen, store.users[toID].Status)
+		require.NotEmpty(t, repo.discrepancies)
+		for _, d := range repo.discrepancies {
+			assert.False(t, d.WalletFrozen)
+		}
+		assert.Empty(t, store.notifications)
+	})
+}
@@ -0,0 +1,349 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrNotRefundable       = errors.New("transaction cannot be refunded")
+	ErrRefundExceedsAmount = errors.New("refund exceeds the amount not yet refunded")
+	ErrRefundForbidden     = errors.New("only the recipient or an admin can refund this transaction")
+)
+
+// Refund is the result of refunding or reversing a transaction. Debit is the
+// leg taken back from the recipient of a transfer and is nil for payments,
+// whose money is returned from the merchant's balance. Fee is the share of
+// the original's fee given back with it, nil when there is none.
+type Refund struct {
+	Original *domain.Transaction `json:"original"`
+	Debit    *domain.Transaction `json:"debit,omitempty"`
+	Credit   *domain.Transaction `json:"credit"`
+	Fee      *domain.Transaction `json:"fee,omitempty"`
+}
+
+type RefundUsecase struct {
+	uow domain.UnitOfWork
+}
+
+func NewRefundUsecase(uow domain.UnitOfWork) *RefundUsecase {
+	return &RefundUsecase{uow: uow}
+}
+
+// Refund gives back amount, or everything not yet refunded when amount is
+// zero, of a settled payment or transfer to the user who paid it. Transfers
+// are refunded by their recipient and payments to a merchant by its owner;
+// any of them, and payments without a merchant, by an admin.
+func (u *RefundUsecase) Refund(actorID, transactionID uuid.UUID, amount money.Money, reason string) (*Refund, error) {
+	var refund *Refund
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		actor, err := repos.Users.GetByID(actorID)
+		if err != nil {
+			return err
+		}
+
+		original, err := lockRefundable(repos, transactionID)
+		if err != nil {
+			return err
+		}
+
+		ownsMerchant, err := isMerchantOwner(repos, original, actorID)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case actor.Role == domain.UserRoleAdmin:
+		case original.TargetUserID != nil && *original.TargetUserID == actorID:
+		case ownsMerchant:
+		case original.UserID == actorID:
+			return ErrRefundForbidden
+		default:
+			return ErrTransactionNotFound
+		}
+
+		refund, err = refundTransaction(repos, original, amount, domain.ReferenceTypeRefund, reason)
+		if err != nil {
+			return err
+		}
+
+		return notify(repos, original.UserID, "Refund received",
+			fmt.Sprintf("You were refunded %s %s.", refund.Credit.Amount, refund.Credit.Amount.Currency))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// Reverse takes a settled transfer back from its recipient in full, or what
+// is left of it after refunds. It fails when the recipient no longer has the
+// funds available.
+func (u *RefundUsecase) Reverse(transactionID uuid.UUID, reason string) (*Refund, error) {
+	var refund *Refund
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		original, err := lockRefundable(repos, transactionID)
+		if err != nil {
+			return err
+		}
+
+		if original.TargetUserID == nil {
+			return fmt.Errorf("%w: only transfers can be reversed", ErrNotRefundable)
+		}
+
+		refund, err = refundTransaction(repos, original, money.Money{}, domain.ReferenceTypeReversal, reason)
+		if err != nil {
+			return err
+		}
+
+		return notify(repos, *original.TargetUserID, "Transfer reversed",
+			fmt.Sprintf("A transfer of %s %s you received was reversed: %s.", refund.Credit.Amount, refund.Credit.Amount.Currency, reason))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// lockRefundable locks the payment or transfer debit a refund applies to. The
+// recipient's credit of a transfer resolves to the sender's debit.
+func lockRefundable(repos *domain.Repositories, transactionID uuid.UUID) (*domain.Transaction, error) {
+	tx, err := repos.Transactions.GetByID(transactionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if tx.Type == domain.TransactionTypeCredit && tx.ReferenceType == domain.ReferenceTypeTransfer {
+		transactionID = tx.ReferenceID
+	}
+
+	original, err := repos.Transactions.GetByIDForUpdate(transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if original.Type != domain.TransactionTypeDebit || original.Status != domain.TransactionStatusSuccess {
+		return nil, ErrNotRefundable
+	}
+
+	switch original.ReferenceType {
+	case "", domain.ReferenceTypeAuthorization:
+	default:
+		return nil, ErrNotRefundable
+	}
+
+	return original, nil
+}
+
+// isMerchantOwner tells whether userID owns the merchant original was paid to.
+func isMerchantOwner(repos *domain.Repositories, original *domain.Transaction, userID uuid.UUID) (bool, error) {
+	if original.MerchantID == nil {
+		return false, nil
+	}
+
+	merchant, err := repos.Merchants.GetByID(*original.MerchantID)
+	if err != nil {
+		return false, err
+	}
+	return merchant.OwnerID == userID, nil
+}
+
+// refundTransaction credits amount of a locked original back to its payer and
+// records it under refType. Transfers are taken back from the recipient,
+// payments from the merchant they were made to or otherwise from the
+// merchant payable account. A zero amount refunds whatever
+// is left. The payer also gets back the same share of the original's fee, and
+// is credited even when their wallet is frozen since the money only comes in.
+func refundTransaction(repos *domain.Repositories, original *domain.Transaction, amount money.Money, refType, reason string) (*Refund, error) {
+	refunded, err := refundedAmount(repos, original)
+	if err != nil {
+		return nil, err
+	}
+	remaining := original.Amount.Sub(refunded)
+
+	if amount.IsZero() {
+		amount = remaining
+	}
+	if !amount.SameCurrency(original.Amount) {
+		return nil, money.ErrCurrencyMismatch
+	}
+	if !remaining.IsPositive() || remaining.LessThan(amount) {
+		return nil, ErrRefundExceedsAmount
+	}
+
+	refund := &Refund{Original: original}
+	payerID := original.UserID
+	remarks := fmt.Sprintf("%s of %s", refType, original.ID)
+	if reason != "" {
+		remarks += ": " + reason
+	}
+
+	if original.TargetUserID != nil {
+		recipientID := *original.TargetUserID
+		if err := lockUsers(repos, payerID, recipientID); err != nil {
+			return nil, err
+		}
+
+		before, after, err := debitBalance(repos, recipientID, amount)
+		if err != nil {
+			return nil, err
+		}
+		refund.Debit = refundLeg(recipientID, domain.TransactionTypeDebit, amount, before, after, original.ID, refType, remarks)
+	}
+
+	before, after, err := returnBalance(repos, payerID, amount)
+	if err != nil {
+		return nil, err
+	}
+	refund.Credit = refundLeg(payerID, domain.TransactionTypeCredit, amount, before, after, original.ID, refType, remarks)
+
+	source := systemAccount(domain.AccountMerchantPayable, domain.AccountTypeLiability)
+	if original.MerchantID != nil {
+		if err := debitMerchant(repos, *original.MerchantID, amount); err != nil {
+			return nil, err
+		}
+		refund.Credit.MerchantID = original.MerchantID
+		refund.Credit.OrderReference = original.OrderReference
+		source = merchantAccount(*original.MerchantID)
+	}
+	if refund.Debit != nil {
+		if err := repos.Transactions.Create(refund.Debit); err != nil {
+			return nil, err
+		}
+		source = userAccount(refund.Debit.UserID)
+	}
+	if err := repos.Transactions.Create(refund.Credit); err != nil {
+		return nil, err
+	}
+
+	err = postJournal(repos, refund.Credit.ID, refType,
+		debitLine(source, amount),
+		creditLine(userAccount(payerID), amount),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	refund.Fee, err = refundFee(repos, original, refunded.Add(amount), remarks)
+	if err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// refundFee gives the payer of original back the share of its fee that
+// refunded, everything refunded so far including this refund, is of its
+// amount, less the fee already given back. Refunding the whole amount returns
+// the whole fee. The fee of a captured payment is the one charged on its
+// authorization.
+func refundFee(repos *domain.Repositories, original *domain.Transaction, refunded money.Money, remarks string) (*domain.Transaction, error) {
+	feeRef := original.ID
+	if original.ReferenceType == domain.ReferenceTypeAuthorization {
+		feeRef = original.ReferenceID
+	}
+
+	fees, err := repos.Transactions.GetByReference(feeRef, domain.ReferenceTypeFee)
+	if err != nil {
+		return nil, err
+	}
+
+	charged := money.Zero(original.Amount.Currency)
+	returned := money.Zero(original.Amount.Currency)
+	for _, feeTx := range fees {
+		if feeTx.UserID != original.UserID || !feeTx.Amount.SameCurrency(charged) {
+			continue
+		}
+		switch {
+		case feeTx.Type == domain.TransactionTypeDebit && feeTx.Status == domain.TransactionStatusSuccess:
+			charged = charged.Add(feeTx.Amount)
+		case feeTx.Type == domain.TransactionTypeCredit:
+			returned = returned.Add(feeTx.Amount)
+		}
+	}
+
+	due := prorate(charged, refunded, original.Amount).Sub(returned)
+	if !due.IsPositive() {
+		return nil, nil
+	}
+
+	before, after, err := returnBalance(repos, original.UserID, due)
+	if err != nil {
+		return nil, err
+	}
+
+	feeTx := refundLeg(original.UserID, domain.TransactionTypeCredit, due, before, after, feeRef, domain.ReferenceTypeFee, "fee "+remarks)
+	if err := repos.Transactions.Create(feeTx); err != nil {
+		return nil, err
+	}
+
+	err = postJournal(repos, feeTx.ID, "fee refund",
+		debitLine(systemAccount(domain.AccountFeeRevenue, domain.AccountTypeRevenue), due),
+		creditLine(userAccount(original.UserID), due),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return feeTx, nil
+}
+
+// prorate returns part/whole of total, rounded down.
+func prorate(total, part, whole money.Money) money.Money {
+	if !whole.IsPositive() {
+		return money.Zero(total.Currency)
+	}
+	minor := new(big.Int).Mul(big.NewInt(total.Minor), big.NewInt(part.Minor))
+	minor.Quo(minor, big.NewInt(whole.Minor))
+	return money.New(minor.Int64(), total.Currency)
+}
+
+// refundedAmount sums the refunds and reversals already credited to the
+// payer of original.
+func refundedAmount(repos *domain.Repositories, original *domain.Transaction) (money.Money, error) {
+	total := money.Zero(original.Amount.Currency)
+	for _, refType := range []string{domain.ReferenceTypeRefund, domain.ReferenceTypeReversal} {
+		legs, err := repos.Transactions.GetByReference(original.ID, refType)
+		if err != nil {
+			return money.Money{}, err
+		}
+		for _, leg := range legs {
+			if leg.Type == domain.TransactionTypeCredit && leg.UserID == original.UserID {
+				total = total.Add(leg.Amount)
+			}
+		}
+	}
+	return total, nil
+}
+
+func refundLeg(userID uuid.UUID, txType domain.TransactionType, amount, before, after money.Money, originalID uuid.UUID, refType, remarks string) *domain.Transaction {
+	now := time.Now()
+	return &domain.Transaction{
+		ID:            uuid.New(),
+		UserID:        userID,
+		Type:          txType,
+		Status:        domain.TransactionStatusSuccess,
+		Amount:        amount,
+		Remarks:       remarks,
+		BalanceBefore: before,
+		BalanceAfter:  after,
+		ReferenceID:   originalID,
+		ReferenceType: refType,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		SettledAt:     &now,
+	}
+}
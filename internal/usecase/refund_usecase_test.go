@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefundUsecase(t *testing.T) {
+	t.Run("caps partial refunds of a payment at its amount", func(t *testing.T) {
+		store := newMemStore()
+		payerID := store.addUser(idr(10000))
+		adminID := store.addUser(idr(0))
+		store.users[adminID] = domain.User{ID: adminID, Role: domain.UserRoleAdmin}
+		uc := NewRefundUsecase(store)
+
+		payment, err := newTestTransactionUsecase(store).Payment(payerID, idr(3000), "coffee", PaymentOptions{})
+		require.NoError(t, err)
+
+		_, err = uc.Refund(payerID, payment.ID, idr(1000), "")
+		assert.ErrorIs(t, err, ErrRefundForbidden)
+
+		refund, err := uc.Refund(adminID, payment.ID, idr(1000), "cold")
+		require.NoError(t, err)
+		assert.Nil(t, refund.Debit)
+		assert.Equal(t, payment.ID, refund.Credit.ReferenceID)
+		assert.Equal(t, domain.ReferenceTypeRefund, refund.Credit.ReferenceType)
+
+		_, err = uc.Refund(adminID, payment.ID, idr(2500), "")
+		assert.ErrorIs(t, err, ErrRefundExceedsAmount)
+
+		refund, err = uc.Refund(adminID, payment.ID, money.Money{}, "")
+		require.NoError(t, err)
+		assert.Equal(t, idr(2000), refund.Credit.Amount)
+		assert.Equal(t, idr(10000), store.wallet(payerID).Balance)
+
+		_, err = uc.Refund(adminID, payment.ID, money.Money{}, "")
+		assert.ErrorIs(t, err, ErrRefundExceedsAmount)
+	})
+
+	t.Run("gives back the fee in proportion to what is refunded", func(t *testing.T) {
+		store := newMemStore()
+		payerID := store.addUser(idr(200000))
+		adminID := store.addUser(idr(0))
+		store.users[adminID] = domain.User{ID: adminID, Role: domain.UserRoleAdmin}
+		repos := store.repos()
+		uc := NewRefundUsecase(store)
+
+		ledgerBefore, err := ledgerBalance(repos.Ledger, payerID, "IDR")
+		require.NoError(t, err)
+
+		payments := newTransactionUsecaseWith(store, noLimits(store), newTestFeeUsecase(t, store))
+		payment, err := payments.Payment(payerID, idr(100000), "groceries", PaymentOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, idr(199000-100000), store.wallet(payerID).Balance)
+
+		refund, err := uc.Refund(adminID, payment.ID, idr(25000), "")
+		require.NoError(t, err)
+		require.NotNil(t, refund.Fee)
+		assert.Equal(t, idr(250), refund.Fee.Amount)
+		assert.Equal(t, domain.TransactionTypeCredit, refund.Fee.Type)
+		assert.Equal(t, payment.ID, refund.Fee.ReferenceID)
+		assert.Equal(t, domain.ReferenceTypeFee, refund.Fee.ReferenceType)
+
+		refund, err = uc.Refund(adminID, payment.ID, money.Money{}, "")
+		require.NoError(t, err)
+		require.NotNil(t, refund.Fee)
+		assert.Equal(t, idr(750), refund.Fee.Amount)
+		assert.Equal(t, idr(200000), store.wallet(payerID).Balance)
+
+		ledgerAfter, err := ledgerBalance(repos.Ledger, payerID, "IDR")
+		require.NoError(t, err)
+		assert.Equal(t, ledgerBefore, ledgerAfter)
+	})
+
+	t.Run("credits a payer whose wallet is frozen", func(t *testing.T) {
+		store := newMemStore()
+		txID, fromID, toID := newPendingTransfer(t, store, idr(4000))
+		require.NoError(t, newTestTransactionUsecase(store).ProcessTransfer(txID))
+
+		sender := store.users[fromID]
+		sender.Status = domain.UserStatusFrozen
+		store.users[fromID] = sender
+
+		refund, err := NewRefundUsecase(store).Refund(toID, txID, money.Money{}, "")
+		require.NoError(t, err)
+		assert.Nil(t, refund.Fee)
+		assert.Equal(t, idr(10000), store.wallet(fromID).Balance)
+		assert.Equal(t, idr(500), store.wallet(toID).Balance)
+	})
+
+	t.Run("recipient refunds part of a transfer", func(t *testing.T) {
+		store := newMemStore()
+		txID, fromID, toID := newPendingTransfer(t, store, idr(4000))
+		require.NoError(t, newTestTransactionUsecase(store).ProcessTransfer(txID))
+		credit := store.transactionsByReference(txID, domain.ReferenceTypeTransfer)[0]
+
+		refund, err := NewRefundUsecase(store).Refund(toID, credit.ID, idr(1500), "overpaid")
+		require.NoError(t, err)
+		assert.Equal(t, txID, refund.Original.ID)
+		assert.Equal(t, toID, refund.Debit.UserID)
+		assert.Equal(t, idr(7500), store.wallet(fromID).Balance)
+		assert.Equal(t, idr(3000), store.wallet(toID).Balance)
+	})
+
+	t.Run("reverses what is left of a transfer only when the recipient has it", func(t *testing.T) {
+		store := newMemStore()
+		txID, fromID, toID := newPendingTransfer(t, store, idr(4000))
+		require.NoError(t, newTestTransactionUsecase(store).ProcessTransfer(txID))
+		uc := NewRefundUsecase(store)
+
+		store.setBalance(toID, idr(1000))
+		_, err := uc.Reverse(txID, "fraud")
+		assert.ErrorIs(t, err, ErrInsufficientBalance)
+		assert.Equal(t, idr(6000), store.wallet(fromID).Balance)
+
+		store.setBalance(toID, idr(4500))
+		refund, err := uc.Reverse(txID, "fraud")
+		require.NoError(t, err)
+		assert.Equal(t, domain.ReferenceTypeReversal, refund.Credit.ReferenceType)
+		assert.Equal(t, idr(10000), store.wallet(fromID).Balance)
+		assert.Equal(t, idr(500), store.wallet(toID).Balance)
+
+		_, err = uc.Reverse(txID, "fraud")
+		assert.ErrorIs(t, err, ErrRefundExceedsAmount)
+	})
+}
@@ -0,0 +1,372 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrScheduleInactive = errors.New("schedule is completed")
+)
+
+// errScheduleRunFailed is the failure recorded for an occurrence that failed
+// for a reason other than the transfer being refused. The error itself is
+// logged rather than shown to the user.
+var errScheduleRunFailed = errors.New("the transfer could not be processed")
+
+type ScheduleConfig struct {
+	BatchSize int
+}
+
+// ScheduleInput describes a new transfer schedule. Frequency defaults to
+// ONCE; EndAt and MaxOccurrences optionally end a recurring schedule.
+type ScheduleInput struct {
+	TargetUserID   uuid.UUID
+	Amount         money.Money
+	Remarks        string
+	Frequency      domain.ScheduleFrequency
+	StartAt        time.Time
+	EndAt          *time.Time
+	MaxOccurrences int
+}
+
+// ScheduleUsecase manages scheduled and recurring transfers. A periodic task
+// calls RunDue, which turns every due occurrence into a normal pending
+// transfer and records the outcome as a ScheduleRun.
+type ScheduleUsecase struct {
+	uow          domain.UnitOfWork
+	scheduleRepo domain.ScheduleRepository
+	limits       *LimitsUsecase
+	fees         *FeeUsecase
+	config       ScheduleConfig
+}
+
+func NewScheduleUsecase(uow domain.UnitOfWork, scheduleRepo domain.ScheduleRepository, limits *LimitsUsecase, fees *FeeUsecase, config ScheduleConfig) *ScheduleUsecase {
+	return &ScheduleUsecase{
+		uow:          uow,
+		scheduleRepo: scheduleRepo,
+		limits:       limits,
+		fees:         fees,
+		config:       config,
+	}
+}
+
+func (u *ScheduleUsecase) Create(userID uuid.UUID, in ScheduleInput) (*domain.TransferSchedule, error) {
+	if in.Frequency == "" {
+		in.Frequency = domain.ScheduleFrequencyOnce
+	}
+	switch in.Frequency {
+	case domain.ScheduleFrequencyOnce, domain.ScheduleFrequencyDaily, domain.ScheduleFrequencyWeekly, domain.ScheduleFrequencyMonthly:
+	default:
+		return nil, fmt.Errorf("unknown frequency %q", in.Frequency)
+	}
+	if err := checkAmount(in.Amount); err != nil {
+		return nil, err
+	}
+	if in.TargetUserID == userID {
+		return nil, ErrSelfTransfer
+	}
+
+	now := time.Now()
+	if !in.StartAt.After(now) {
+		return nil, errors.New("start date must be in the future")
+	}
+	if in.EndAt != nil && in.EndAt.Before(in.StartAt) {
+		return nil, errors.New("end date must not be before the start date")
+	}
+	if in.MaxOccurrences < 0 {
+		return nil, errors.New("occurrence count must not be negative")
+	}
+
+	schedule := &domain.TransferSchedule{
+		ID:             uuid.New(),
+		UserID:         userID,
+		TargetUserID:   in.TargetUserID,
+		Amount:         in.Amount,
+		Remarks:        in.Remarks,
+		Frequency:      in.Frequency,
+		StartAt:        in.StartAt,
+		EndAt:          in.EndAt,
+		MaxOccurrences: in.MaxOccurrences,
+		Status:         domain.ScheduleStatusActive,
+		NextRunAt:      &in.StartAt,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		if _, err := repos.Users.GetByID(in.TargetUserID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRecipientNotFound
+			}
+			return err
+		}
+		return repos.Schedules.Create(schedule)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (u *ScheduleUsecase) GetSchedules(userID uuid.UUID) ([]domain.TransferSchedule, error) {
+	return u.scheduleRepo.GetByUserID(userID)
+}
+
+func (u *ScheduleUsecase) GetRuns(userID, scheduleID uuid.UUID) ([]domain.ScheduleRun, error) {
+	schedule, err := u.scheduleRepo.GetByID(scheduleID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && schedule.UserID != userID) {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return u.scheduleRepo.GetRuns(scheduleID)
+}
+
+// Pause stops a schedule from running until it is resumed.
+func (u *ScheduleUsecase) Pause(userID, scheduleID uuid.UUID) (*domain.TransferSchedule, error) {
+	return u.update(userID, scheduleID, func(schedule *domain.TransferSchedule, now time.Time) {
+		schedule.Status = domain.ScheduleStatusPaused
+	})
+}
+
+// Resume restarts a paused schedule. Recurring occurrences that fell due
+// while it was paused are skipped.
+func (u *ScheduleUsecase) Resume(userID, scheduleID uuid.UUID) (*domain.TransferSchedule, error) {
+	return u.update(userID, scheduleID, func(schedule *domain.TransferSchedule, now time.Time) {
+		schedule.Status = domain.ScheduleStatusActive
+		if schedule.Frequency != domain.ScheduleFrequencyOnce {
+			scheduleNext(schedule, now)
+		}
+	})
+}
+
+func (u *ScheduleUsecase) Delete(userID, scheduleID uuid.UUID) error {
+	return u.uow.Do(func(repos *domain.Repositories) error {
+		schedule, err := lockSchedule(repos, userID, scheduleID)
+		if err != nil {
+			return err
+		}
+		return repos.Schedules.Delete(schedule.ID)
+	})
+}
+
+func (u *ScheduleUsecase) update(userID, scheduleID uuid.UUID, fn func(schedule *domain.TransferSchedule, now time.Time)) (*domain.TransferSchedule, error) {
+	var schedule *domain.TransferSchedule
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		var err error
+		schedule, err = lockSchedule(repos, userID, scheduleID)
+		if err != nil {
+			return err
+		}
+		if schedule.Status == domain.ScheduleStatusCompleted {
+			return ErrScheduleInactive
+		}
+
+		now := time.Now()
+		fn(schedule, now)
+		schedule.UpdatedAt = now
+		return repos.Schedules.Update(schedule)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// RunDue runs every due occurrence and returns the runs it recorded. An
+// occurrence that fails unexpectedly is logged and recorded as failed, so it
+// neither holds up the rest of the batch nor comes back in the next one.
+func (u *ScheduleUsecase) RunDue() ([]domain.ScheduleRun, error) {
+	now := time.Now()
+	schedules, err := u.scheduleRepo.GetDue(now, u.config.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []domain.ScheduleRun
+	for _, schedule := range schedules {
+		run, err := u.runOccurrence(schedule.ID, now)
+		if err != nil {
+			log.Printf("Failed to run transfer schedule %s: %s", schedule.ID, err)
+			run, err = u.recordFailure(schedule.ID, now, errScheduleRunFailed)
+		}
+		if err != nil {
+			log.Printf("Failed to record the failed run of transfer schedule %s: %s", schedule.ID, err)
+			continue
+		}
+		if run != nil {
+			runs = append(runs, *run)
+		}
+	}
+
+	return runs, nil
+}
+
+// runOccurrence creates the transfer of a due occurrence, recording the run
+// and advancing the schedule in the same database transaction. When the
+// transfer is refused the attempt is rolled back and the failure is recorded
+// instead, so the occurrence is not retried.
+func (u *ScheduleUsecase) runOccurrence(scheduleID uuid.UUID, now time.Time) (*domain.ScheduleRun, error) {
+	var run *domain.ScheduleRun
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		schedule, due, err := lockDueSchedule(repos, scheduleID, now)
+		if err != nil || !due {
+			return err
+		}
+
+		tx, err := createTransfer(repos, u.limits, u.fees, schedule.UserID, schedule.TargetUserID, schedule.Amount, schedule.Remarks, TransferOptions{}, false)
+		if err != nil {
+			if isScheduleRejection(err) {
+				return &transferRejection{err}
+			}
+			return err
+		}
+
+		run = &domain.ScheduleRun{
+			ScheduleID:    schedule.ID,
+			ScheduledFor:  *schedule.NextRunAt,
+			Status:        domain.ScheduleRunStatusSuccess,
+			TransactionID: &tx.ID,
+			CreatedAt:     now,
+		}
+		return recordRun(repos, schedule, run, now)
+	})
+
+	var rejection *transferRejection
+	if !errors.As(err, &rejection) {
+		return run, err
+	}
+
+	return u.recordFailure(scheduleID, now, rejection)
+}
+
+// recordFailure records a failed run of a due occurrence in a fresh unit of
+// work, advances the schedule past it and notifies the user.
+func (u *ScheduleUsecase) recordFailure(scheduleID uuid.UUID, now time.Time, reason error) (*domain.ScheduleRun, error) {
+	var run *domain.ScheduleRun
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		schedule, due, err := lockDueSchedule(repos, scheduleID, now)
+		if err != nil || !due {
+			return err
+		}
+
+		run = &domain.ScheduleRun{
+			ScheduleID:    schedule.ID,
+			ScheduledFor:  *schedule.NextRunAt,
+			Status:        domain.ScheduleRunStatusFailed,
+			FailureReason: reason.Error(),
+			CreatedAt:     now,
+		}
+		if err := recordRun(repos, schedule, run, now); err != nil {
+			return err
+		}
+
+		return notify(repos, schedule.UserID, "Scheduled transfer failed",
+			fmt.Sprintf("Your scheduled transfer of %s %s could not be made: %s.", schedule.Amount, schedule.Amount.Currency, reason.Error()))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
+func lockSchedule(repos *domain.Repositories, userID, scheduleID uuid.UUID) (*domain.TransferSchedule, error) {
+	schedule, err := repos.Schedules.GetByIDForUpdate(scheduleID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if schedule.UserID != userID {
+		return nil, ErrScheduleNotFound
+	}
+
+	return schedule, nil
+}
+
+// lockDueSchedule locks a schedule and reports whether it is still active and
+// due, since it may have been paused, deleted or run since it was listed.
+func lockDueSchedule(repos *domain.Repositories, scheduleID uuid.UUID, now time.Time) (*domain.TransferSchedule, bool, error) {
+	schedule, err := repos.Schedules.GetByIDForUpdate(scheduleID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	due := schedule.Status == domain.ScheduleStatusActive && schedule.NextRunAt != nil && !schedule.NextRunAt.After(now)
+	return schedule, due, nil
+}
+
+func recordRun(repos *domain.Repositories, schedule *domain.TransferSchedule, run *domain.ScheduleRun, now time.Time) error {
+	if err := repos.Schedules.CreateRun(run); err != nil {
+		return err
+	}
+
+	schedule.RunCount++
+	schedule.NextIndex++
+	scheduleNext(schedule, now)
+	schedule.UpdatedAt = now
+	return repos.Schedules.Update(schedule)
+}
+
+// scheduleNext moves NextRunAt to the first occurrence after now, skipping
+// occurrences that were missed, and completes the schedule when none is left.
+func scheduleNext(schedule *domain.TransferSchedule, now time.Time) {
+	if schedule.Frequency == domain.ScheduleFrequencyOnce && schedule.RunCount > 0 ||
+		schedule.MaxOccurrences > 0 && schedule.RunCount >= schedule.MaxOccurrences {
+		completeSchedule(schedule)
+		return
+	}
+
+	next := schedule.OccurrenceAt(schedule.NextIndex)
+	for schedule.Frequency != domain.ScheduleFrequencyOnce && !next.After(now) {
+		schedule.NextIndex++
+		next = schedule.OccurrenceAt(schedule.NextIndex)
+	}
+
+	if schedule.EndAt != nil && next.After(*schedule.EndAt) {
+		completeSchedule(schedule)
+		return
+	}
+
+	schedule.NextRunAt = &next
+}
+
+func completeSchedule(schedule *domain.TransferSchedule) {
+	schedule.Status = domain.ScheduleStatusCompleted
+	schedule.NextRunAt = nil
+}
+
+// isScheduleRejection reports whether a transfer was refused for a reason
+// that retrying the occurrence will not fix.
+func isScheduleRejection(err error) bool {
+	switch {
+	case errors.Is(err, ErrInsufficientBalance),
+		errors.Is(err, ErrWalletFrozen),
+		errors.Is(err, ErrRecipientNotFound),
+		errors.Is(err, ErrSelfTransfer),
+		errors.Is(err, ErrLimitExceeded),
+		errors.Is(err, money.ErrCurrencyMismatch):
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestScheduleUsecase(store *memStore) *ScheduleUsecase {
+	return NewScheduleUsecase(store, store.repos().Schedules, noLimits(store), noFees(store), ScheduleConfig{BatchSize: 10})
+}
+
+func TestTransferSchedule_OccurrenceAt(t *testing.T) {
+	schedule := domain.TransferSchedule{
+		Frequency: domain.ScheduleFrequencyMonthly,
+		StartAt:   time.Date(2024, time.January, 31, 9, 0, 0, 0, time.UTC),
+	}
+
+	assert.Equal(t, time.Date(2024, time.February, 29, 9, 0, 0, 0, time.UTC), schedule.OccurrenceAt(1))
+	assert.Equal(t, time.Date(2024, time.March, 31, 9, 0, 0, 0, time.UTC), schedule.OccurrenceAt(2))
+	assert.Equal(t, time.Date(2024, time.April, 30, 9, 0, 0, 0, time.UTC), schedule.OccurrenceAt(3))
+}
+
+func TestScheduleUsecase_RunDue(t *testing.T) {
+	// makeDue moves a schedule's next occurrence into the past.
+	makeDue := func(store *memStore, schedule *domain.TransferSchedule) {
+		stored := store.schedules[schedule.ID]
+		due := time.Now().Add(-time.Minute)
+		stored.StartAt = due
+		stored.NextRunAt = &due
+		store.schedules[schedule.ID] = stored
+	}
+
+	t.Run("turns each occurrence into a pending transfer until the count is reached", func(t *testing.T) {
+		store := newMemStore()
+		fromID := store.addUser(idr(10000))
+		toID := store.addUser(idr(0))
+		uc := newTestScheduleUsecase(store)
+
+		schedule, err := uc.Create(fromID, ScheduleInput{
+			TargetUserID:   toID,
+			Amount:         idr(1000),
+			Remarks:        "allowance",
+			Frequency:      domain.ScheduleFrequencyDaily,
+			StartAt:        time.Now().Add(time.Hour),
+			MaxOccurrences: 2,
+		})
+		require.NoError(t, err)
+
+		runs, err := uc.RunDue()
+		require.NoError(t, err)
+		assert.Empty(t, runs)
+
+		makeDue(store, schedule)
+		runs, err = uc.RunDue()
+		require.NoError(t, err)
+		require.Len(t, runs, 1)
+		assert.Equal(t, domain.ScheduleRunStatusSuccess, runs[0].Status)
+		assert.Equal(t, domain.TransactionStatusPending, store.transactions[*runs[0].TransactionID].Status)
+		assert.Equal(t, idr(1000), store.wallet(fromID).HeldBalance)
+
+		stored := store.schedules[schedule.ID]
+		assert.Equal(t, domain.ScheduleStatusActive, stored.Status)
+		assert.True(t, stored.NextRunAt.After(time.Now()))
+
+		makeDue(store, schedule)
+		_, err = uc.RunDue()
+		require.NoError(t, err)
+		stored = store.schedules[schedule.ID]
+		assert.Equal(t, domain.ScheduleStatusCompleted, stored.Status)
+		assert.Nil(t, stored.NextRunAt)
+		assert.Len(t, store.runs, 2)
+	})
+
+	t.Run("records a failed occurrence and moves on", func(t *testing.T) {
+		store := newMemStore()
+		fromID := store.addUser(idr(500))
+		toID := store.addUser(idr(0))
+		uc := newTestScheduleUsecase(store)
+
+		schedule, err := uc.Create(fromID, ScheduleInput{
+			TargetUserID: toID,
+			Amount:       idr(1000),
+			Remarks:      "rent",
+			Frequency:    domain.ScheduleFrequencyWeekly,
+			StartAt:      time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+		makeDue(store, schedule)
+
+		runs, err := uc.RunDue()
+		require.NoError(t, err)
+		require.Len(t, runs, 1)
+		assert.Equal(t, domain.ScheduleRunStatusFailed, runs[0].Status)
+		assert.Equal(t, ErrInsufficientBalance.Error(), runs[0].FailureReason)
+		assert.Empty(t, store.transactions)
+		assert.Len(t, store.notifications, 1)
+		assert.Equal(t, domain.ScheduleStatusActive, store.schedules[schedule.ID].Status)
+		assert.Equal(t, 1, store.schedules[schedule.ID].RunCount)
+	})
+
+	t.Run("records an unexpected failure and runs the rest of the batch", func(t *testing.T) {
+		store := newMemStore()
+		brokenID := store.addUser(idr(10000))
+		fromID := store.addUser(idr(10000))
+		toID := store.addUser(idr(0))
+		uc := newTestScheduleUsecase(store)
+
+		var schedules []*domain.TransferSchedule
+		for _, userID := range []uuid.UUID{brokenID, fromID} {
+			schedule, err := uc.Create(userID, ScheduleInput{
+				TargetUserID: toID,
+				Amount:       idr(1000),
+				Remarks:      "rent",
+				Frequency:    domain.ScheduleFrequencyWeekly,
+				StartAt:      time.Now().Add(time.Hour),
+			})
+			require.NoError(t, err)
+			makeDue(store, schedule)
+			schedules = append(schedules, schedule)
+		}
+		// Locking a user that is gone fails with an error no transfer
+		// rejection covers.
+		delete(store.users, brokenID)
+
+		runs, err := uc.RunDue()
+		require.NoError(t, err)
+		require.Len(t, runs, 2)
+		for _, run := range runs {
+			switch run.ScheduleID {
+			case schedules[0].ID:
+				assert.Equal(t, domain.ScheduleRunStatusFailed, run.Status)
+				assert.Equal(t, errScheduleRunFailed.Error(), run.FailureReason)
+			case schedules[1].ID:
+				assert.Equal(t, domain.ScheduleRunStatusSuccess, run.Status)
+			}
+		}
+		assert.Equal(t, idr(1000), store.wallet(fromID).HeldBalance)
+		assert.True(t, store.schedules[schedules[0].ID].NextRunAt.After(time.Now()), "the failed occurrence is not retried")
+	})
+
+	t.Run("skips paused schedules", func(t *testing.T) {
+		store := newMemStore()
+		fromID := store.addUser(idr(10000))
+		toID := store.addUser(idr(0))
+		uc := newTestScheduleUsecase(store)
+
+		schedule, err := uc.Create(fromID, ScheduleInput{
+			TargetUserID: toID,
+			Amount:       idr(1000),
+			Remarks:      "gift",
+			StartAt:      time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+		makeDue(store, schedule)
+
+		_, err = uc.Pause(fromID, schedule.ID)
+		require.NoError(t, err)
+		runs, err := uc.RunDue()
+		require.NoError(t, err)
+		assert.Empty(t, runs)
+
+		_, err = uc.Resume(fromID, schedule.ID)
+		require.NoError(t, err)
+		runs, err = uc.RunDue()
+		require.NoError(t, err)
+		require.Len(t, runs, 1)
+		assert.Equal(t, domain.ScheduleStatusCompleted, store.schedules[schedule.ID].Status)
+	})
+}
@@ -0,0 +1,310 @@
+package usecase
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSettlementNotFound = errors.New("settlement not found")
+	ErrSettlementPaid     = errors.New("settlement is already paid")
+)
+
+type SettlementConfig struct {
+	// FeeRateBps is the share of a batch's gross payments kept as the
+	// settlement fee, in basis points.
+	FeeRateBps int64
+	BatchSize  int
+}
+
+// SettlementUsecase pays out what merchants have collected. Each run makes at
+// most one batch per merchant, which takes the net amount from the
+// merchant's balance into payout in transit until an admin confirms the bank
+// transfer.
+type SettlementUsecase struct {
+	uow            domain.UnitOfWork
+	merchantRepo   domain.MerchantRepository
+	settlementRepo domain.SettlementRepository
+	config         SettlementConfig
+}
+
+func NewSettlementUsecase(uow domain.UnitOfWork, merchantRepo domain.MerchantRepository, settlementRepo domain.SettlementRepository, config SettlementConfig) *SettlementUsecase {
+	return &SettlementUsecase{
+		uow:            uow,
+		merchantRepo:   merchantRepo,
+		settlementRepo: settlementRepo,
+		config:         config,
+	}
+}
+
+// RunSettlements batches what every merchant received before now. Merchants
+// with nothing to pay out are skipped. A merchant that cannot be settled is
+// logged and left for the next run without holding up the others.
+func (u *SettlementUsecase) RunSettlements() ([]domain.SettlementBatch, error) {
+	cutoff := time.Now()
+	var batches []domain.SettlementBatch
+
+	afterID := uuid.Nil
+	for {
+		merchants, err := u.merchantRepo.List(afterID, u.config.BatchSize)
+		if err != nil {
+			return batches, err
+		}
+		if len(merchants) == 0 {
+			return batches, nil
+		}
+
+		for _, merchant := range merchants {
+			batch, err := u.settleMerchant(merchant.ID, cutoff)
+			if err != nil {
+				log.Printf("Failed to settle merchant %s: %s", merchant.ID, err)
+				continue
+			}
+			if batch != nil {
+				batches = append(batches, *batch)
+			}
+		}
+
+		afterID = merchants[len(merchants)-1].ID
+	}
+}
+
+// settleMerchant makes the batch of one merchant under the merchant's row
+// lock, so no payment or refund can slip in between the items and the
+// balance change. It returns nil when the net amount would not be positive;
+// the items then wait for the next run.
+func (u *SettlementUsecase) settleMerchant(merchantID uuid.UUID, cutoff time.Time) (*domain.SettlementBatch, error) {
+	var batch *domain.SettlementBatch
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		merchant, err := lockMerchant(repos, merchantID)
+		if err != nil {
+			return err
+		}
+
+		transactions, err := repos.Settlements.GetUnsettled(merchantID, cutoff)
+		if err != nil {
+			return err
+		}
+		if len(transactions) == 0 {
+			return nil
+		}
+
+		currency := merchant.Balance.Currency
+		now := time.Now()
+		b := &domain.SettlementBatch{
+			ID:                      uuid.New(),
+			MerchantID:              merchantID,
+			Status:                  domain.SettlementStatusInTransit,
+			Gross:                   money.Zero(currency),
+			Refunds:                 money.Zero(currency),
+			SettlementBankCode:      merchant.SettlementBankCode,
+			SettlementAccountNumber: merchant.SettlementAccountNumber,
+			SettlementAccountName:   merchant.SettlementAccountName,
+			CutoffAt:                cutoff,
+			CreatedAt:               now,
+		}
+
+		items := make([]domain.SettlementItem, 0, len(transactions))
+		for _, tx := range transactions {
+			item := domain.SettlementItem{
+				ID:             uuid.New(),
+				BatchID:        b.ID,
+				TransactionID:  tx.ID,
+				Amount:         tx.Amount,
+				OrderReference: tx.OrderReference,
+				TransactedAt:   *tx.SettledAt,
+			}
+
+			switch {
+			case tx.Type == domain.TransactionTypeDebit && tx.ReferenceType == "":
+				item.Type = domain.SettlementItemPayment
+				b.Gross = b.Gross.Add(tx.Amount)
+				b.PaymentCount++
+			case tx.Type == domain.TransactionTypeCredit && tx.ReferenceType == domain.ReferenceTypeRefund:
+				item.Type = domain.SettlementItemRefund
+				b.Refunds = b.Refunds.Add(tx.Amount)
+				b.RefundCount++
+			default:
+				continue
+			}
+			items = append(items, item)
+		}
+
+		b.Fee = money.New(applyBps(b.Gross.Minor, u.config.FeeRateBps), currency)
+		collected := b.Gross.Sub(b.Refunds)
+		b.Net = collected.Sub(b.Fee)
+		if !b.Net.IsPositive() {
+			return nil
+		}
+		if merchant.Balance.LessThan(collected) {
+			return fmt.Errorf("merchant balance %s is less than the %s to settle", merchant.Balance, collected)
+		}
+
+		if err := repos.Merchants.UpdateBalance(merchantID, merchant.Balance.Sub(collected)); err != nil {
+			return err
+		}
+		if err := repos.Merchants.UpdatePayoutInTransit(merchantID, merchant.PayoutInTransit.Add(b.Net)); err != nil {
+			return err
+		}
+
+		if err := repos.Settlements.CreateBatch(b); err != nil {
+			return err
+		}
+		if err := repos.Settlements.CreateItems(items); err != nil {
+			return err
+		}
+
+		lines := []ledgerLine{
+			debitLine(merchantAccount(merchantID), collected),
+			creditLine(systemAccount(domain.AccountPayoutInTransit, domain.AccountTypeLiability), b.Net),
+		}
+		if b.Fee.IsPositive() {
+			lines = append(lines, creditLine(systemAccount(domain.AccountFeeRevenue, domain.AccountTypeRevenue), b.Fee))
+		}
+		if err := postJournal(repos, b.ID, "settlement", lines...); err != nil {
+			return err
+		}
+
+		batch = b
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// MarkPaid records that the bank transfer of a batch arrived at the merchant.
+func (u *SettlementUsecase) MarkPaid(batchID uuid.UUID) (*domain.SettlementBatch, error) {
+	var batch *domain.SettlementBatch
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		var err error
+		batch, err = repos.Settlements.GetBatchForUpdate(batchID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSettlementNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if batch.Status != domain.SettlementStatusInTransit {
+			return ErrSettlementPaid
+		}
+
+		merchant, err := lockMerchant(repos, batch.MerchantID)
+		if err != nil {
+			return err
+		}
+		if err := repos.Merchants.UpdatePayoutInTransit(merchant.ID, merchant.PayoutInTransit.Sub(batch.Net)); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		batch.Status = domain.SettlementStatusPaid
+		batch.PaidAt = &now
+		if err := repos.Settlements.UpdateBatch(batch); err != nil {
+			return err
+		}
+
+		return postJournal(repos, batch.ID, "settlement payout",
+			debitLine(systemAccount(domain.AccountPayoutInTransit, domain.AccountTypeLiability), batch.Net),
+			creditLine(systemAccount(domain.AccountCashInClearing, domain.AccountTypeAsset), batch.Net),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// GetBatches lists the settlements of one of the user's merchants.
+func (u *SettlementUsecase) GetBatches(ownerID, merchantID uuid.UUID) ([]domain.SettlementBatch, error) {
+	if _, err := ownedMerchant(u.merchantRepo, ownerID, merchantID); err != nil {
+		return nil, err
+	}
+	return u.settlementRepo.GetBatchesByMerchantID(merchantID)
+}
+
+// GetBatch returns a settlement of one of the user's merchants.
+func (u *SettlementUsecase) GetBatch(ownerID, batchID uuid.UUID) (*domain.SettlementBatch, error) {
+	batch, err := u.settlementRepo.GetBatch(batchID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSettlementNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := ownedMerchant(u.merchantRepo, ownerID, batch.MerchantID); err != nil {
+		return nil, ErrSettlementNotFound
+	}
+
+	return batch, nil
+}
+
+// WriteReport writes the settlement report of a batch as CSV: one row per
+// payment and refund followed by the totals.
+func (u *SettlementUsecase) WriteReport(w io.Writer, batchID uuid.UUID) error {
+	batch, err := u.settlementRepo.GetBatch(batchID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrSettlementNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	items, err := u.settlementRepo.GetItems(batchID)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	_ = out.Write([]string{"settlement_id", "transaction_id", "type", "order_reference", "transacted_date", "amount", "currency"})
+	for _, item := range items {
+		amount := item.Amount
+		if item.Type == domain.SettlementItemRefund {
+			amount = amount.Neg()
+		}
+		_ = out.Write([]string{
+			batch.ID.String(),
+			item.TransactionID.String(),
+			string(item.Type),
+			item.OrderReference,
+			item.TransactedAt.Format(time.RFC3339),
+			amount.String(),
+			amount.Currency,
+		})
+	}
+
+	for _, total := range []struct {
+		name   string
+		amount money.Money
+	}{
+		{"GROSS", batch.Gross},
+		{"REFUNDS", batch.Refunds.Neg()},
+		{"FEE", batch.Fee.Neg()},
+		{"NET", batch.Net},
+	} {
+		_ = out.Write([]string{batch.ID.String(), "", total.name, "", batch.CutoffAt.Format(time.RFC3339), total.amount.String(), total.amount.Currency})
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// applyBps returns bps basis points of minor, rounded half up, without
+// overflowing on large amounts.
+func applyBps(minor, bps int64) int64 {
+	return minor/10000*bps + (minor%10000*bps+5000)/10000
+}
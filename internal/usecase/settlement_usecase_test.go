@@ -0,0 +1,102 @@
+package usecase
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSettlementUsecase(store *memStore) *SettlementUsecase {
+	return NewSettlementUsecase(store, store.repos().Merchants, store.repos().Settlements, SettlementConfig{FeeRateBps: 100, BatchSize: 10})
+}
+
+func TestSettlementUsecase(t *testing.T) {
+	store := newMemStore()
+	_, merchant := newTestMerchant(t, store)
+	userID := store.addUser(idr(100000))
+	payments := newTestTransactionUsecase(store)
+	uc := newTestSettlementUsecase(store)
+
+	first, err := payments.Payment(userID, idr(2500), "coffee", PaymentOptions{MerchantID: merchant.ID, OrderReference: "ORDER-1"})
+	require.NoError(t, err)
+	_, err = payments.Payment(userID, idr(1500), "cake", PaymentOptions{MerchantID: merchant.ID, OrderReference: "ORDER-2"})
+	require.NoError(t, err)
+	_, err = NewRefundUsecase(store).Refund(merchant.OwnerID, first.ID, idr(500), "cold")
+	require.NoError(t, err)
+
+	batches, err := uc.RunSettlements()
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+
+	batch := batches[0]
+	assert.Equal(t, domain.SettlementStatusInTransit, batch.Status)
+	assert.Equal(t, 2, batch.PaymentCount)
+	assert.Equal(t, 1, batch.RefundCount)
+	assert.Equal(t, idr(4000), batch.Gross)
+	assert.Equal(t, idr(500), batch.Refunds)
+	assert.Equal(t, idr(40), batch.Fee)
+	assert.Equal(t, idr(3460), batch.Net)
+	assert.Equal(t, idr(0), store.merchants[merchant.ID].Balance)
+	assert.Equal(t, idr(3460), store.merchants[merchant.ID].PayoutInTransit)
+
+	batches, err = uc.RunSettlements()
+	require.NoError(t, err)
+	assert.Empty(t, batches, "settled payments are not batched again")
+
+	var report bytes.Buffer
+	require.NoError(t, uc.WriteReport(&report, batch.ID))
+	assert.Contains(t, report.String(), "PAYMENT,ORDER-1,")
+	assert.Contains(t, report.String(), ",REFUND,ORDER-1,")
+	assert.Contains(t, report.String(), ",NET,,")
+
+	_, err = uc.GetBatch(userID, batch.ID)
+	assert.ErrorIs(t, err, ErrSettlementNotFound)
+
+	paid, err := uc.MarkPaid(batch.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.SettlementStatusPaid, paid.Status)
+	assert.Equal(t, idr(0), store.merchants[merchant.ID].PayoutInTransit)
+
+	_, err = uc.MarkPaid(batch.ID)
+	assert.ErrorIs(t, err, ErrSettlementPaid)
+}
+
+func TestSettlementUsecase_FailingMerchant(t *testing.T) {
+	store := newMemStore()
+	_, first := newTestMerchant(t, store)
+	_, second := newTestMerchant(t, store)
+	if bytes.Compare(first.ID[:], second.ID[:]) > 0 {
+		first, second = second, first
+	}
+	userID := store.addUser(idr(100000))
+	payments := newTestTransactionUsecase(store)
+	uc := newTestSettlementUsecase(store)
+
+	for _, merchant := range []*domain.Merchant{first, second} {
+		_, err := payments.Payment(userID, idr(2500), "coffee", PaymentOptions{MerchantID: merchant.ID})
+		require.NoError(t, err)
+	}
+
+	// The first merchant's balance no longer covers its payments.
+	broken := store.merchants[first.ID]
+	broken.Balance = idr(1000)
+	store.merchants[first.ID] = broken
+
+	batches, err := uc.RunSettlements()
+	require.NoError(t, err)
+	require.Len(t, batches, 1, "the second merchant is settled despite the first")
+	assert.Equal(t, second.ID, batches[0].MerchantID)
+	assert.Equal(t, idr(1000), store.merchants[first.ID].Balance)
+	assert.Equal(t, idr(0), store.merchants[first.ID].PayoutInTransit)
+
+	broken.Balance = idr(2500)
+	store.merchants[first.ID] = broken
+
+	batches, err = uc.RunSettlements()
+	require.NoError(t, err)
+	require.Len(t, batches, 1, "the first merchant is settled once it can be")
+	assert.Equal(t, first.ID, batches[0].MerchantID)
+}
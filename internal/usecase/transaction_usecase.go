@@ -1,103 +1,312 @@
 package usecase
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
 	"github.com/bangadam/wallet-api/pkg/queue"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+var ErrTransferNotPending = errors.New("transfer is no longer pending and cannot be cancelled")
+
 type TransactionUsecase struct {
+	uow             domain.UnitOfWork
 	transactionRepo domain.TransactionRepository
 	userRepo        domain.UserRepository
-	queueService    *queue.QueueService
+	walletRepo      domain.WalletRepository
+	ledgerRepo      domain.LedgerRepository
+	tasks           TaskInspector
+	limits          *LimitsUsecase
+	fees            *FeeUsecase
 }
 
 func NewTransactionUsecase(
+	uow domain.UnitOfWork,
 	transactionRepo domain.TransactionRepository,
 	userRepo domain.UserRepository,
-	queueService *queue.QueueService,
+	walletRepo domain.WalletRepository,
+	ledgerRepo domain.LedgerRepository,
+	tasks TaskInspector,
+	limits *LimitsUsecase,
+	fees *FeeUsecase,
 ) *TransactionUsecase {
 	return &TransactionUsecase{
+		uow:             uow,
 		transactionRepo: transactionRepo,
 		userRepo:        userRepo,
-		queueService:    queueService,
+		walletRepo:      walletRepo,
+		ledgerRepo:      ledgerRepo,
+		tasks:           tasks,
+		limits:          limits,
+		fees:            fees,
 	}
 }
 
-func (u *TransactionUsecase) TopUp(userID uuid.UUID, amount float64) (*domain.Transaction, error) {
-	user, err := u.userRepo.GetByID(userID)
+// TopUpOptions are the optional parts of a top-up request.
+type TopUpOptions struct {
+	// PromoCode waives the fee when the promotion covers top-ups.
+	PromoCode string
+}
+
+func (u *TransactionUsecase) TopUp(userID uuid.UUID, amount money.Money, opts TopUpOptions) (*domain.Transaction, error) {
+	var tx *domain.Transaction
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		fee, err := u.fees.feeOf(repos, userID, domain.FeeTypeTopUp, amount, opts.PromoCode)
+		if err != nil {
+			return err
+		}
+
+		if err := u.limits.CheckIncoming(repos, userID, amount, true); err != nil {
+			return err
+		}
+
+		if err := u.limits.CheckBalanceCap(repos, userID, amount); err != nil {
+			return err
+		}
+
+		before, after, err := creditBalance(repos, userID, amount)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		tx = &domain.Transaction{
+			ID:            uuid.New(),
+			UserID:        userID,
+			Type:          domain.TransactionTypeCredit,
+			Status:        domain.TransactionStatusSuccess,
+			Amount:        amount,
+			BalanceBefore: before,
+			BalanceAfter:  after,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+			SettledAt:     &now,
+		}
+		if err := repos.Transactions.Create(tx); err != nil {
+			return err
+		}
+
+		err = postJournal(repos, tx.ID, "top up",
+			debitLine(systemAccount(domain.AccountCashInClearing, domain.AccountTypeAsset), amount),
+			creditLine(userAccount(userID), amount),
+		)
+		if err != nil {
+			return err
+		}
+
+		return chargeFee(repos, tx, fee.Fee)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	tx := &domain.Transaction{
-		ID:            uuid.New(),
-		UserID:        userID,
-		Type:          domain.TransactionTypeCredit,
-		Status:        domain.TransactionStatusSuccess,
-		Amount:        amount,
-		BalanceBefore: user.Balance,
-		BalanceAfter:  user.Balance + amount,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+	return tx, nil
+}
+
+// PaymentOptions are the optional parts of a payment request.
+type PaymentOptions struct {
+	// PocketID pays from a pocket instead of the wallet's own funds. The fee
+	// is drawn from the pocket too.
+	PocketID uuid.UUID
+	// PromoCode waives the fee when the promotion covers payments.
+	PromoCode string
+	// MerchantID credits the payment to a merchant in the same database
+	// transaction; OrderReference is the merchant's reference of the order.
+	// Without a merchant the payment goes to the merchant payable account.
+	MerchantID     uuid.UUID
+	OrderReference string
+	// PaymentIntentID completes a payment intent in the same database
+	// transaction. PaymentIntentUsecase.Confirm sets it together with the
+	// intent's merchant and order.
+	PaymentIntentID uuid.UUID
+}
+
+func (u *TransactionUsecase) Payment(userID uuid.UUID, amount money.Money, remarks string, opts PaymentOptions) (*domain.Transaction, error) {
+	var tx *domain.Transaction
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		var intent *domain.PaymentIntent
+		if opts.PaymentIntentID != uuid.Nil {
+			var err error
+			intent, err = lockIntentForPayment(repos, opts.PaymentIntentID, userID, opts.MerchantID, amount)
+			if err != nil {
+				return err
+			}
+		}
+
+		fee, err := u.fees.feeOf(repos, userID, domain.FeeTypePayment, amount, opts.PromoCode)
+		if err != nil {
+			return err
+		}
+
+		if err := u.limits.CheckOutgoing(repos, userID, amount); err != nil {
+			return err
+		}
+
+		if opts.PocketID != uuid.Nil {
+			if _, err := drawPocket(repos, userID, opts.PocketID, amount.Add(fee.Fee)); err != nil {
+				return err
+			}
+		}
+
+		before, after, err := debitBalance(repos, userID, amount)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		tx = &domain.Transaction{
+			ID:             uuid.New(),
+			UserID:         userID,
+			Type:           domain.TransactionTypeDebit,
+			Status:         domain.TransactionStatusSuccess,
+			Amount:         amount,
+			Remarks:        remarks,
+			BalanceBefore:  before,
+			BalanceAfter:   after,
+			OrderReference: opts.OrderReference,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			SettledAt:      &now,
+		}
+
+		payee := systemAccount(domain.AccountMerchantPayable, domain.AccountTypeLiability)
+		if opts.MerchantID != uuid.Nil {
+			if err := creditMerchant(repos, opts.MerchantID, amount); err != nil {
+				return err
+			}
+			tx.MerchantID = &opts.MerchantID
+			payee = merchantAccount(opts.MerchantID)
+		}
+
+		if err := repos.Transactions.Create(tx); err != nil {
+			return err
+		}
+
+		err = postJournal(repos, tx.ID, "payment",
+			debitLine(userAccount(userID), amount),
+			creditLine(payee, amount),
+		)
+		if err != nil {
+			return err
+		}
+
+		if err := chargeFee(repos, tx, fee.Fee); err != nil {
+			return err
+		}
+
+		if intent != nil {
+			return completePaymentIntent(repos, intent, tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := u.transactionRepo.Create(tx); err != nil {
+	return tx, nil
+}
+
+// TransferOptions are the optional parts of a transfer request.
+type TransferOptions struct {
+	// TargetCurrency is the currency the recipient receives. Empty means the
+	// currency of the amount.
+	TargetCurrency string
+	// Convert allows TargetCurrency to differ from the amount's currency. The
+	// amount is then converted at QuoteID before it is sent.
+	Convert bool
+	QuoteID uuid.UUID
+	// PocketID sends the amount from a pocket instead of the wallet's own
+	// funds. The fee is drawn from the pocket too.
+	PocketID uuid.UUID
+	// PromoCode waives the fee when the promotion covers transfers.
+	PromoCode string
+}
+
+// Transfer records a pending transfer and, in the same database transaction,
+// an outbox row for the worker task. The task is published by OutboxRelay.
+func (u *TransactionUsecase) Transfer(fromUserID, toUserID uuid.UUID, amount money.Money, remarks string, opts TransferOptions) (*domain.Transaction, error) {
+	convert, err := needsConversion(amount, opts.TargetCurrency, opts.Convert, opts.QuoteID)
+	if err != nil {
 		return nil, err
 	}
 
-	if err := u.userRepo.UpdateBalance(userID, tx.BalanceAfter); err != nil {
+	var tx *domain.Transaction
+	err = u.uow.Do(func(repos *domain.Repositories) error {
+		tx, err = createTransfer(repos, u.limits, u.fees, fromUserID, toUserID, amount, remarks, opts, convert)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return tx, nil
 }
 
-func (u *TransactionUsecase) Payment(userID uuid.UUID, amount float64, remarks string) (*domain.Transaction, error) {
-	user, err := u.userRepo.GetByID(userID)
+// createTransfer holds the amount and its fee and writes the pending
+// transaction and its outbox row. convert is the result of needsConversion
+// for the request. The amount that is sent counts towards the limits of both
+// users; the fee is charged on the amount as requested, before conversion.
+func createTransfer(repos *domain.Repositories, limits *LimitsUsecase, fees *FeeUsecase, fromUserID, toUserID uuid.UUID, amount money.Money, remarks string, opts TransferOptions, convert bool) (*domain.Transaction, error) {
+	if toUserID == fromUserID {
+		return nil, ErrSelfTransfer
+	}
+
+	toUser, err := repos.Users.GetByID(toUserID)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecipientNotFound
+		}
 		return nil, err
 	}
 
-	if user.Balance < amount {
-		return nil, errors.New("balance is not enough")
+	if toUser.Status == domain.UserStatusFrozen {
+		return nil, ErrWalletFrozen
 	}
 
-	tx := &domain.Transaction{
-		ID:            uuid.New(),
-		UserID:        userID,
-		Type:          domain.TransactionTypeDebit,
-		Status:        domain.TransactionStatusSuccess,
-		Amount:        amount,
-		Remarks:       remarks,
-		BalanceBefore: user.Balance,
-		BalanceAfter:  user.Balance - amount,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+	if err := lockUsers(repos, fromUserID, toUserID); err != nil {
+		return nil, err
 	}
 
-	if err := u.transactionRepo.Create(tx); err != nil {
+	fee, err := fees.feeOf(repos, fromUserID, domain.FeeTypeTransfer, amount, opts.PromoCode)
+	if err != nil {
 		return nil, err
 	}
 
-	if err := u.userRepo.UpdateBalance(userID, tx.BalanceAfter); err != nil {
-		return nil, err
+	if opts.PocketID != uuid.Nil {
+		if _, err := drawPocket(repos, fromUserID, opts.PocketID, amount.Add(fee.Fee)); err != nil {
+			return nil, err
+		}
 	}
 
-	return tx, nil
-}
+	if convert {
+		conversion, err := convertAtQuote(repos, fromUserID, opts.QuoteID)
+		if err != nil {
+			return nil, err
+		}
+		quote := conversion.Quote
+		if quote.SourceAmount != amount || quote.TargetAmount.Currency != opts.TargetCurrency {
+			return nil, ErrQuoteMismatch
+		}
+		amount = quote.TargetAmount
+	}
 
-func (u *TransactionUsecase) Transfer(fromUserID, toUserID uuid.UUID, amount float64, remarks string) (*domain.Transaction, error) {
-	fromUser, err := u.userRepo.GetByID(fromUserID)
-	if err != nil {
+	if err := limits.CheckOutgoing(repos, fromUserID, amount); err != nil {
+		return nil, err
+	}
+	if err := limits.CheckIncoming(repos, toUserID, amount, false); err != nil {
 		return nil, err
 	}
 
-	if fromUser.Balance < amount {
-		return nil, errors.New("balance is not enough")
+	// Reserve the amount until the worker settles or fails the transfer.
+	wallet, err := placeHold(repos, fromUserID, amount)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create pending transaction
@@ -108,96 +317,290 @@ func (u *TransactionUsecase) Transfer(fromUserID, toUserID uuid.UUID, amount flo
 		Status:        domain.TransactionStatusPending,
 		Amount:        amount,
 		Remarks:       remarks,
-		BalanceBefore: fromUser.Balance,
-		BalanceAfter:  fromUser.Balance - amount,
+		BalanceBefore: wallet.Balance,
+		BalanceAfter:  wallet.Balance.Sub(amount),
 		TargetUserID:  &toUserID,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
+	if opts.PocketID != uuid.Nil {
+		tx.PocketID = &opts.PocketID
+	}
+
+	if err := repos.Transactions.Create(tx); err != nil {
+		return nil, err
+	}
+
+	if err := holdFee(repos, tx, fee.Fee); err != nil {
+		return nil, err
+	}
 
-	if err := u.transactionRepo.Create(tx); err != nil {
+	if err := enqueueTransfer(repos, tx); err != nil {
 		return nil, err
 	}
 
-	// Enqueue transfer task
-	err = u.queueService.EnqueueTransfer(&queue.TransferPayload{
+	return tx, nil
+}
+
+// enqueueTransfer writes the worker task for a pending transfer to the outbox.
+func enqueueTransfer(repos *domain.Repositories, tx *domain.Transaction) error {
+	payload, err := json.Marshal(&queue.TransferPayload{
 		TransactionID: tx.ID.String(),
-		FromUserID:    fromUserID.String(),
-		ToUserID:      toUserID.String(),
-		Amount:        amount,
+		FromUserID:    tx.UserID.String(),
+		ToUserID:      tx.TargetUserID.String(),
+		Amount:        tx.Amount.Minor,
+		Currency:      tx.Amount.Currency,
 	})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return tx, nil
+	return repos.Outbox.Create(&domain.OutboxMessage{
+		TaskType:  queue.TaskTransfer,
+		TaskID:    tx.ID.String(),
+		Payload:   payload,
+		Status:    domain.OutboxStatusPending,
+		CreatedAt: time.Now(),
+	})
 }
 
 func (u *TransactionUsecase) GetTransactionsByUserID(userID uuid.UUID) ([]domain.Transaction, error) {
 	return u.transactionRepo.GetByUserID(userID)
 }
 
-func (u *TransactionUsecase) ProcessTransfer(transactionID, fromUserID, toUserID string, amount float64) error {
-	txID, err := uuid.Parse(transactionID)
-	if err != nil {
-		return err
-	}
+// ProcessTransfer settles a pending transfer. It is safe to run any number of
+// times for the same transaction: all steps commit together under a lock on
+// the transaction row, a transfer that is no longer PENDING is left alone and
+// the recipient credit is unique per transfer.
+func (u *TransactionUsecase) ProcessTransfer(transactionID uuid.UUID) error {
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		tx, err := repos.Transactions.GetByIDForUpdate(transactionID)
+		if err != nil {
+			return err
+		}
+
+		if tx.Status != domain.TransactionStatusPending {
+			return nil
+		}
+
+		if tx.TargetUserID == nil {
+			return fmt.Errorf("transaction %s is not a transfer", tx.ID)
+		}
+		fromUID, toUID := tx.UserID, *tx.TargetUserID
+
+		// A credit left behind by an interrupted run means the money already
+		// moved; only the status update is missing.
+		credits, err := repos.Transactions.GetByReference(tx.ID, domain.ReferenceTypeTransfer)
+		if err != nil {
+			return err
+		}
+		if len(credits) > 0 {
+			tx.Status = domain.TransactionStatusSuccess
+			tx.SettledAt = &credits[0].CreatedAt
+			tx.UpdatedAt = time.Now()
+			return repos.Transactions.Update(tx)
+		}
+
+		if err := lockUsers(repos, fromUID, toUID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &transferRejection{ErrRecipientNotFound}
+			}
+			return err
+		}
+
+		// Capture the sender's hold
+		senderBefore, senderAfter, err := captureHold(repos, fromUID, tx.Amount)
+		if err != nil {
+			return rejectTransfer(err)
+		}
+
+		// Bounce the transfer back to the sender when the recipient may not
+		// hold that much.
+		if err := u.limits.CheckBalanceCap(repos, toUID, tx.Amount); err != nil {
+			if errors.Is(err, ErrLimitExceeded) {
+				return &transferRejection{ErrRecipientBalanceCap}
+			}
+			return err
+		}
+
+		// Update recipient's balance
+		recipientBefore, recipientAfter, err := creditBalance(repos, toUID, tx.Amount)
+		if err != nil {
+			return rejectTransfer(err)
+		}
+
+		// Create recipient's transaction
+		now := time.Now()
+		recipientTx := &domain.Transaction{
+			ID:            uuid.New(),
+			UserID:        toUID,
+			Type:          domain.TransactionTypeCredit,
+			Status:        domain.TransactionStatusSuccess,
+			Amount:        tx.Amount,
+			Remarks:       tx.Remarks,
+			BalanceBefore: recipientBefore,
+			BalanceAfter:  recipientAfter,
+			ReferenceID:   tx.ID,
+			ReferenceType: domain.ReferenceTypeTransfer,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+			SettledAt:     &now,
+		}
+
+		if err := repos.Transactions.Create(recipientTx); err != nil {
+			return err
+		}
+
+		err = postJournal(repos, tx.ID, "transfer",
+			debitLine(userAccount(fromUID), tx.Amount),
+			creditLine(userAccount(toUID), tx.Amount),
+		)
+		if err != nil {
+			return err
+		}
+
+		if err := settleFee(repos, tx); err != nil {
+			return rejectTransfer(err)
+		}
+
+		// Update original transaction status
+		tx.Status = domain.TransactionStatusSuccess
+		tx.BalanceBefore = senderBefore
+		tx.BalanceAfter = senderAfter
+		tx.SettledAt = &now
+		tx.UpdatedAt = now
+		return repos.Transactions.Update(tx)
+	})
 
-	fromUID, err := uuid.Parse(fromUserID)
-	if err != nil {
-		return err
+	// Rejections roll back whatever was applied and then fail the transfer
+	// in a fresh unit of work.
+	var rejection *transferRejection
+	if errors.As(err, &rejection) {
+		return u.FailTransfer(transactionID, rejection.Error())
 	}
 
-	toUID, err := uuid.Parse(toUserID)
+	return err
+}
+
+// CancelTransfer calls off one of the user's transfers while it is still
+// PENDING, releases its hold and returns it to the pocket it came from. The transaction row lock decides the race
+// with the worker: whichever commits first wins, and the worker ignores
+// transfers that are no longer PENDING.
+func (u *TransactionUsecase) CancelTransfer(userID, transactionID uuid.UUID) (*domain.Transaction, error) {
+	var tx *domain.Transaction
+	err := u.uow.Do(func(repos *domain.Repositories) error {
+		var err error
+		tx, err = repos.Transactions.GetByIDForUpdate(transactionID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTransactionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if tx.UserID != userID || tx.TargetUserID == nil || tx.Type != domain.TransactionTypeDebit {
+			return ErrTransactionNotFound
+		}
+		if tx.Status != domain.TransactionStatusPending {
+			return ErrTransferNotPending
+		}
+
+		if err := reopenMoneyRequest(repos, tx, &userID, "transfer cancelled"); err != nil {
+			return err
+		}
+
+		if err := releaseHold(repos, tx.UserID, tx.Amount); err != nil {
+			return err
+		}
+
+		if err := repos.Outbox.CancelPending(queue.TaskTransfer, tx.ID.String()); err != nil {
+			return err
+		}
+
+		tx.Status = domain.TransactionStatusCancelled
+		tx.UpdatedAt = time.Now()
+		if err := releaseFee(repos, tx); err != nil {
+			return err
+		}
+		if err := returnToPocket(repos, tx); err != nil {
+			return err
+		}
+		return repos.Transactions.Update(tx)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Get transaction
-	tx, err := u.transactionRepo.GetByID(txID)
-	if err != nil {
+	// Best effort: a task that is already running, or that cannot be
+	// deleted now, finds the transfer CANCELLED and does nothing.
+	_ = u.tasks.DeleteTask(tx.ID.String())
+
+	return tx, nil
+}
+
+// transferRejection wraps errors that fail a transfer for good instead of
+// letting the worker retry it.
+type transferRejection struct {
+	err error
+}
+
+func (e *transferRejection) Error() string { return e.err.Error() }
+
+func (e *transferRejection) Unwrap() error { return e.err }
+
+func rejectTransfer(err error) error {
+	switch {
+	case errors.Is(err, ErrInsufficientBalance),
+		errors.Is(err, ErrWalletFrozen),
+		errors.Is(err, money.ErrCurrencyMismatch):
+		return &transferRejection{err}
+	default:
 		return err
 	}
+}
+
+// FailTransfer gives up on a pending transfer, e.g. once the worker has run
+// out of retries. Transfers that are no longer PENDING are left untouched.
+func (u *TransactionUsecase) FailTransfer(transactionID uuid.UUID, reason string) error {
+	return u.uow.Do(func(repos *domain.Repositories) error {
+		tx, err := repos.Transactions.GetByIDForUpdate(transactionID)
+		if err != nil {
+			return err
+		}
+
+		if tx.Status != domain.TransactionStatusPending {
+			return nil
+		}
 
-	// Update sender's balance
-	if err := u.userRepo.UpdateBalance(fromUID, tx.BalanceAfter); err != nil {
-		tx.Status = domain.TransactionStatusFailed
-		u.transactionRepo.Update(tx)
+		return failTransfer(repos, tx, reason)
+	})
+}
+
+// failTransfer marks a locked pending transfer FAILED, releases the sender's
+// hold and fee, returns them to the pocket they came from, reopens the money
+// request it was paying and tells the sender.
+func failTransfer(repos *domain.Repositories, tx *domain.Transaction, reason string) error {
+	if err := reopenMoneyRequest(repos, tx, nil, "transfer failed: "+reason); err != nil {
 		return err
 	}
 
-	// Create recipient's transaction
-	recipientTx := &domain.Transaction{
-		ID:            uuid.New(),
-		UserID:        toUID,
-		Type:          domain.TransactionTypeCredit,
-		Status:        domain.TransactionStatusSuccess,
-		Amount:        amount,
-		Remarks:       tx.Remarks,
-		ReferenceID:   tx.ID,
-		ReferenceType: "transfer",
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+	if err := releaseHold(repos, tx.UserID, tx.Amount); err != nil {
+		return err
 	}
 
-	toUser, err := u.userRepo.GetByID(toUID)
-	if err != nil {
+	tx.Status = domain.TransactionStatusFailed
+	tx.FailureReason = reason
+	tx.UpdatedAt = time.Now()
+	if err := releaseFee(repos, tx); err != nil {
 		return err
 	}
-
-	recipientTx.BalanceBefore = toUser.Balance
-	recipientTx.BalanceAfter = toUser.Balance + amount
-
-	if err := u.transactionRepo.Create(recipientTx); err != nil {
+	if err := returnToPocket(repos, tx); err != nil {
 		return err
 	}
-
-	// Update recipient's balance
-	if err := u.userRepo.UpdateBalance(toUID, recipientTx.BalanceAfter); err != nil {
+	if err := repos.Transactions.Update(tx); err != nil {
 		return err
 	}
 
-	// Update original transaction status
-	tx.Status = domain.TransactionStatusSuccess
-	return u.transactionRepo.Update(tx)
+	return notify(repos, tx.UserID, "Transfer failed",
+		fmt.Sprintf("Your transfer of %s %s could not be completed: %s.", tx.Amount, tx.Amount.Currency, reason))
 }
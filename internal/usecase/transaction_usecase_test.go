@@ -0,0 +1,316 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTransactionUsecase(store *memStore) *TransactionUsecase {
+	return newTransactionUsecaseWith(store, noLimits(store), noFees(store))
+}
+
+// newTransactionUsecaseWith builds a transaction usecase that applies the
+// given limits and fees.
+func newTransactionUsecaseWith(store *memStore, limits *LimitsUsecase, fees *FeeUsecase) *TransactionUsecase {
+	repos := store.repos()
+	return NewTransactionUsecase(store, repos.Transactions, repos.Users, repos.Wallets, repos.Ledger, store, limits, fees)
+}
+
+// newPendingTransfer creates a transfer of amount from a sender holding
+// 10000 to a recipient holding 500, and resets the call counter.
+func newPendingTransfer(t *testing.T, store *memStore, amount money.Money) (txID, fromID, toID uuid.UUID) {
+	t.Helper()
+
+	fromID = store.addUser(idr(10000))
+	toID = store.addUser(idr(500))
+
+	tx, err := newTestTransactionUsecase(store).Transfer(fromID, toID, amount, "rent", TransferOptions{})
+	require.NoError(t, err)
+	store.calls = 0
+
+	return tx.ID, fromID, toID
+}
+
+func assertTransferSettledOnce(t *testing.T, store *memStore, txID, fromID, toID uuid.UUID, amount money.Money) {
+	t.Helper()
+
+	assert.Equal(t, domain.TransactionStatusSuccess, store.transactions[txID].Status)
+	assert.Equal(t, idr(10000).Sub(amount), store.wallet(fromID).Balance)
+	assert.Equal(t, idr(0), store.wallet(fromID).HeldBalance)
+	assert.Equal(t, idr(500).Add(amount), store.wallet(toID).Balance)
+	assert.Len(t, store.transactionsByReference(txID, domain.ReferenceTypeTransfer), 1)
+
+	entries := 0
+	for _, entry := range store.entries {
+		if entry.TransactionID == txID {
+			entries++
+		}
+	}
+	assert.Equal(t, 1, entries)
+}
+
+func TestTransactionUsecase_Transfer(t *testing.T) {
+	store := newMemStore()
+	txID, fromID, toID := newPendingTransfer(t, store, idr(2500))
+
+	tx := store.transactions[txID]
+	assert.Equal(t, domain.TransactionStatusPending, tx.Status)
+	require.Len(t, store.outbox, 1)
+	assert.Equal(t, txID.String(), store.outbox[0].TaskID)
+	assert.Equal(t, domain.OutboxStatusPending, store.outbox[0].Status)
+
+	from := store.wallet(fromID)
+	assert.Equal(t, idr(10000), from.Balance)
+	assert.Equal(t, idr(2500), from.HeldBalance)
+	assert.Equal(t, idr(7500), from.AvailableBalance())
+
+	// Held funds cannot be spent twice.
+	_, err := newTestTransactionUsecase(store).Transfer(fromID, toID, idr(8000), "rent", TransferOptions{})
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+	assert.Equal(t, idr(2500), store.wallet(fromID).HeldBalance)
+}
+
+func TestTransactionUsecase_TransferCurrencies(t *testing.T) {
+	t.Run("opens the recipient wallet in the transfer currency", func(t *testing.T) {
+		store := newMemStore()
+		fromID := store.addUser(money.New(5000, "USD"))
+		toID := store.addUser(idr(500))
+		uc := newTestTransactionUsecase(store)
+
+		tx, err := uc.Transfer(fromID, toID, money.New(2000, "USD"), "rent", TransferOptions{})
+		require.NoError(t, err)
+		require.NoError(t, uc.ProcessTransfer(tx.ID))
+
+		assert.Equal(t, money.New(3000, "USD"), store.wallets[walletKey(fromID, "USD")].Balance)
+		assert.Equal(t, money.New(2000, "USD"), store.wallets[walletKey(toID, "USD")].Balance)
+		assert.Equal(t, idr(500), store.wallet(toID).Balance)
+	})
+
+	t.Run("rejects a move across currencies", func(t *testing.T) {
+		store := newMemStore()
+		fromID := store.addUser(idr(10000))
+		toID := store.addUser(idr(500))
+		uc := newTestTransactionUsecase(store)
+
+		_, err := uc.Transfer(fromID, toID, idr(100), "rent", TransferOptions{TargetCurrency: "USD"})
+		assert.ErrorIs(t, err, ErrCrossCurrency)
+
+		_, err = uc.Transfer(fromID, toID, idr(100), "rent", TransferOptions{TargetCurrency: "USD", Convert: true})
+		assert.ErrorIs(t, err, ErrQuoteRequired)
+		assert.Empty(t, store.transactions)
+	})
+
+	t.Run("has no funds in a currency without a wallet", func(t *testing.T) {
+		store := newMemStore()
+		fromID := store.addUser(idr(10000))
+		toID := store.addUser(idr(500))
+
+		_, err := newTestTransactionUsecase(store).Transfer(fromID, toID, money.New(100, "USD"), "rent", TransferOptions{})
+		assert.ErrorIs(t, err, ErrInsufficientBalance)
+	})
+}
+
+func TestTransactionUsecase_BalanceOverflow(t *testing.T) {
+	store := newMemStore()
+	userID := store.addUser(idr(math.MaxInt64 - 100))
+
+	_, err := newTestTransactionUsecase(store).TopUp(userID, idr(1000), TopUpOptions{})
+	assert.ErrorIs(t, err, money.ErrOverflow)
+	assert.Equal(t, idr(math.MaxInt64-100), store.wallet(userID).Balance)
+	assert.Empty(t, store.transactions)
+}
+
+func TestTransactionUsecase_ProcessTransfer(t *testing.T) {
+	amount := idr(2500)
+
+	t.Run("settles a pending transfer", func(t *testing.T) {
+		store := newMemStore()
+		txID, fromID, toID := newPendingTransfer(t, store, amount)
+
+		err := newTestTransactionUsecase(store).ProcessTransfer(txID)
+
+		assert.NoError(t, err)
+		assertTransferSettledOnce(t, store, txID, fromID, toID, amount)
+	})
+
+	t.Run("running again is a no-op", func(t *testing.T) {
+		store := newMemStore()
+		txID, fromID, toID := newPendingTransfer(t, store, amount)
+		uc := newTestTransactionUsecase(store)
+
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, uc.ProcessTransfer(txID))
+		}
+
+		assertTransferSettledOnce(t, store, txID, fromID, toID, amount)
+	})
+
+	t.Run("resumes after a failure at every step", func(t *testing.T) {
+		store := newMemStore()
+		txID, _, _ := newPendingTransfer(t, store, amount)
+		require.NoError(t, newTestTransactionUsecase(store).ProcessTransfer(txID))
+		steps := store.calls
+		require.Greater(t, steps, 0)
+
+		for failAt := 1; failAt <= steps; failAt++ {
+			store := newMemStore()
+			txID, fromID, toID := newPendingTransfer(t, store, amount)
+			uc := newTestTransactionUsecase(store)
+
+			store.failAt = failAt
+			err := uc.ProcessTransfer(txID)
+			assert.ErrorIs(t, err, errInjected, "step %d", failAt)
+			assert.Equal(t, domain.TransactionStatusPending, store.transactions[txID].Status, "step %d", failAt)
+			assert.Equal(t, idr(10000), store.wallet(fromID).Balance, "step %d", failAt)
+			assert.Equal(t, idr(500), store.wallet(toID).Balance, "step %d", failAt)
+
+			store.failAt = 0
+			assert.NoError(t, uc.ProcessTransfer(txID), "step %d", failAt)
+			assert.NoError(t, uc.ProcessTransfer(txID), "step %d", failAt)
+			assertTransferSettledOnce(t, store, txID, fromID, toID, amount)
+		}
+	})
+
+	t.Run("completes a transfer whose credit already exists", func(t *testing.T) {
+		store := newMemStore()
+		txID, fromID, toID := newPendingTransfer(t, store, amount)
+
+		// Simulate a legacy run that moved the money but died before
+		// marking the transfer as successful.
+		store.setBalance(fromID, idr(10000).Sub(amount))
+		store.setBalance(toID, idr(500).Add(amount))
+		creditID := uuid.New()
+		store.transactions[creditID] = domain.Transaction{
+			ID:            creditID,
+			UserID:        toID,
+			Type:          domain.TransactionTypeCredit,
+			Status:        domain.TransactionStatusSuccess,
+			Amount:        amount,
+			ReferenceID:   txID,
+			ReferenceType: domain.ReferenceTypeTransfer,
+			CreatedAt:     time.Now(),
+		}
+
+		err := newTestTransactionUsecase(store).ProcessTransfer(txID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.TransactionStatusSuccess, store.transactions[txID].Status)
+		assert.Equal(t, idr(10000).Sub(amount), store.wallet(fromID).Balance)
+		assert.Equal(t, idr(500).Add(amount), store.wallet(toID).Balance)
+		assert.Len(t, store.transactionsByReference(txID, domain.ReferenceTypeTransfer), 1)
+	})
+
+	t.Run("fails when the sender can no longer cover it", func(t *testing.T) {
+		store := newMemStore()
+		txID, fromID, toID := newPendingTransfer(t, store, amount)
+		store.setBalance(fromID, idr(100))
+
+		err := newTestTransactionUsecase(store).ProcessTransfer(txID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.TransactionStatusFailed, store.transactions[txID].Status)
+		assert.Equal(t, ErrInsufficientBalance.Error(), store.transactions[txID].FailureReason)
+		assert.Equal(t, idr(100), store.wallet(fromID).Balance)
+		assert.Equal(t, idr(0), store.wallet(fromID).HeldBalance)
+		assert.Equal(t, idr(500), store.wallet(toID).Balance)
+		assert.Len(t, store.notifications, 1)
+	})
+
+	t.Run("fails when the recipient is gone", func(t *testing.T) {
+		store := newMemStore()
+		txID, fromID, toID := newPendingTransfer(t, store, amount)
+		delete(store.users, toID)
+
+		err := newTestTransactionUsecase(store).ProcessTransfer(txID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.TransactionStatusFailed, store.transactions[txID].Status)
+		assert.Equal(t, ErrRecipientNotFound.Error(), store.transactions[txID].FailureReason)
+		assert.Equal(t, idr(10000), store.wallet(fromID).Balance)
+		require.Len(t, store.notifications, 1)
+		assert.Equal(t, fromID, store.notifications[0].UserID)
+	})
+}
+
+func TestTransactionUsecase_FailTransfer(t *testing.T) {
+	t.Run("fails a pending transfer once", func(t *testing.T) {
+		store := newMemStore()
+		txID, fromID, _ := newPendingTransfer(t, store, idr(2500))
+		uc := newTestTransactionUsecase(store)
+
+		assert.NoError(t, uc.FailTransfer(txID, "retries exhausted"))
+		assert.NoError(t, uc.FailTransfer(txID, "retries exhausted"))
+
+		tx := store.transactions[txID]
+		assert.Equal(t, domain.TransactionStatusFailed, tx.Status)
+		assert.Equal(t, "retries exhausted", tx.FailureReason)
+		assert.Equal(t, idr(10000), store.wallet(fromID).Balance)
+		assert.Equal(t, idr(0), store.wallet(fromID).HeldBalance)
+		assert.Len(t, store.notifications, 1)
+	})
+
+	t.Run("leaves a settled transfer alone", func(t *testing.T) {
+		store := newMemStore()
+		txID, fromID, toID := newPendingTransfer(t, store, idr(2500))
+		uc := newTestTransactionUsecase(store)
+		require.NoError(t, uc.ProcessTransfer(txID))
+
+		assert.NoError(t, uc.FailTransfer(txID, "retries exhausted"))
+		assertTransferSettledOnce(t, store, txID, fromID, toID, idr(2500))
+		assert.Empty(t, store.notifications)
+	})
+
+	t.Run("rejects unknown recipients up front", func(t *testing.T) {
+		store := newMemStore()
+		fromID := store.addUser(idr(10000))
+
+		_, err := newTestTransactionUsecase(store).Transfer(fromID, uuid.New(), idr(100), "rent", TransferOptions{})
+
+		assert.ErrorIs(t, err, ErrRecipientNotFound)
+		assert.Empty(t, store.transactions)
+		assert.Empty(t, store.outbox)
+	})
+}
+
+func TestTransactionUsecase_CancelTransfer(t *testing.T) {
+	t.Run("cancels a pending transfer and its task", func(t *testing.T) {
+		store := newMemStore()
+		txID, fromID, toID := newPendingTransfer(t, store, idr(2500))
+		uc := newTestTransactionUsecase(store)
+
+		_, err := uc.CancelTransfer(toID, txID)
+		assert.ErrorIs(t, err, ErrTransactionNotFound)
+
+		tx, err := uc.CancelTransfer(fromID, txID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.TransactionStatusCancelled, tx.Status)
+		assert.Equal(t, idr(10000), store.wallet(fromID).Balance)
+		assert.Equal(t, idr(0), store.wallet(fromID).HeldBalance)
+		assert.Equal(t, domain.OutboxStatusCancelled, store.outbox[0].Status)
+		assert.Equal(t, []string{txID.String()}, store.deletedTasks)
+
+		// A task that was already running finds nothing to do.
+		require.NoError(t, uc.ProcessTransfer(txID))
+		assert.Equal(t, domain.TransactionStatusCancelled, store.transactions[txID].Status)
+		assert.Equal(t, idr(500), store.wallet(toID).Balance)
+	})
+
+	t.Run("refuses once the worker has settled the transfer", func(t *testing.T) {
+		store := newMemStore()
+		txID, fromID, toID := newPendingTransfer(t, store, idr(2500))
+		uc := newTestTransactionUsecase(store)
+		require.NoError(t, uc.ProcessTransfer(txID))
+
+		_, err := uc.CancelTransfer(fromID, txID)
+		assert.ErrorIs(t, err, ErrTransferNotPending)
+		assertTransferSettledOnce(t, store, txID, fromID, toID, idr(2500))
+		assert.Empty(t, store.deletedTasks)
+	})
+}
@@ -0,0 +1,197 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/queue"
+	"github.com/google/uuid"
+)
+
+type TaskInspector interface {
+	// TaskState returns the queue state of a task, or "" if it is unknown.
+	TaskState(taskID string) (string, error)
+	DeleteTask(taskID string) error
+}
+
+type TransferSweeperConfig struct {
+	// MinAge leaves recent transfers to the normal worker flow.
+	MinAge time.Duration
+	// MaxAge is how long a transfer whose task was lost keeps being
+	// re-enqueued before it is failed.
+	MaxAge    time.Duration
+	BatchSize int
+}
+
+type SweepAction string
+
+const (
+	SweepActionInFlight  SweepAction = "IN_FLIGHT"
+	SweepActionRequeued  SweepAction = "REQUEUED"
+	SweepActionCompleted SweepAction = "COMPLETED"
+	SweepActionFailed    SweepAction = "FAILED"
+	SweepActionError     SweepAction = "ERROR"
+)
+
+type SweepResult struct {
+	TransactionID uuid.UUID
+	Action        SweepAction
+	Detail        string
+}
+
+type SweepReport struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Results    []SweepResult
+}
+
+func (r *SweepReport) Count(action SweepAction) int {
+	count := 0
+	for _, result := range r.Results {
+		if result.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *SweepReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "checked=%d in_flight=%d requeued=%d completed=%d failed=%d errors=%d duration=%s",
+		len(r.Results),
+		r.Count(SweepActionInFlight),
+		r.Count(SweepActionRequeued),
+		r.Count(SweepActionCompleted),
+		r.Count(SweepActionFailed),
+		r.Count(SweepActionError),
+		r.FinishedAt.Sub(r.StartedAt),
+	)
+	for _, result := range r.Results {
+		if result.Action == SweepActionInFlight {
+			continue
+		}
+		fmt.Fprintf(&b, "\n  %s %s: %s", result.TransactionID, result.Action, result.Detail)
+	}
+	return b.String()
+}
+
+// TransferSweeper finds transfers stuck in PENDING, e.g. because their task
+// was lost, and re-enqueues, completes or fails them.
+type TransferSweeper struct {
+	uow                domain.UnitOfWork
+	transactionRepo    domain.TransactionRepository
+	outboxRepo         domain.OutboxRepository
+	transactionUsecase *TransactionUsecase
+	inspector          TaskInspector
+	config             TransferSweeperConfig
+}
+
+func NewTransferSweeper(
+	uow domain.UnitOfWork,
+	transactionRepo domain.TransactionRepository,
+	outboxRepo domain.OutboxRepository,
+	transactionUsecase *TransactionUsecase,
+	inspector TaskInspector,
+	config TransferSweeperConfig,
+) *TransferSweeper {
+	return &TransferSweeper{
+		uow:                uow,
+		transactionRepo:    transactionRepo,
+		outboxRepo:         outboxRepo,
+		transactionUsecase: transactionUsecase,
+		inspector:          inspector,
+		config:             config,
+	}
+}
+
+func (s *TransferSweeper) Sweep() (*SweepReport, error) {
+	report := &SweepReport{StartedAt: time.Now()}
+
+	transfers, err := s.transactionRepo.GetPendingTransfers(report.StartedAt.Add(-s.config.MinAge), s.config.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tx := range transfers {
+		action, detail, err := s.sweep(&tx)
+		if err != nil {
+			action, detail = SweepActionError, err.Error()
+		}
+		report.Results = append(report.Results, SweepResult{
+			TransactionID: tx.ID,
+			Action:        action,
+			Detail:        detail,
+		})
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+func (s *TransferSweeper) sweep(tx *domain.Transaction) (SweepAction, string, error) {
+	taskID := tx.ID.String()
+
+	credits, err := s.transactionRepo.GetByReference(tx.ID, domain.ReferenceTypeTransfer)
+	if err != nil {
+		return "", "", err
+	}
+	if len(credits) > 0 {
+		if err := s.transactionUsecase.ProcessTransfer(tx.ID); err != nil {
+			return "", "", err
+		}
+		return SweepActionCompleted, "recipient credit already recorded", nil
+	}
+
+	queued, err := s.outboxRepo.HasPending(queue.TaskTransfer, taskID)
+	if err != nil {
+		return "", "", err
+	}
+	if queued {
+		return SweepActionInFlight, "waiting for outbox relay", nil
+	}
+
+	state, err := s.inspector.TaskState(taskID)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch state {
+	case queue.TaskStatePending, queue.TaskStateActive, queue.TaskStateScheduled, queue.TaskStateRetry:
+		return SweepActionInFlight, "task is " + state, nil
+	case queue.TaskStateArchived:
+		if err := s.transactionUsecase.FailTransfer(tx.ID, "transfer task was archived"); err != nil {
+			return "", "", err
+		}
+		return SweepActionFailed, "task was archived", nil
+	}
+
+	// The task is gone, or completed without settling the transfer.
+	if time.Since(tx.CreatedAt) > s.config.MaxAge {
+		if err := s.transactionUsecase.FailTransfer(tx.ID, "transfer task was lost"); err != nil {
+			return "", "", err
+		}
+		return SweepActionFailed, fmt.Sprintf("task lost and transfer older than %s", s.config.MaxAge), nil
+	}
+
+	if err := s.inspector.DeleteTask(taskID); err != nil {
+		return "", "", err
+	}
+
+	err = s.uow.Do(func(repos *domain.Repositories) error {
+		locked, err := repos.Transactions.GetByIDForUpdate(tx.ID)
+		if err != nil {
+			return err
+		}
+		if locked.Status != domain.TransactionStatusPending {
+			return nil
+		}
+		return enqueueTransfer(repos, locked)
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return SweepActionRequeued, "task lost, enqueued again", nil
+}
@@ -0,0 +1,123 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bangadam/wallet-api/internal/domain"
+	"github.com/bangadam/wallet-api/pkg/queue"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferSweeper(t *testing.T) {
+	// setup creates a transfer whose outbox row was already relayed, so only
+	// the queue decides what happens to it. age backdates the transfer.
+	setup := func(t *testing.T, age time.Duration) (*memStore, *TransferSweeper, *domain.Transaction) {
+		store := newMemStore()
+		store.taskStates = make(map[string]string)
+		fromID := store.addUser(idr(10000))
+		toID := store.addUser(idr(0))
+		transactions := newTestTransactionUsecase(store)
+
+		tx, err := transactions.Transfer(fromID, toID, idr(2500), "rent", TransferOptions{})
+		require.NoError(t, err)
+
+		for i := range store.outbox {
+			store.outbox[i].Status = domain.OutboxStatusSent
+		}
+		stored := store.transactions[tx.ID]
+		stored.CreatedAt = time.Now().Add(-age)
+		store.transactions[tx.ID] = stored
+
+		sweeper := NewTransferSweeper(store, store.repos().Transactions, store.repos().Outbox, transactions, store, TransferSweeperConfig{
+			MinAge:    time.Minute,
+			MaxAge:    time.Hour,
+			BatchSize: 10,
+		})
+		return store, sweeper, tx
+	}
+
+	sweepOne := func(t *testing.T, sweeper *TransferSweeper) SweepResult {
+		report, err := sweeper.Sweep()
+		require.NoError(t, err)
+		require.Len(t, report.Results, 1)
+		return report.Results[0]
+	}
+
+	t.Run("leaves transfers with a live task alone", func(t *testing.T) {
+		for _, state := range []string{queue.TaskStatePending, queue.TaskStateActive, queue.TaskStateScheduled, queue.TaskStateRetry} {
+			store, sweeper, tx := setup(t, 5*time.Minute)
+			store.taskStates[tx.ID.String()] = state
+
+			result := sweepOne(t, sweeper)
+			assert.Equal(t, SweepActionInFlight, result.Action, state)
+			assert.Equal(t, domain.TransactionStatusPending, store.transactions[tx.ID].Status)
+			assert.Empty(t, store.deletedTasks)
+		}
+	})
+
+	t.Run("leaves transfers waiting for the outbox relay alone", func(t *testing.T) {
+		store, sweeper, tx := setup(t, 5*time.Minute)
+		store.outbox[0].Status = domain.OutboxStatusPending
+
+		result := sweepOne(t, sweeper)
+		assert.Equal(t, SweepActionInFlight, result.Action)
+		assert.Equal(t, domain.TransactionStatusPending, store.transactions[tx.ID].Status)
+	})
+
+	t.Run("fails transfers whose task was archived", func(t *testing.T) {
+		store, sweeper, tx := setup(t, 5*time.Minute)
+		store.taskStates[tx.ID.String()] = queue.TaskStateArchived
+
+		result := sweepOne(t, sweeper)
+		assert.Equal(t, SweepActionFailed, result.Action)
+		assert.Equal(t, domain.TransactionStatusFailed, store.transactions[tx.ID].Status)
+		wallet := store.wallet(tx.UserID)
+		assert.Equal(t, idr(10000), wallet.AvailableBalance(), "the hold is released")
+	})
+
+	t.Run("fails old transfers whose task was lost", func(t *testing.T) {
+		store, sweeper, tx := setup(t, 2*time.Hour)
+
+		result := sweepOne(t, sweeper)
+		assert.Equal(t, SweepActionFailed, result.Action)
+		assert.Equal(t, domain.TransactionStatusFailed, store.transactions[tx.ID].Status)
+	})
+
+	t.Run("re-enqueues recent transfers whose task was lost", func(t *testing.T) {
+		store, sweeper, tx := setup(t, 5*time.Minute)
+
+		result := sweepOne(t, sweeper)
+		assert.Equal(t, SweepActionRequeued, result.Action)
+		assert.Equal(t, domain.TransactionStatusPending, store.transactions[tx.ID].Status)
+		assert.Equal(t, []string{tx.ID.String()}, store.deletedTasks)
+
+		require.Len(t, store.outbox, 2)
+		requeued := store.outbox[1]
+		assert.Equal(t, queue.TaskTransfer, requeued.TaskType)
+		assert.Equal(t, tx.ID.String(), requeued.TaskID)
+		assert.Equal(t, domain.OutboxStatusPending, requeued.Status)
+	})
+
+	t.Run("completes transfers whose credit already exists", func(t *testing.T) {
+		store, sweeper, tx := setup(t, 5*time.Minute)
+		credit := domain.Transaction{
+			ID:            uuid.New(),
+			UserID:        *tx.TargetUserID,
+			Type:          domain.TransactionTypeCredit,
+			Status:        domain.TransactionStatusSuccess,
+			Amount:        tx.Amount,
+			ReferenceID:   tx.ID,
+			ReferenceType: domain.ReferenceTypeTransfer,
+			CreatedAt:     time.Now(),
+		}
+		store.transactions[credit.ID] = credit
+
+		result := sweepOne(t, sweeper)
+		assert.Equal(t, SweepActionCompleted, result.Action)
+		assert.Equal(t, domain.TransactionStatusSuccess, store.transactions[tx.ID].Status)
+		assert.Len(t, store.transactionsByReference(tx.ID, domain.ReferenceTypeTransfer), 1, "no second credit")
+	})
+}
@@ -42,7 +42,8 @@ func (u *UserUsecase) Register(firstName, lastName, phoneNumber, address, pin st
 		PhoneNumber: phoneNumber,
 		Address:     address,
 		Pin:         string(hashedPin),
-		Balance:     0,
+		Role:        domain.UserRoleUser,
+		Status:      domain.UserStatusActive,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
@@ -93,3 +94,15 @@ func (u *UserUsecase) UpdateProfile(userID uuid.UUID, firstName, lastName, addre
 func (u *UserUsecase) GetUserByID(userID uuid.UUID) (*domain.User, error) {
 	return u.userRepo.GetByID(userID)
 }
+
+func (u *UserUsecase) UpdateStatus(userID uuid.UUID, status domain.UserStatus) (*domain.User, error) {
+	if status != domain.UserStatusActive && status != domain.UserStatusFrozen {
+		return nil, errors.New("invalid user status")
+	}
+
+	if err := u.userRepo.UpdateStatus(userID, status); err != nil {
+		return nil, err
+	}
+
+	return u.userRepo.GetByID(userID)
+}
@@ -36,13 +36,16 @@ func (m *MockUserRepository) GetByID(id uuid.UUID) (*domain.User, error) {
 	return args.Get(0).(*domain.User), args.Error(1)
 }
 
-func (m *MockUserRepository) Update(user *domain.User) error {
-	args := m.Called(user)
-	return args.Error(0)
+func (m *MockUserRepository) GetByIDForUpdate(id uuid.UUID) (*domain.User, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
 }
 
-func (m *MockUserRepository) UpdateBalance(userID uuid.UUID, amount float64) error {
-	args := m.Called(userID, amount)
+func (m *MockUserRepository) Update(user *domain.User) error {
+	args := m.Called(user)
 	return args.Error(0)
 }
 
@@ -135,3 +138,16 @@ func TestUserUsecase_Login(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 	})
 }
+
+func (m *MockUserRepository) UpdateStatus(userID uuid.UUID, status domain.UserStatus) error {
+	args := m.Called(userID, status)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) List(afterID uuid.UUID, limit int) ([]domain.User, error) {
+	args := m.Called(afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.User), args.Error(1)
+}
@@ -0,0 +1,245 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidAmount       = errors.New("invalid amount")
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	ErrCurrencyMismatch    = errors.New("currency mismatch")
+	ErrOverflow            = errors.New("amount out of range")
+)
+
+// exponents holds the ISO 4217 minor unit exponent of every supported currency.
+var exponents = map[string]int{
+	"IDR": 2,
+	"USD": 2,
+	"EUR": 2,
+	"SGD": 2,
+	"MYR": 2,
+	"JPY": 0,
+	"KRW": 0,
+}
+
+// Money is an amount expressed in the minor unit of its currency (e.g. cents).
+// It is stored as two columns, <prefix>minor and <prefix>currency, when
+// embedded in a GORM model.
+type Money struct {
+	Minor    int64  `gorm:"not null;default:0"`
+	Currency string `gorm:"size:3"`
+}
+
+func New(minor int64, currency string) Money {
+	return Money{Minor: minor, Currency: currency}
+}
+
+func Zero(currency string) Money {
+	return Money{Currency: currency}
+}
+
+func Exponent(currency string) (int, error) {
+	exp, ok := exponents[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnsupportedCurrency, currency)
+	}
+	return exp, nil
+}
+
+func ValidateCurrency(currency string) error {
+	_, err := Exponent(currency)
+	return err
+}
+
+// Parse converts a decimal string such as "1500.25" into Money without going
+// through floating point. Inputs with more fractional digits than the
+// currency allows are rejected rather than rounded.
+func Parse(amount, currency string) (Money, error) {
+	exp, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	s := strings.TrimSpace(amount)
+	negative := false
+	if strings.HasPrefix(s, "-") {
+		negative = true
+		s = s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+
+	whole, frac, hasPoint := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if hasPoint && frac == "" {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if len(frac) > exp {
+		return Money{}, fmt.Errorf("%w: %s allows at most %d decimal places", ErrInvalidAmount, currency, exp)
+	}
+
+	digits := whole + frac + strings.Repeat("0", exp-len(frac))
+	digits = strings.TrimLeft(digits, "0")
+	if digits == "" {
+		return Zero(currency), nil
+	}
+
+	minor, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q is out of range", ErrInvalidAmount, amount)
+	}
+	if negative {
+		minor = -minor
+	}
+
+	return New(minor, currency), nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (m Money) SameCurrency(other Money) bool {
+	return m.Currency == other.Currency
+}
+
+// Add and Sub assume both operands share a currency; callers check that with
+// SameCurrency before doing arithmetic. They do not check for overflow, so
+// stored balances are changed with CheckedAdd and CheckedSub instead.
+func (m Money) Add(other Money) Money {
+	return Money{Minor: m.Minor + other.Minor, Currency: m.Currency}
+}
+
+func (m Money) Sub(other Money) Money {
+	return Money{Minor: m.Minor - other.Minor, Currency: m.Currency}
+}
+
+// CheckedAdd is Add for balances: it fails with ErrCurrencyMismatch when the
+// currencies differ and with ErrOverflow when the sum does not fit in int64.
+func (m Money) CheckedAdd(other Money) (Money, error) {
+	if !m.SameCurrency(other) {
+		return Money{}, ErrCurrencyMismatch
+	}
+
+	sum := m.Minor + other.Minor
+	if other.Minor > 0 && sum < m.Minor || other.Minor < 0 && sum > m.Minor {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{Minor: sum, Currency: m.Currency}, nil
+}
+
+// CheckedSub is the checked counterpart of Sub, see CheckedAdd.
+func (m Money) CheckedSub(other Money) (Money, error) {
+	if !m.SameCurrency(other) {
+		return Money{}, ErrCurrencyMismatch
+	}
+
+	diff := m.Minor - other.Minor
+	if other.Minor > 0 && diff > m.Minor || other.Minor < 0 && diff < m.Minor {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{Minor: diff, Currency: m.Currency}, nil
+}
+
+func (m Money) Neg() Money {
+	return Money{Minor: -m.Minor, Currency: m.Currency}
+}
+
+func (m Money) Cmp(other Money) int {
+	switch {
+	case m.Minor < other.Minor:
+		return -1
+	case m.Minor > other.Minor:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (m Money) LessThan(other Money) bool {
+	return m.Minor < other.Minor
+}
+
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Minor > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Minor < 0
+}
+
+// String renders the amount as a plain decimal, e.g. "1500.25".
+func (m Money) String() string {
+	exp := exponents[m.Currency]
+
+	minor := m.Minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+	}
+
+	abs := strconv.FormatUint(absUint(minor), 10)
+	if exp == 0 {
+		return sign + abs
+	}
+	if len(abs) <= exp {
+		abs = strings.Repeat("0", exp-len(abs)+1) + abs
+	}
+
+	return sign + abs[:len(abs)-exp] + "." + abs[len(abs)-exp:]
+}
+
+func absUint(v int64) uint64 {
+	if v == math.MinInt64 {
+		return uint64(math.MaxInt64) + 1
+	}
+	if v < 0 {
+		return uint64(-v)
+	}
+	return uint64(v)
+}
+
+type jsonMoney struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonMoney{Amount: m.String(), Currency: m.Currency})
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v jsonMoney
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	parsed, err := Parse(v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
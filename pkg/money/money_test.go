@@ -0,0 +1,138 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("exact decimal amounts", func(t *testing.T) {
+		cases := map[string]int64{
+			"100000":    10000000,
+			"0.1":       10,
+			"0.29":      29,
+			"1500.25":   150025,
+			".5":        50,
+			"000012.30": 1230,
+			"-7.01":     -701,
+		}
+
+		for input, want := range cases {
+			m, err := Parse(input, "IDR")
+			assert.NoError(t, err, input)
+			assert.Equal(t, want, m.Minor, input)
+			assert.Equal(t, "IDR", m.Currency)
+		}
+	})
+
+	t.Run("zero exponent currency", func(t *testing.T) {
+		m, err := Parse("1500", "JPY")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1500), m.Minor)
+
+		_, err = Parse("1500.5", "JPY")
+		assert.ErrorIs(t, err, ErrInvalidAmount)
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		for _, input := range []string{"", "-", "1.", "1.234", "1e5", "12,50", "abc", "99999999999999999999"} {
+			_, err := Parse(input, "IDR")
+			assert.ErrorIs(t, err, ErrInvalidAmount, input)
+		}
+	})
+
+	t.Run("unsupported currency", func(t *testing.T) {
+		_, err := Parse("10", "XXX")
+		assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+	})
+}
+
+func TestMoney_String(t *testing.T) {
+	assert.Equal(t, "0.00", New(0, "IDR").String())
+	assert.Equal(t, "0.05", New(5, "IDR").String())
+	assert.Equal(t, "1500.25", New(150025, "IDR").String())
+	assert.Equal(t, "-0.10", New(-10, "USD").String())
+	assert.Equal(t, "1500", New(1500, "JPY").String())
+}
+
+func TestMoney_JSON(t *testing.T) {
+	data, err := json.Marshal(New(150025, "IDR"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"1500.25","currency":"IDR"}`, string(data))
+
+	var m Money
+	assert.NoError(t, json.Unmarshal(data, &m))
+	assert.Equal(t, New(150025, "IDR"), m)
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	a := New(1000, "IDR")
+	b := New(250, "IDR")
+
+	assert.Equal(t, New(1250, "IDR"), a.Add(b))
+	assert.Equal(t, New(750, "IDR"), a.Sub(b))
+	assert.True(t, b.LessThan(a))
+	assert.Equal(t, 1, a.Cmp(b))
+	assert.True(t, a.SameCurrency(b))
+	assert.False(t, a.SameCurrency(New(1, "USD")))
+}
+
+func TestMoney_CheckedArithmetic(t *testing.T) {
+	a := New(1000, "IDR")
+	b := New(250, "IDR")
+
+	sum, err := a.CheckedAdd(b)
+	assert.NoError(t, err)
+	assert.Equal(t, New(1250, "IDR"), sum)
+	diff, err := a.CheckedSub(b)
+	assert.NoError(t, err)
+	assert.Equal(t, New(750, "IDR"), diff)
+
+	_, err = a.CheckedAdd(New(1, "USD"))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	_, err = a.CheckedSub(New(1, "USD"))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	_, err = New(math.MaxInt64, "IDR").CheckedAdd(New(1, "IDR"))
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = New(math.MinInt64, "IDR").CheckedAdd(New(-1, "IDR"))
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = New(math.MinInt64, "IDR").CheckedSub(New(1, "IDR"))
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = New(0, "IDR").CheckedSub(New(math.MinInt64, "IDR"))
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	largest, err := New(math.MaxInt64-1, "IDR").CheckedAdd(New(1, "IDR"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt64), largest.Minor)
+}
+
+func TestMoney_Convert(t *testing.T) {
+	rate, err := ParseRate("15523.75")
+	assert.NoError(t, err)
+
+	converted, err := New(1050, "USD").Convert(rate, "IDR")
+	assert.NoError(t, err)
+	assert.Equal(t, New(16299937, "IDR"), converted)
+
+	inverse := new(big.Rat).Inv(rate)
+	converted, err = New(16299937, "IDR").Convert(inverse, "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, New(1049, "USD"), converted, "rounds down")
+
+	yen, err := ParseRate("149.5")
+	assert.NoError(t, err)
+	converted, err = New(1001, "USD").Convert(yen, "JPY")
+	assert.NoError(t, err)
+	assert.Equal(t, New(1496, "JPY"), converted)
+
+	_, err = ParseRate("-1")
+	assert.ErrorIs(t, err, ErrInvalidRate)
+	_, err = ParseRate("1/3")
+	assert.ErrorIs(t, err, ErrInvalidRate)
+	assert.Equal(t, "15523.75", FormatRate(rate))
+}
@@ -0,0 +1,65 @@
+package money
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+var ErrInvalidRate = errors.New("invalid exchange rate")
+
+// ParseRate parses a positive decimal exchange rate such as "15523.75".
+func ParseRate(rate string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok || r.Sign() <= 0 || strings.ContainsAny(rate, "/eE") {
+		return nil, ErrInvalidRate
+	}
+	return r, nil
+}
+
+// FormatRate renders a rate as a decimal with up to 10 fractional digits.
+func FormatRate(rate *big.Rat) string {
+	s := rate.FloatString(10)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// Convert converts m into currency at rate, the price of one unit of m's
+// currency expressed in currency. The result is rounded down to the minor
+// unit of currency.
+func (m Money) Convert(rate *big.Rat, currency string) (Money, error) {
+	fromExp, err := Exponent(m.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	toExp, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if rate.Sign() <= 0 {
+		return Money{}, ErrInvalidRate
+	}
+
+	v := new(big.Rat).SetInt64(m.Minor)
+	v.Mul(v, rate)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(toExp-fromExp))), nil))
+	if toExp >= fromExp {
+		v.Mul(v, scale)
+	} else {
+		v.Quo(v, scale)
+	}
+
+	minor := new(big.Int).Quo(v.Num(), v.Denom())
+	if !minor.IsInt64() {
+		return Money{}, ErrInvalidAmount
+	}
+
+	return New(minor.Int64(), currency), nil
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -2,7 +2,7 @@ package queue
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -11,7 +11,25 @@ import (
 )
 
 const (
-	TaskTransfer = "task:transfer"
+	TaskTransfer              = "task:transfer"
+	TaskSweepPendingTransfers = "task:sweep_pending_transfers"
+	TaskReconcileBalances     = "task:reconcile_balances"
+	TaskExpireAuthorizations  = "task:expire_authorizations"
+	TaskRunTransferSchedules  = "task:run_transfer_schedules"
+	TaskRunSettlements        = "task:run_settlements"
+	TaskExpirePaymentIntents  = "task:expire_payment_intents"
+	TaskPaymentIntentCallback = "task:payment_intent_callback"
+	TaskExpireMoneyRequests   = "task:expire_money_requests"
+
+	// Task states as reported by TaskState.
+	TaskStatePending   = "pending"
+	TaskStateActive    = "active"
+	TaskStateScheduled = "scheduled"
+	TaskStateRetry     = "retry"
+	TaskStateArchived  = "archived"
+	TaskStateCompleted = "completed"
+
+	defaultQueue = "default"
 )
 
 type Config struct {
@@ -22,16 +40,31 @@ type Config struct {
 	DashboardPort int
 }
 
+// TransferPayload carries the amount in minor units of Currency so the worker
+// never sees a floating point value.
 type TransferPayload struct {
-	TransactionID string  `json:"transaction_id"`
-	FromUserID    string  `json:"from_user_id"`
-	ToUserID      string  `json:"to_user_id"`
-	Amount        float64 `json:"amount"`
+	TransactionID string `json:"transaction_id"`
+	FromUserID    string `json:"from_user_id"`
+	ToUserID      string `json:"to_user_id"`
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency"`
+}
+
+// PaymentIntentCallbackPayload asks the worker to tell the merchant that a
+// payment intent reached Status.
+type PaymentIntentCallbackPayload struct {
+	PaymentIntentID string `json:"payment_intent_id"`
+	Status          string `json:"status"`
 }
 
 type QueueService struct {
+	redisOpt      asynq.RedisClientOpt
 	client        *asynq.Client
 	server        *asynq.Server
+	scheduler     *asynq.Scheduler
+	inspector     *asynq.Inspector
+	mux           *asynq.ServeMux
+	failures      map[string]func(task *asynq.Task, err error)
 	monitor       *asynqmon.HTTPHandler
 	dashboardPort int
 }
@@ -44,9 +77,6 @@ func NewQueueService(config *Config) *QueueService {
 	}
 
 	client := asynq.NewClient(redisOpt)
-	server := asynq.NewServer(redisOpt, asynq.Config{
-		Concurrency: 10,
-	})
 
 	// Create Asynq monitor HTTP handler
 	monitor := asynqmon.New(asynqmon.Options{
@@ -55,32 +85,96 @@ func NewQueueService(config *Config) *QueueService {
 	})
 
 	return &QueueService{
+		redisOpt:      redisOpt,
 		client:        client,
-		server:        server,
+		scheduler:     asynq.NewScheduler(redisOpt, nil),
+		inspector:     asynq.NewInspector(redisOpt),
+		mux:           asynq.NewServeMux(),
+		failures:      make(map[string]func(task *asynq.Task, err error)),
 		monitor:       monitor,
 		dashboardPort: config.DashboardPort,
 	}
 }
 
-func (s *QueueService) EnqueueTransfer(payload *TransferPayload) error {
-	jsonPayload, err := json.Marshal(payload)
+// Publish enqueues a task under a caller chosen ID. Publishing the same ID
+// again while the task is still known to asynq is treated as success, which
+// lets the outbox relay retry without creating duplicate tasks.
+func (s *QueueService) Publish(taskType, taskID string, payload []byte) error {
+	task := asynq.NewTask(taskType, payload)
+	_, err := s.client.Enqueue(task, asynq.TaskID(taskID))
+	if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return fmt.Errorf("failed to enqueue %s task: %w", taskType, err)
+	}
+
+	return nil
+}
+
+// HandleFunc registers the worker for a task type. It must be called before
+// Start.
+func (s *QueueService) HandleFunc(taskType string, handler func(task *asynq.Task) error) {
+	s.mux.HandleFunc(taskType, func(ctx context.Context, task *asynq.Task) error {
+		return handler(task)
+	})
+}
+
+// HandleFailure registers a callback for tasks of taskType that failed for
+// the last time, either because their retries are exhausted or because the
+// worker returned an error wrapping asynq.SkipRetry. It must be called
+// before Start.
+func (s *QueueService) HandleFailure(taskType string, handler func(task *asynq.Task, err error)) {
+	s.failures[taskType] = handler
+}
+
+func (s *QueueService) handleError(ctx context.Context, task *asynq.Task, err error) {
+	handler, ok := s.failures[task.Type()]
+	if !ok {
+		return
+	}
+
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	if retried >= maxRetry || errors.Is(err, asynq.SkipRetry) {
+		handler(task, err)
+	}
+}
+
+// Schedule enqueues an empty task of taskType on a cron spec such as
+// "@every 5m". It must be called before Start.
+func (s *QueueService) Schedule(cronspec, taskType string) error {
+	_, err := s.scheduler.Register(cronspec, asynq.NewTask(taskType, nil))
 	if err != nil {
-		return fmt.Errorf("failed to marshal transfer payload: %w", err)
+		return fmt.Errorf("failed to schedule %s: %w", taskType, err)
 	}
+	return nil
+}
 
-	task := asynq.NewTask(TaskTransfer, jsonPayload)
-	_, err = s.client.Enqueue(task)
+// TaskState returns the state of the task enqueued under taskID, or an empty
+// string if asynq no longer knows about it.
+func (s *QueueService) TaskState(taskID string) (string, error) {
+	info, err := s.inspector.GetTaskInfo(defaultQueue, taskID)
+	if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+		return "", nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to enqueue transfer task: %w", err)
+		return "", fmt.Errorf("failed to inspect task %s: %w", taskID, err)
 	}
+	return info.State.String(), nil
+}
 
+// DeleteTask removes a task that is not currently being processed. Deleting
+// a task that does not exist is not an error.
+func (s *QueueService) DeleteTask(taskID string) error {
+	err := s.inspector.DeleteTask(defaultQueue, taskID)
+	if err != nil && !errors.Is(err, asynq.ErrTaskNotFound) && !errors.Is(err, asynq.ErrQueueNotFound) {
+		return fmt.Errorf("failed to delete task %s: %w", taskID, err)
+	}
 	return nil
 }
 
-func (s *QueueService) Start(handler func(task *asynq.Task) error) error {
-	mux := asynq.NewServeMux()
-	mux.HandleFunc(TaskTransfer, func(ctx context.Context, task *asynq.Task) error {
-		return handler(task)
+func (s *QueueService) Start() error {
+	s.server = asynq.NewServer(s.redisOpt, asynq.Config{
+		Concurrency:  10,
+		ErrorHandler: asynq.ErrorHandlerFunc(s.handleError),
 	})
 
 	// Start the monitoring dashboard in a separate goroutine
@@ -95,14 +189,22 @@ func (s *QueueService) Start(handler func(task *asynq.Task) error) error {
 		}
 	}()
 
-	if err := s.server.Start(mux); err != nil {
+	if err := s.server.Start(s.mux); err != nil {
 		return fmt.Errorf("failed to start queue server: %w", err)
 	}
 
+	if err := s.scheduler.Start(); err != nil {
+		return fmt.Errorf("failed to start queue scheduler: %w", err)
+	}
+
 	return nil
 }
 
 func (s *QueueService) Stop() {
-	s.server.Stop()
+	if s.server != nil {
+		s.server.Stop()
+	}
+	s.scheduler.Shutdown()
+	s.inspector.Close()
 	s.client.Close()
 }